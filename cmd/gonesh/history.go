@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/ousiass/GoNeSh/internal/errors"
+	"github.com/ousiass/GoNeSh/internal/history"
+	"github.com/ousiass/GoNeSh/pkg/config"
+)
+
+const historyUsage = `使い方: gonesh history <コマンド>
+
+コマンド:
+  import [--from bash|zsh|fish] [ファイル]  他のシェルの履歴を取り込む
+  scrub                                     記録ルールを既存の履歴に適用する
+  stats [--json] [--limit N]                履歴の統計を表示する
+`
+
+// runHistory runs the history subcommand and returns the exit status
+func runHistory(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, historyUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "import":
+		return historyImport(args[1:])
+	case "scrub":
+		return historyScrub(args[1:])
+	case "stats":
+		return historyStats(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "不明なコマンドです: %s\n\n%s", args[0], historyUsage)
+		return 2
+	}
+}
+
+// historyImport imports the history of bash, zsh and fish.
+// Without --from, the default history file of each shell that has one is
+// imported.
+func historyImport(args []string) int {
+	fs := flag.NewFlagSet("gonesh history import", flag.ContinueOnError)
+	from := fs.String("from", "", "取り込むシェル (bash, zsh, fish)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	shells := history.Shells
+	if *from != "" {
+		shell := history.Shell(*from)
+		if !slices.Contains(history.Shells, shell) {
+			fmt.Fprintf(os.Stderr, "対応していないシェルです: %s\n", *from)
+			return 2
+		}
+		shells = []history.Shell{shell}
+	}
+	if fs.NArg() > 0 && len(shells) != 1 {
+		fmt.Fprintln(os.Stderr, "ファイルを指定するときは --from でシェルを指定してください")
+		return 2
+	}
+
+	h, status := loadHistory()
+	if h == nil {
+		return status
+	}
+	host, _ := os.Hostname()
+
+	for _, shell := range shells {
+		path := fs.Arg(0)
+		if path == "" {
+			var err error
+			if path, err = history.ShellHistoryFile(shell); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+				return 1
+			}
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) && fs.NArg() == 0 {
+				continue // The shell is not used
+			}
+			fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+			status = 1
+			continue
+		}
+		entries, err := history.ReadShellHistory(shell, file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+			status = 1
+			continue
+		}
+
+		for i := range entries {
+			entries[i].Host = host
+		}
+		added, err := h.Import(entries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: %d 件中 %d 件を取り込みました (%s)\n", shell, len(entries), added, path)
+	}
+	return status
+}
+
+// historyScrub removes the commands ignored by the history rules in
+// config.yaml from the history file and masks secrets in the others
+func historyScrub(args []string) int {
+	fs := flag.NewFlagSet("gonesh history scrub", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	h, status := loadHistory()
+	if h == nil {
+		return status
+	}
+	res, err := h.Scrub()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+		return 1
+	}
+	fmt.Printf("%d 件を削除し、%d 件の機密情報を伏せました\n", res.Removed, res.Masked)
+	if res.LegacyFile != "" {
+		fmt.Printf("旧形式の履歴ファイル %s からも %d 件を削除し、%d 件の機密情報を伏せました\n",
+			res.LegacyFile, res.LegacyRemoved, res.LegacyMasked)
+	}
+	return 0
+}
+
+// historyStats prints the statistics of the history as tables or JSON
+func historyStats(args []string) int {
+	fs := flag.NewFlagSet("gonesh history stats", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "JSON で出力する")
+	limit := fs.Int("limit", 10, "各ランキングに表示する件数")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	h, status := loadHistory()
+	if h == nil {
+		return status
+	}
+	stats := history.ComputeStats(h.Entries(), *limit)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Printf("コマンド数: %d (%d 種類, 失敗 %d)\n", stats.Total, stats.Unique, stats.Failures)
+	if !stats.First.IsZero() {
+		fmt.Printf("期間: %s 〜 %s\n",
+			stats.First.Local().Format(time.DateTime), stats.Last.Local().Format(time.DateTime))
+	}
+
+	var rows [][]string
+	for _, c := range stats.MostUsed {
+		rows = append(rows, []string{strconv.Itoa(c.Count), statsCommand(c.Command)})
+	}
+	printTable("よく使うコマンド", []string{"回数", "コマンド"}, rows)
+
+	rows = rows[:0]
+	for _, c := range stats.MostFailed {
+		rows = append(rows, []string{strconv.Itoa(c.Failures), strconv.Itoa(c.Runs), statsCommand(c.Command)})
+	}
+	printTable("よく失敗するコマンド", []string{"失敗", "実行", "コマンド"}, rows)
+
+	rows = rows[:0]
+	for _, c := range stats.Slowest {
+		rows = append(rows, []string{
+			formatDuration(c.Mean), formatDuration(c.Max), strconv.Itoa(c.Runs), statsCommand(c.Command),
+		})
+	}
+	printTable("時間のかかるコマンド", []string{"平均", "最大", "実行", "コマンド"}, rows)
+
+	rows = rows[:0]
+	peak := slices.Max(stats.ByHour[:])
+	for hour, n := range stats.ByHour {
+		rows = append(rows, []string{fmt.Sprintf("%02d", hour), strconv.Itoa(n), statsBar(n, peak)})
+	}
+	printTable("時間帯別", []string{"時", "回数", ""}, rows)
+
+	rows = rows[:0]
+	peak = slices.Max(stats.ByWeekday[:])
+	for day, n := range stats.ByWeekday {
+		rows = append(rows, []string{weekdays[day], strconv.Itoa(n), statsBar(n, peak)})
+	}
+	printTable("曜日別", []string{"曜日", "回数", ""}, rows)
+
+	rows = rows[:0]
+	for _, c := range stats.Hosts {
+		rows = append(rows, []string{strconv.Itoa(c.Count), strconv.Itoa(c.Failures), c.Host})
+	}
+	printTable("ホスト別", []string{"回数", "失敗", "ホスト"}, rows)
+	return 0
+}
+
+// printTable prints a titled table, padding the columns by display width
+func printTable(title string, header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], runewidth.StringWidth(cell))
+		}
+	}
+
+	fmt.Printf("\n%s\n", title)
+	if len(rows) == 0 {
+		fmt.Println("  (なし)")
+		return
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		var sb strings.Builder
+		for i, cell := range row {
+			sb.WriteString("  ")
+			sb.WriteString(cell)
+			if i < len(row)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-runewidth.StringWidth(cell)))
+			}
+		}
+		fmt.Println(strings.TrimRight(sb.String(), " "))
+	}
+}
+
+// weekdays are the names of the days of the week, Sunday first
+var weekdays = [7]string{"日", "月", "火", "水", "木", "金", "土"}
+
+// statsCommand shows a multi-line command on one line
+func statsCommand(command string) string {
+	return strings.ReplaceAll(command, "\n", "↵")
+}
+
+// statsBar draws n as a bar of up to 30 cells relative to peak
+func statsBar(n, peak int) string {
+	if peak == 0 {
+		return ""
+	}
+	return strings.Repeat("█", (n*30+peak-1)/peak)
+}
+
+// formatDuration rounds a duration for display
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// loadHistory loads the history with the rules of config.yaml.
+// On failure it returns nil and the exit status.
+func loadHistory() (*history.History, int) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E1001, err))
+		return nil, 1
+	}
+	rules, err := history.NewRules(cfg.History)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E1003, err))
+		return nil, 1
+	}
+
+	h := history.New(history.DefaultMaxEntries)
+	h.SetRules(rules)
+	if err := h.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+		return nil, 1
+	}
+	return h, 0
+}
@@ -3,18 +3,66 @@
 package main
 
 import (
+	goerrors "errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/ousiass/GoNeSh/internal/core"
 	"github.com/ousiass/GoNeSh/internal/errors"
+	"github.com/ousiass/GoNeSh/internal/history"
+	"github.com/ousiass/GoNeSh/internal/session"
 	"github.com/ousiass/GoNeSh/pkg/config"
 )
 
 const version = "0.1.0"
 
 func main() {
+	// サブコマンド
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		case "server":
+			os.Exit(runServer(os.Args[2:]))
+		case "attach":
+			os.Exit(runAttach(os.Args[2:]))
+		}
+	}
+
+	sessionName := flag.String("session", "", "タブとペインの配置を保存・復元するセッション名")
+	flag.Parse()
+
+	cfg := loadConfig()
+
+	// アプリケーションを初期化
+	app := core.NewApp(cfg)
+
+	// 保存したセッションを復元（初回は空のセッション）
+	if *sessionName != "" {
+		layout, err := session.Load(*sessionName)
+		switch {
+		case goerrors.Is(err, session.ErrInvalidName):
+			fmt.Printf("%v\n", errors.Wrap(errors.E7001, err))
+			os.Exit(1)
+		case err != nil && !goerrors.Is(err, fs.ErrNotExist):
+			fmt.Printf("%v\n", errors.Wrap(errors.E7002, err))
+			os.Exit(1)
+		}
+		app.RestoreSession(*sessionName, layout)
+	}
+
+	runApp(app)
+	if err := app.SessionError(); err != nil {
+		fmt.Printf("%v\n", errors.Wrap(errors.E7003, err))
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the configuration, exiting on errors
+func loadConfig() *config.Config {
 	// 設定を読み込む
 	cfg, err := config.Load()
 	if err != nil {
@@ -23,9 +71,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	// アプリケーションを初期化
-	app := core.NewApp(cfg)
+	// 履歴の記録ルールを確認
+	if _, err := history.NewRules(cfg.History); err != nil {
+		fmt.Printf("%v\n", errors.Wrap(errors.E1003, err))
+		os.Exit(1)
+	}
+	return cfg
+}
 
+// runApp runs the TUI until it quits, exiting on errors
+func runApp(app *core.App) {
 	// Bubbleteaプログラムを開始
 	p := tea.NewProgram(
 		app,
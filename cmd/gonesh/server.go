@@ -0,0 +1,241 @@
+package main
+
+import (
+	goerrors "errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ousiass/GoNeSh/internal/core"
+	"github.com/ousiass/GoNeSh/internal/errors"
+	"github.com/ousiass/GoNeSh/internal/server"
+)
+
+const serverUsage = `使い方: gonesh server [コマンド]
+
+コマンドを省略すると、サーバーをフォアグラウンドで起動します。
+
+コマンド:
+  list  サーバーで動いているシェルの一覧を表示する
+  stop  サーバーを停止し、すべてのシェルを終了する
+`
+
+const (
+	// How long attach waits for a server it started to listen
+	serverStartTimeout = 3 * time.Second
+	// Log of a server started by attach, next to the socket
+	serverLogName = "server.log"
+)
+
+// runServer runs the server subcommand and returns the exit status
+func runServer(args []string) int {
+	if len(args) == 0 {
+		return serveForeground()
+	}
+
+	switch args[0] {
+	case "list":
+		return serverList()
+	case "stop":
+		return serverStop()
+	case "-h", "--help", "help":
+		fmt.Print(serverUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "不明なコマンドです: %s\n\n%s", args[0], serverUsage)
+		return 2
+	}
+}
+
+// serveForeground runs the server until it is stopped. Closing the
+// terminal the server was started in does not stop it.
+func serveForeground() int {
+	path, err := server.SocketPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+		return 1
+	}
+	srv, err := server.Listen(path)
+	if goerrors.Is(err, server.ErrRunning) {
+		fmt.Fprintf(os.Stderr, "GoNeSh サーバーは既に起動しています: %s\n", path)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7005, err))
+		return 1
+	}
+
+	// 端末が閉じられてもシェルを残す。signal.Ignore にすると無視の設定が
+	// シェルやその子プロセスに引き継がれるので、受け取って捨てる
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-sig:
+				_ = srv.Close()
+				return
+			case <-srv.Done():
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("GoNeSh サーバーを起動しました: %s\n", path)
+	if err := srv.Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7005, err))
+		_ = srv.Close()
+		return 1
+	}
+	_ = os.Remove(path)
+	return 0
+}
+
+// serverList prints the shells running in the server
+func serverList() int {
+	c, status := dialServer()
+	if c == nil {
+		return status
+	}
+	defer c.Close()
+
+	panes, err := c.Panes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7004, err))
+		return 1
+	}
+	if len(panes) == 0 {
+		fmt.Println("動いているシェルはありません")
+		return 0
+	}
+	for _, p := range panes {
+		cmd := strings.Join(p.Command, " ")
+		if cmd == "" {
+			cmd = p.Process
+		}
+		fmt.Printf("%3d  %-30s %s\n", p.ID, cmd, p.Dir)
+	}
+	return 0
+}
+
+// serverStop stops the server
+func serverStop() int {
+	c, status := dialServer()
+	if c == nil {
+		return status
+	}
+	defer c.Close()
+
+	if err := c.Shutdown(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7004, err))
+		return 1
+	}
+	fmt.Println("GoNeSh サーバーを停止しました")
+	return 0
+}
+
+// dialServer connects to the running server. It returns nil and the exit
+// status if there is none.
+func dialServer() (*server.Client, int) {
+	path, err := server.SocketPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+		return nil, 1
+	}
+	c, err := server.Dial(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "GoNeSh サーバーは起動していません")
+		return nil, 1
+	}
+	return c, 0
+}
+
+// runAttach runs the TUI on the shells of the server, starting the server
+// in the background if it is not running. Quitting the TUI detaches from
+// the server and leaves the shells running.
+func runAttach(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "使い方: gonesh attach")
+		return 2
+	}
+	cfg := loadConfig()
+
+	path, err := server.SocketPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E9001, err))
+		return 1
+	}
+	c, err := server.Dial(path)
+	if err != nil {
+		if c, err = startServer(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7005, err))
+			return 1
+		}
+	}
+
+	app := core.NewApp(cfg)
+	if err := app.Attach(c); err != nil {
+		_ = c.Close()
+		fmt.Fprintf(os.Stderr, "%v\n", errors.Wrap(errors.E7004, err))
+		return 1
+	}
+	runApp(app)
+	_ = c.Close()
+
+	if app.ServerLost() {
+		fmt.Println("GoNeSh サーバーとの接続が切れました")
+		return 1
+	}
+	if app.Detached() {
+		fmt.Println("デタッチしました（gonesh attach で再開できます）")
+	}
+	return 0
+}
+
+// startServer starts the server in the background, in a session of its
+// own so that it outlives the terminal, and connects to it
+func startServer(path string) (*server.Client, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, serverLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "server")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	_ = cmd.Process.Release()
+
+	// ソケットが作られるまで待つ
+	deadline := time.Now().Add(serverStartTimeout)
+	for {
+		c, err := server.Dial(path)
+		if err == nil {
+			return c, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -2,11 +2,19 @@
 package core
 
 import (
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ousiass/GoNeSh/internal/history"
+	"github.com/ousiass/GoNeSh/internal/server"
+	"github.com/ousiass/GoNeSh/internal/session"
+	"github.com/ousiass/GoNeSh/internal/terminal"
 	"github.com/ousiass/GoNeSh/internal/ui/context"
 	"github.com/ousiass/GoNeSh/internal/ui/organisms"
 	"github.com/ousiass/GoNeSh/pkg/config"
@@ -20,6 +28,14 @@ const (
 	StateTerminal
 )
 
+// How often history added by other GoNeSh instances is merged in
+const historyReloadInterval = 2 * time.Second
+
+// historyReloadMsg is sent after the history file has been reloaded
+type historyReloadMsg struct {
+	err error // Why the history file is left alone, see history.Err
+}
+
 // App represents the main application state
 type App struct {
 	config    *config.Config
@@ -35,13 +51,44 @@ type App struct {
 	showHelp  bool
 	state     AppState
 
-	// Terminal sessions per tab
-	terminals         map[int]*organisms.Terminal
+	// Last ID given to a terminal. Tabs own their terminals; the ID
+	// routes PTY notifications to them.
 	terminalIDCounter int
 
 	// Command history
 	history       *history.History
 	historySearch *organisms.HistorySearch
+	historyStats  *organisms.HistoryStats
+
+	// Details of the resource whose meter was clicked
+	resourceMonitor *organisms.ResourceMonitor
+
+	// Confirmation before risky pastes
+	pasteConfirm *organisms.PasteConfirm
+
+	// Prompt for the name of the active tab
+	tabRename *organisms.TabRename
+
+	// Text last copied from a terminal, for the paste key
+	clipboard string
+
+	// Whether the host terminal's keypad is in application mode, and
+	// whether the app is quitting and has to leave it in numeric mode
+	hostKeypad bool
+	quitting   bool
+
+	// Name the layout is saved under on quit, set by --session, and the
+	// error of the save
+	sessionName string
+	sessionErr  error
+
+	// Connection to the GoNeSh server the shells run in, set by `gonesh
+	// attach`, the layout last sent to it, whether it was lost and whether
+	// the app quit leaving the shells running there
+	server       *server.Client
+	serverLayout *session.Layout
+	serverLost   bool
+	detached     bool
 }
 
 // NewApp creates a new application instance
@@ -60,6 +107,9 @@ func NewApp(cfg *config.Config) *App {
 
 	// Initialize history
 	hist := history.New(history.DefaultMaxEntries)
+	if rules, err := history.NewRules(cfg.History); err == nil {
+		hist.SetRules(rules)
+	}
 	_ = hist.Load() // Load existing history
 
 	app := &App{
@@ -71,15 +121,21 @@ func NewApp(cfg *config.Config) *App {
 		statusBar:         organisms.NewStatusBar(ui),
 		helpModal:         organisms.NewHelpModal(ui),
 		welcome:           organisms.NewWelcome(ui),
-		terminals:         make(map[int]*organisms.Terminal),
 		terminalIDCounter: 0,
 		history:           hist,
 		historySearch:     organisms.NewHistorySearch(ui, hist),
+		historyStats:      organisms.NewHistoryStats(ui),
+		resourceMonitor:   organisms.NewResourceMonitor(ui),
+		pasteConfirm:      organisms.NewPasteConfirm(ui),
+		tabRename:         organisms.NewTabRename(ui),
 		state:             StateWelcome,
 	}
 
+	// 履歴ファイルが読めないことを知らせる
+	app.statusBar.SetHistoryError(hist.Err())
+
 	// Create initial terminal for the first tab (but don't start it yet)
-	app.terminals[0] = organisms.NewTerminal(ui, 0)
+	app.tabBar.AddTab("local", "local", app.newTerminal())
 
 	return app
 }
@@ -89,27 +145,56 @@ func (a *App) Init() tea.Cmd {
 	cmds := []tea.Cmd{
 		a.statusBar.Init(),
 		a.welcome.Init(),
+		a.reloadHistory(),
 		tea.SetWindowTitle("GoNeSh"),
 	}
+	if a.server != nil {
+		// アタッチ時はウェルカム画面を出さずにサーバーのシェルを表示する
+		cmds = append(cmds, a.startTerminals(), a.waitServer())
+	}
 
 	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the application state
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := a.update(msg)
+	return m, tea.Batch(cmd, a.syncKeypad())
+}
+
+func (a *App) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// 他のインスタンスの履歴を定期的に取り込む
+	if msg, ok := msg.(historyReloadMsg); ok {
+		a.statusBar.SetHistoryError(msg.err)
+		return a, a.reloadHistory()
+	}
+
+	// サーバーとの接続が切れたら終了する（デタッチ後は除く）
+	if _, ok := msg.(serverClosedMsg); ok {
+		if a.server == nil {
+			return a, nil
+		}
+		a.serverLost = true
+		return a, a.quit(true)
+	}
+
+	// タブやペインの変更をサーバーへ伝える
+	defer a.syncLayout()
+
+	// ステータスバーを更新（モーダルの表示中もリソースの記録を続ける）
+	var cmd tea.Cmd
+	a.statusBar, cmd = a.statusBar.Update(msg)
+	cmds = append(cmds, cmd)
+
 	// Handle welcome state
 	if a.state == StateWelcome {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			// Any key press transitions to terminal
 			a.state = StateTerminal
-			// Initialize the terminal
-			if term, ok := a.terminals[0]; ok {
-				return a, term.Init()
-			}
-			return a, nil
+			return a, a.startTerminals()
 		case tea.WindowSizeMsg:
 			a.width = msg.Width
 			a.height = msg.Height
@@ -122,20 +207,70 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
-		// Update status bar
+		return a, tea.Batch(cmds...)
+	}
+
+	// Copy terminal selections to the host clipboard
+	if msg, ok := msg.(organisms.CopyMsg); ok {
+		a.clipboard = msg.Text
+		return a, copyToClipboard(msg.Text)
+	}
+
+	// Record commands reported by shell integration
+	if msg, ok := msg.(organisms.CommandFinishedMsg); ok {
+		a.recordCommand(msg)
+		return a, nil
+	}
+
+	// Handle paste confirmation result
+	if result, ok := msg.(organisms.PasteConfirmResult); ok {
+		if result.Confirmed {
+			if term := a.activeTerminal(); term != nil {
+				term.Paste(result.Text)
+			}
+		}
+		return a, nil
+	}
+
+	// If the paste confirmation is visible, it takes all keys
+	if a.pasteConfirm.IsVisible() {
 		var cmd tea.Cmd
-		a.statusBar, cmd = a.statusBar.Update(msg)
+		a.pasteConfirm, cmd = a.pasteConfirm.Update(msg)
 		cmds = append(cmds, cmd)
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		}
+		return a, tea.Batch(cmds...)
+	}
 
+	// タブ名の入力結果
+	if result, ok := msg.(organisms.TabRenameResult); ok {
+		if result.Confirmed {
+			a.tabBar.RenameTab(result.Name)
+		}
+		return a, nil
+	}
+
+	// タブ名の入力中はキーを入力欄へ
+	if a.tabRename.IsVisible() {
+		var cmd tea.Cmd
+		a.tabRename, cmd = a.tabRename.Update(msg)
+		cmds = append(cmds, cmd)
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		}
 		return a, tea.Batch(cmds...)
 	}
 
 	// Handle history search result
 	if result, ok := msg.(organisms.HistorySearchResult); ok {
 		if result.Selected && result.Entry != "" {
-			// Send selected history entry to terminal
-			if term := a.activeTerminal(); term != nil {
-				term.SendInput(result.Entry)
+			// Insert the selected entry like a paste, so that a multi-line
+			// entry is neither run right away nor typed past the confirmation
+			if len(organisms.PasteWarnings(result.Entry)) > 0 {
+				a.requestPaste(result.Entry)
+			} else if term := a.activeTerminal(); term != nil {
+				term.Paste(result.Entry)
 			}
 		}
 		return a, nil
@@ -148,6 +283,32 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+		// Terminals keep receiving their output notifications
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		}
+		return a, tea.Batch(cmds...)
+	}
+
+	// 履歴統計の表示中はキーを統計パネルへ
+	if a.historyStats.IsVisible() {
+		var cmd tea.Cmd
+		a.historyStats, cmd = a.historyStats.Update(msg)
+		cmds = append(cmds, cmd)
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		}
+		return a, tea.Batch(cmds...)
+	}
+
+	// リソースモニターの表示中はキーとクリックをモニターへ
+	if a.resourceMonitor.IsVisible() {
+		var cmd tea.Cmd
+		a.resourceMonitor, cmd = a.resourceMonitor.Update(msg)
+		cmds = append(cmds, cmd)
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		}
 		return a, tea.Batch(cmds...)
 	}
 
@@ -162,8 +323,8 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmd := a.addNewTab()
 				return a, cmd
 			case "w":
-				if a.closeCurrentTab() {
-					return a, tea.Quit
+				if a.closePane() {
+					return a, a.quit(false)
 				}
 				return a, nil
 			case "]":
@@ -172,6 +333,38 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "[":
 				a.tabBar.PrevTab()
 				return a, nil
+			case "v":
+				if term := a.activeTerminal(); term != nil {
+					term.EnterScrollMode()
+				}
+				return a, nil
+			case "/":
+				if term := a.activeTerminal(); term != nil {
+					term.StartSearch()
+				}
+				return a, nil
+			case "y":
+				a.requestPaste(a.clipboard)
+				return a, nil
+			case "n":
+				a.showTabRename()
+				return a, nil
+			case "{":
+				a.tabBar.MoveTab(-1)
+				return a, nil
+			case "}":
+				a.tabBar.MoveTab(1)
+				return a, nil
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				a.selectTabNumber(key)
+				return a, nil
+			case "\\":
+				return a, a.splitPane(organisms.SplitRight)
+			case "-":
+				return a, a.splitPane(organisms.SplitDown)
+			case "h":
+				a.showHistoryStats()
+				return a, nil
 			case "a", "p", "c", "x", "f", "s", "r", "g":
 				// TODO: 実装
 				return a, nil
@@ -181,27 +374,44 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, nil
 		}
 
+		// 端末からのペースト（検索入力中はクエリへ）
+		if msg.Paste {
+			if term := a.activeTerminal(); term != nil && !term.IsSearching() {
+				a.requestPaste(string(msg.Runes))
+				return a, nil
+			}
+		}
+
 		// 通常時 - Alt キーのショートカット
 		switch msg.String() {
 		case "ctrl+c", "ctrl+q":
-			// Save history before quitting
-			_ = a.history.Save()
-			a.closeAllTerminals()
-			return a, tea.Quit
+			return a, a.quit(true)
 		case "ctrl+r":
 			// Show history search
+			var dir, host, session string
+			if tab := a.tabBar.ActiveTab(); tab != nil {
+				dir, host = tab.Terminal().WorkingDir()
+				session = tab.ID
+			}
+			if host == "" {
+				host, _ = os.Hostname()
+			}
 			a.historySearch.SetSize(a.width, a.calculateContentHeight())
-			a.historySearch.Show()
+			a.historySearch.Show(dir, host, session)
 			return a, nil
 		case "?":
+			// スクロールモードでは後方検索
+			if term := a.activeTerminal(); term != nil && term.IsScrolling() {
+				return a, a.updateActiveTerminal(msg)
+			}
 			a.showHelp = true
 			return a, nil
 		case "alt+t":
 			cmd := a.addNewTab()
 			return a, cmd
 		case "alt+w":
-			if a.closeCurrentTab() {
-				return a, tea.Quit
+			if a.closePane() {
+				return a, a.quit(false)
 			}
 			return a, nil
 		case "alt+]":
@@ -210,22 +420,66 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "alt+[":
 			a.tabBar.PrevTab()
 			return a, nil
+		case "alt+v":
+			if term := a.activeTerminal(); term != nil {
+				term.EnterScrollMode()
+			}
+			return a, nil
+		case "alt+/":
+			if term := a.activeTerminal(); term != nil {
+				term.StartSearch()
+			}
+			return a, nil
+		case "alt+y":
+			a.requestPaste(a.clipboard)
+			return a, nil
+		case "alt+h":
+			a.showHistoryStats()
+			return a, nil
+		case "alt+n":
+			a.showTabRename()
+			return a, nil
+		case "alt+{":
+			a.tabBar.MoveTab(-1)
+			return a, nil
+		case "alt+}":
+			a.tabBar.MoveTab(1)
+			return a, nil
+		case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+			a.selectTabNumber(strings.TrimPrefix(msg.String(), "alt+"))
+			return a, nil
+		case "alt+\\":
+			return a, a.splitPane(organisms.SplitRight)
+		case "alt+-":
+			return a, a.splitPane(organisms.SplitDown)
+		case "alt+up", "alt+down", "alt+left", "alt+right":
+			// ペインが1つのときはシェルへ（単語移動など）
+			if tab := a.tabBar.ActiveTab(); tab != nil && len(tab.Panes()) > 1 {
+				tab.MoveFocus(arrowDelta(msg.String()))
+				return a, nil
+			}
+			cmds = append(cmds, a.updateActiveTerminal(msg))
+		case "alt+shift+up", "alt+shift+down", "alt+shift+left", "alt+shift+right":
+			if tab := a.tabBar.ActiveTab(); tab != nil {
+				tab.ResizePane(arrowDelta(msg.String()))
+			}
+			return a, nil
 		case "alt+a": // AIパネル
 		case "alt+p": // プリセット
 		case "alt+c": // Claude
 		case "alt+x": // 外部AI
-		case "alt+f": // ファイル
+		case "alt+f":
+			// 補完候補があれば1単語だけ確定
+			if term := a.activeTerminal(); term != nil && term.HasSuggestion() {
+				return a, a.updateActiveTerminal(msg)
+			}
+			// ファイル
 		case "alt+s": // 転送
 		case "alt+r": // API
 		case "alt+g": // Git
 		default:
 			// Forward key to active terminal
-			if term := a.activeTerminal(); term != nil {
-				var cmd tea.Cmd
-				term, cmd = term.Update(msg)
-				a.terminals[a.tabBar.ActiveTabIndex()] = term
-				cmds = append(cmds, cmd)
-			}
+			cmds = append(cmds, a.updateActiveTerminal(msg))
 		}
 
 	case tea.WindowSizeMsg:
@@ -238,25 +492,39 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Update terminal sizes
 		contentHeight := a.calculateContentHeight()
-		for _, term := range a.terminals {
-			term.SetSize(msg.Width, contentHeight)
+		for _, tab := range a.tabBar.Tabs() {
+			tab.SetSize(msg.Width, contentHeight)
 		}
 		a.historySearch.SetSize(msg.Width, contentHeight)
 
-	default:
-		// Forward other messages to active terminal
-		if term := a.activeTerminal(); term != nil {
-			var cmd tea.Cmd
-			term, cmd = term.Update(msg)
-			a.terminals[a.tabBar.ActiveTabIndex()] = term
+	case tea.MouseMsg:
+		contentTop, contentHeight := a.contentTop(), a.calculateContentHeight()
+		switch {
+		case msg.Y < contentTop:
+			cmd, quit := a.clickTabBar(msg)
+			if quit {
+				return a, a.quit(false)
+			}
 			cmds = append(cmds, cmd)
+		case msg.Y >= contentTop+contentHeight:
+			cmds = append(cmds, a.clickStatusBar(msg))
+		default:
+			// Mouse events in the content area go to the panes of the
+			// active tab, relative to its top left corner
+			msg.Y -= contentTop
+			if tab := a.tabBar.ActiveTab(); tab != nil {
+				cmds = append(cmds, tab.UpdateMouse(msg))
+			}
 		}
-	}
 
-	// ステータスバーを更新
-	var cmd tea.Cmd
-	a.statusBar, cmd = a.statusBar.Update(msg)
-	cmds = append(cmds, cmd)
+	default:
+		if organisms.IsPTYMsg(msg) {
+			cmds = append(cmds, a.updateTerminals(msg))
+		} else {
+			// Other input goes to the active terminal
+			cmds = append(cmds, a.updateActiveTerminal(msg))
+		}
+	}
 
 	return a, tea.Batch(cmds...)
 }
@@ -272,6 +540,9 @@ func (a *App) View() string {
 	tabBarHeight := lipgloss.Height(tabBar)
 
 	// ステータスバー
+	if term := a.activeTerminal(); term != nil {
+		a.statusBar.SetSearch(term.SearchMatches())
+	}
 	statusBar := a.statusBar.View()
 	statusBarHeight := lipgloss.Height(statusBar)
 
@@ -288,21 +559,34 @@ func (a *App) View() string {
 		content = a.welcome.View()
 	} else if a.historySearch.IsVisible() {
 		// Show history search overlay on top of terminal
-		if term := a.activeTerminal(); term != nil {
-			term.SetSize(a.width, contentHeight)
-			termView := term.View()
+		if tab := a.tabBar.ActiveTab(); tab != nil {
+			tab.SetSize(a.width, contentHeight)
+			termView := tab.View(a.ui)
 			searchView := a.historySearch.View()
 			// Overlay search on terminal
 			content = a.overlayViews(termView, searchView, a.width, contentHeight)
 		} else {
 			content = a.historySearch.View()
 		}
+	} else if a.pasteConfirm.IsVisible() {
+		a.pasteConfirm.SetSize(a.width, contentHeight)
+		content = a.pasteConfirm.View()
+	} else if a.tabRename.IsVisible() {
+		a.tabRename.SetSize(a.width, contentHeight)
+		content = a.tabRename.View()
+	} else if a.historyStats.IsVisible() {
+		a.historyStats.SetSize(a.width, contentHeight)
+		content = a.historyStats.View()
+	} else if a.resourceMonitor.IsVisible() {
+		a.resourceMonitor.SetSize(a.width, contentHeight)
+		a.resourceMonitor.SetHistory(a.statusBar.History(a.resourceMonitor.Resource()))
+		content = a.resourceMonitor.View()
 	} else if a.showHelp {
 		a.helpModal.SetSize(a.width, contentHeight)
 		content = a.helpModal.View()
-	} else if term := a.activeTerminal(); term != nil {
-		term.SetSize(a.width, contentHeight)
-		content = term.View()
+	} else if tab := a.tabBar.ActiveTab(); tab != nil {
+		tab.SetSize(a.width, contentHeight)
+		content = tab.View(a.ui)
 	} else {
 		content = ""
 	}
@@ -333,65 +617,412 @@ func (a *App) overlayViews(base, overlay string, width, height int) string {
 	return lipgloss.JoinVertical(lipgloss.Left, overlay)
 }
 
-// activeTerminal returns the terminal for the active tab
+// requestPaste pastes text into the active terminal, asking first when the
+// text could run commands by accident. Every paste is confirmed in a
+// production environment.
+func (a *App) requestPaste(text string) {
+	term := a.activeTerminal()
+	if term == nil || text == "" {
+		return
+	}
+
+	env := a.statusBar.Env()
+	warnings := organisms.PasteWarnings(text)
+	if len(warnings) > 0 || env == "prod" {
+		a.pasteConfirm.Show(text, warnings, env)
+		return
+	}
+	term.Paste(text)
+}
+
+// showHistoryStats opens the history statistics panel
+func (a *App) showHistoryStats() {
+	a.historyStats.Show(history.ComputeStats(a.history.Entries(), 0))
+}
+
+// updateTerminals forwards a PTY notification to every terminal.
+// Notifications carry the terminal ID, so background tabs keep receiving
+// their own output while another tab is active.
+func (a *App) updateTerminals(msg tea.Msg) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, tab := range a.tabBar.Tabs() {
+		for _, pane := range tab.Panes() {
+			var cmd tea.Cmd
+			pane.Terminal, cmd = pane.Terminal.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	}
+	a.tabBar.UpdateActivity()
+	return tea.Batch(cmds...)
+}
+
+// updateActiveTerminal forwards a message to the focused terminal of the
+// active tab
+func (a *App) updateActiveTerminal(msg tea.Msg) tea.Cmd {
+	tab := a.tabBar.ActiveTab()
+	if tab == nil {
+		return nil
+	}
+	var cmd tea.Cmd
+	tab.Focus.Terminal, cmd = tab.Focus.Terminal.Update(msg)
+	return cmd
+}
+
+// activeTerminal returns the focused terminal of the active tab
 func (a *App) activeTerminal() *organisms.Terminal {
-	return a.terminals[a.tabBar.ActiveTabIndex()]
+	if tab := a.tabBar.ActiveTab(); tab != nil {
+		return tab.Terminal()
+	}
+	return nil
 }
 
-// addNewTab adds a new tab with a terminal
-func (a *App) addNewTab() tea.Cmd {
+// newTerminal creates a terminal with a new ID that suggests commands
+// from the history
+func (a *App) newTerminal() *organisms.Terminal {
 	a.terminalIDCounter++
-	id := a.terminalIDCounter
+	term := organisms.NewTerminal(a.ui, a.terminalIDCounter)
+	term.SetSuggestFunc(a.suggestCommand)
+	if c := a.server; c != nil {
+		// シェルはサーバーで起動する
+		term.SetSessionFunc(func(dir string, rows, cols int) (terminal.Session, error) {
+			return c.Spawn(dir, rows, cols)
+		})
+	}
+	return term
+}
+
+// serverTerminal creates a terminal attached to a pane of the GoNeSh
+// server
+func (a *App) serverTerminal(id int) *organisms.Terminal {
+	term := a.newTerminal()
+	c := a.server
+	term.SetSessionFunc(func(string, int, int) (terminal.Session, error) {
+		return c.Attach(id)
+	})
+	return term
+}
 
-	a.tabBar.AddTab("new", "local")
-	term := organisms.NewTerminal(a.ui, id)
-	a.terminals[a.tabBar.ActiveTabIndex()] = term
+// suggestCommand completes the input typed at a prompt from the history
+func (a *App) suggestCommand(input, dir, host string) string {
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return a.history.Suggest(input, dir, host)
+}
+
+// addNewTab adds a new tab with a terminal
+func (a *App) addNewTab() tea.Cmd {
+	term := a.newTerminal()
+	tab := a.tabBar.AddTab("local", "local", term)
 
 	// Set size if known
 	if a.width > 0 && a.height > 0 {
-		contentHeight := a.calculateContentHeight()
-		term.SetSize(a.width, contentHeight)
+		tab.SetSize(a.width, a.calculateContentHeight())
 	}
 
 	return term.Init()
 }
 
-// closeCurrentTab closes the current tab and its terminal
-func (a *App) closeCurrentTab() bool {
-	idx := a.tabBar.ActiveTabIndex()
-	if term, ok := a.terminals[idx]; ok {
-		_ = term.Close()
-		delete(a.terminals, idx)
+// splitPane splits the focused pane of the active tab and starts a shell
+// in the new pane, sized to its part of the tab
+func (a *App) splitPane(dir organisms.SplitDir) tea.Cmd {
+	tab := a.tabBar.ActiveTab()
+	if tab == nil {
+		return nil
 	}
+	term := a.newTerminal()
+	tab.Split(dir, term)
+	if a.width > 0 && a.height > 0 {
+		tab.SetSize(a.width, a.calculateContentHeight())
+	}
+	return term.Init()
+}
 
-	if a.tabBar.CloseTab() {
-		// Save history before quitting
-		_ = a.history.Save()
-		return true // Should quit
+// showTabRename asks for a new name for the active tab, starting from the
+// name the user gave it
+func (a *App) showTabRename() {
+	tab := a.tabBar.ActiveTab()
+	if tab == nil {
+		return
 	}
+	name := ""
+	if tab.Renamed {
+		name = tab.Name
+	}
+	a.tabRename.Show(name)
+}
+
+// selectTabNumber switches to the tab with the given number, counted from 1
+func (a *App) selectTabNumber(number string) {
+	a.tabBar.SelectTab(int(number[0]-'0') - 1)
+}
+
+// arrowDelta returns the direction of the arrow key in a key name
+func arrowDelta(key string) (dx, dy int) {
+	switch {
+	case strings.HasSuffix(key, "up"):
+		return 0, -1
+	case strings.HasSuffix(key, "down"):
+		return 0, 1
+	case strings.HasSuffix(key, "left"):
+		return -1, 0
+	default:
+		return 1, 0
+	}
+}
+
+// closePane closes the focused pane of the active tab, or the tab if it
+// is the last pane. It returns true if the app should quit.
+func (a *App) closePane() bool {
+	if tab := a.tabBar.ActiveTab(); tab != nil {
+		if term, ok := tab.ClosePane(); ok {
+			_ = term.Close()
+			return false
+		}
+	}
+	return a.closeCurrentTab()
+}
+
+// closeCurrentTab closes the current tab and the terminals of its panes
+func (a *App) closeCurrentTab() bool {
+	return a.closeTab(a.tabBar.ActiveTabIndex())
+}
 
-	// Re-index terminals after closing
-	a.reindexTerminals()
+// closeTab closes the tab at index i and the terminals of its panes.
+// It returns true if the app should quit, in which case the last tab is
+// left for quit, to save its layout before closing its terminals.
+func (a *App) closeTab(i int) bool {
+	tabs := a.tabBar.Tabs()
+	if i < 0 || i >= len(tabs) {
+		return false
+	}
+	tab := tabs[i]
+	if a.tabBar.CloseTabAt(i) {
+		return true
+	}
+	for _, pane := range tab.Panes() {
+		_ = pane.Terminal.Close()
+	}
 	return false
 }
 
+// quit saves the history and the session layout and quits. The shells are
+// closed, except that with detach set the app detaches from the GoNeSh
+// server it is attached to and leaves them running there.
+func (a *App) quit(detach bool) tea.Cmd {
+	a.quitting = true
+	_ = a.history.Save()
+	a.saveSession()
+	if a.server == nil || !detach {
+		a.closeAllTerminals()
+	}
+	if a.server != nil {
+		a.detached = detach
+		a.detach()
+	}
+	return tea.Quit
+}
+
+// startTerminals starts the shells of all tabs, sized to the content area
+func (a *App) startTerminals() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, tab := range a.tabBar.Tabs() {
+		if a.width > 0 && a.height > 0 {
+			tab.SetSize(a.width, a.calculateContentHeight())
+		}
+		for _, pane := range tab.Panes() {
+			cmds = append(cmds, pane.Terminal.Init())
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// RestoreSession sets the name the layout is saved under on quit, and
+// replaces the first tab with the tabs of a saved layout, if any
+func (a *App) RestoreSession(name string, layout *session.Layout) {
+	a.sessionName = name
+	if layout == nil || len(layout.Tabs) == 0 {
+		return
+	}
+
+	// 最初のタブのシェルはまだ起動していない
+	a.tabBar = organisms.NewTabBar(a.ui)
+	for _, st := range layout.Tabs {
+		a.tabBar.AddSavedTab(st, func(session.Pane) *organisms.Terminal {
+			return a.newTerminal()
+		})
+	}
+	a.tabBar.SelectTab(layout.ActiveTab)
+}
+
+// Attach shows the shells running in the GoNeSh server, laid out as the
+// last client left them, instead of starting local shells. Panes that
+// are not in the layout get tabs of their own, and a new shell is started
+// in the server if it has none. The welcome screen is skipped.
+func (a *App) Attach(c *server.Client) error {
+	panes, err := c.Panes()
+	if err != nil {
+		return err
+	}
+	layout, err := c.Layout()
+	if err != nil {
+		return err
+	}
+	a.server = c
+
+	running := make(map[int]bool, len(panes))
+	for _, p := range panes {
+		running[p.ID] = true
+	}
+	shown := make(map[int]bool, len(panes))
+
+	// 終了したペインはレイアウトから除く
+	a.tabBar = organisms.NewTabBar(a.ui)
+	active := 0
+	if layout != nil {
+		for i, st := range layout.Tabs {
+			root, ok := st.Root.Prune(func(p session.Pane) bool {
+				return running[p.Server] && !shown[p.Server]
+			})
+			if !ok {
+				continue
+			}
+			st.Root = root
+			if i == layout.ActiveTab {
+				active = len(a.tabBar.Tabs())
+			}
+			a.tabBar.AddSavedTab(st, func(p session.Pane) *organisms.Terminal {
+				shown[p.Server] = true
+				return a.serverTerminal(p.Server)
+			})
+		}
+	}
+	for _, p := range panes {
+		if !shown[p.ID] {
+			a.tabBar.AddTab("local", "local", a.serverTerminal(p.ID))
+		}
+	}
+	if len(a.tabBar.Tabs()) == 0 {
+		a.tabBar.AddTab("local", "local", a.newTerminal())
+	}
+	a.tabBar.SelectTab(active)
+
+	a.state = StateTerminal
+	return nil
+}
+
+// serverClosedMsg is sent when the connection to the GoNeSh server is lost
+type serverClosedMsg struct{}
+
+// waitServer waits for the connection to the GoNeSh server to close
+func (a *App) waitServer() tea.Cmd {
+	c := a.server
+	return func() tea.Msg {
+		<-c.Done()
+		return serverClosedMsg{}
+	}
+}
+
+// syncLayout sends the layout of the tabs to the GoNeSh server when it has
+// changed, so that the next client attaching shows the same tabs
+func (a *App) syncLayout() {
+	if a.server == nil || a.state != StateTerminal {
+		return
+	}
+	layout := a.tabBar.ServerLayout()
+	if reflect.DeepEqual(layout, a.serverLayout) {
+		return
+	}
+	a.serverLayout = layout
+	_ = a.server.SetLayout(layout)
+}
+
+// detach disconnects from the GoNeSh server, leaving the shells running
+func (a *App) detach() {
+	a.syncLayout()
+	_ = a.server.Close()
+	a.server = nil
+}
+
+// ServerLost reports whether the app quit because the connection to the
+// GoNeSh server was lost
+func (a *App) ServerLost() bool {
+	return a.serverLost
+}
+
+// Detached reports whether the app quit leaving its shells running in the
+// GoNeSh server
+func (a *App) Detached() bool {
+	return a.detached && !a.serverLost
+}
+
+// saveSession saves the layout under the session name, if there is one
+func (a *App) saveSession() {
+	if a.sessionName != "" {
+		a.sessionErr = session.Save(a.sessionName, a.tabBar.Layout())
+	}
+}
+
+// SessionError returns the error of saving the session on quit
+func (a *App) SessionError() error {
+	return a.sessionErr
+}
+
 // closeAllTerminals closes all terminal sessions
 func (a *App) closeAllTerminals() {
-	for _, term := range a.terminals {
-		_ = term.Close()
+	for _, tab := range a.tabBar.Tabs() {
+		for _, pane := range tab.Panes() {
+			_ = pane.Terminal.Close()
+		}
+	}
+}
+
+// clickTabBar handles a mouse event on the tab bar. Clicking a tab
+// switches to it, a middle click closes it and the "+" button opens a new
+// tab. It returns true if the app should quit.
+func (a *App) clickTabBar(msg tea.MouseMsg) (tea.Cmd, bool) {
+	// タブはバーの1行目、2行目は境界線
+	if msg.Action != tea.MouseActionPress || msg.Y != 0 {
+		return nil, false
+	}
+	index, newTab := a.tabBar.HitTest(msg.X)
+	switch msg.Button {
+	case tea.MouseButtonLeft:
+		if newTab {
+			return a.addNewTab(), false
+		}
+		a.tabBar.SelectTab(index)
+	case tea.MouseButtonMiddle:
+		if index >= 0 {
+			return nil, a.closeTab(index)
+		}
+	}
+	return nil, false
+}
+
+// clickStatusBar opens the resource monitor for the meter clicked in the
+// status bar
+func (a *App) clickStatusBar(msg tea.MouseMsg) tea.Cmd {
+	// メーターはバーの最終行、その上は境界線
+	if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft || msg.Y != a.height-1 {
+		return nil
 	}
+	kind, gpu, ok := a.statusBar.MeterAt(msg.X)
+	if !ok {
+		return nil
+	}
+	return a.resourceMonitor.Show(kind, gpu)
 }
 
-// reindexTerminals updates terminal indices after tab changes
-func (a *App) reindexTerminals() {
-	// This is a simplified approach - in production,
-	// we'd need more sophisticated tab/terminal management
+// contentTop returns the screen row where the content area starts
+func (a *App) contentTop() int {
+	return lipgloss.Height(a.tabBar.View())
 }
 
 // calculateContentHeight calculates the content area height
 func (a *App) calculateContentHeight() int {
-	tabBarHeight := 1 // Approximate
-	statusBarHeight := 1 // Approximate
+	tabBarHeight := a.contentTop()
+	statusBarHeight := lipgloss.Height(a.statusBar.View())
 
 	contentHeight := a.height - tabBarHeight - statusBarHeight
 	if contentHeight < 1 {
@@ -400,7 +1031,49 @@ func (a *App) calculateContentHeight() int {
 	return contentHeight
 }
 
+// reloadHistory returns a command that merges in the history of other
+// instances after historyReloadInterval
+func (a *App) reloadHistory() tea.Cmd {
+	h := a.history
+	return tea.Tick(historyReloadInterval, func(time.Time) tea.Msg {
+		_ = h.Reload()
+		return historyReloadMsg{err: h.Err()}
+	})
+}
+
 // AddToHistory adds a command to the history
 func (a *App) AddToHistory(cmd string) {
 	a.history.Add(cmd)
 }
+
+// recordCommand adds a command run in a terminal to the history, with the
+// tab and session it ran in
+func (a *App) recordCommand(msg organisms.CommandFinishedMsg) {
+	c := msg.Command
+	if c.Text == "" {
+		return
+	}
+
+	entry := history.Entry{
+		Command:  c.Text,
+		Time:     c.Start,
+		Dir:      c.Dir,
+		ExitCode: c.ExitCode,
+		Host:     c.Host,
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	} else if !c.End.IsZero() {
+		entry.Duration = c.End.Sub(c.Start)
+	}
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+
+	if tab := a.tabBar.TabByTerminal(msg.TerminalID); tab != nil {
+		entry.Tab = tab.Label()
+		entry.Session = tab.ID
+	}
+
+	a.history.AddEntry(entry)
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"os"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// copyToClipboard returns a command that copies text to the host clipboard.
+// OSC 52 asks the host terminal to do the copy, so it also works over SSH.
+// Inside tmux or screen the sequence is wrapped to pass through them.
+func copyToClipboard(text string) tea.Cmd {
+	return func() tea.Msg {
+		seq := osc52.New(text)
+		switch {
+		case os.Getenv("TMUX") != "":
+			seq = seq.Tmux()
+		case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+			seq = seq.Screen()
+		}
+		_, _ = seq.WriteTo(os.Stderr)
+		return nil
+	}
+}
@@ -10,6 +10,13 @@ type KeyMap struct {
 	CloseTab      key.Binding
 	NextTab       key.Binding
 	PrevTab       key.Binding
+	GoToTab       key.Binding
+	MoveTab       key.Binding
+	RenameTab     key.Binding
+	SplitRight    key.Binding
+	SplitDown     key.Binding
+	FocusPane     key.Binding
+	ResizePane    key.Binding
 	ToggleAI      key.Binding
 	SelectPreset  key.Binding
 	FileBrowser   key.Binding
@@ -18,6 +25,10 @@ type KeyMap struct {
 	GitCommit     key.Binding
 	ClaudeCode    key.Binding
 	ExternalAI    key.Binding
+	ScrollMode    key.Binding
+	Search        key.Binding
+	Paste         key.Binding
+	HistoryStats  key.Binding
 	ShowHelp      key.Binding
 }
 
@@ -44,6 +55,34 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("alt+["),
 			key.WithHelp("alt+[", "前タブ"),
 		),
+		GoToTab: key.NewBinding(
+			key.WithKeys("alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9"),
+			key.WithHelp("alt+1..9", "タブ番号"),
+		),
+		MoveTab: key.NewBinding(
+			key.WithKeys("alt+{", "alt+}"),
+			key.WithHelp("alt+{/}", "タブ移動"),
+		),
+		RenameTab: key.NewBinding(
+			key.WithKeys("alt+n"),
+			key.WithHelp("alt+n", "タブ名変更"),
+		),
+		SplitRight: key.NewBinding(
+			key.WithKeys("alt+\\"),
+			key.WithHelp("alt+\\", "左右に分割"),
+		),
+		SplitDown: key.NewBinding(
+			key.WithKeys("alt+-"),
+			key.WithHelp("alt+-", "上下に分割"),
+		),
+		FocusPane: key.NewBinding(
+			key.WithKeys("alt+up", "alt+down", "alt+left", "alt+right"),
+			key.WithHelp("alt+←↑↓→", "ペイン移動"),
+		),
+		ResizePane: key.NewBinding(
+			key.WithKeys("alt+shift+up", "alt+shift+down", "alt+shift+left", "alt+shift+right"),
+			key.WithHelp("alt+shift+←↑↓→", "ペインサイズ"),
+		),
 		ToggleAI: key.NewBinding(
 			key.WithKeys("alt+a"),
 			key.WithHelp("alt+a", "AI"),
@@ -76,6 +115,22 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("alt+x"),
 			key.WithHelp("alt+x", "外部AI"),
 		),
+		ScrollMode: key.NewBinding(
+			key.WithKeys("alt+v", "shift+pgup"),
+			key.WithHelp("alt+v", "スクロール"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("alt+/"),
+			key.WithHelp("alt+/", "出力を検索"),
+		),
+		Paste: key.NewBinding(
+			key.WithKeys("alt+y"),
+			key.WithHelp("alt+y", "貼り付け"),
+		),
+		HistoryStats: key.NewBinding(
+			key.WithKeys("alt+h"),
+			key.WithHelp("alt+h", "履歴統計"),
+		),
 		ShowHelp: key.NewBinding(
 			key.WithKeys("?"),
 			key.WithHelp("?", "ヘルプ"),
@@ -91,8 +146,11 @@ func (k KeyMap) ShortHelp() []key.Binding {
 // FullHelp returns keybindings for the expanded help view (grouped by category)
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.NewTab, k.CloseTab, k.NextTab, k.PrevTab},             // タブ操作
-		{k.ToggleAI, k.SelectPreset, k.ClaudeCode, k.ExternalAI}, // AI
+		{k.NewTab, k.CloseTab, k.NextTab, k.PrevTab},               // タブ操作
+		{k.GoToTab, k.MoveTab, k.RenameTab},                        // タブ整理
+		{k.SplitRight, k.SplitDown, k.FocusPane, k.ResizePane},     // ペイン操作
+		{k.ToggleAI, k.SelectPreset, k.ClaudeCode, k.ExternalAI},   // AI
 		{k.FileBrowser, k.QuickTransfer, k.APIClient, k.GitCommit}, // ファイル
+		{k.ScrollMode, k.Search, k.Paste, k.HistoryStats},          // ターミナル
 	}
 }
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// syncKeypad returns a command that switches the host terminal's keypad to
+// the mode the focused program asked for, or nil if it already is.
+//
+// Bubbletea cannot tell keypad keys from the main keyboard, so application
+// keypad mode (DECKPAM) is passed on to the host terminal instead. Its
+// keypad sequences (ESC O p and so on) then arrive as Alt+runes, which
+// EncodeKey sends to the program unchanged. The keypad is kept numeric
+// while GoNeSh itself handles the keys, in overlays, scroll mode and search.
+func (a *App) syncKeypad() tea.Cmd {
+	want := false
+	if !a.quitting && a.state == StateTerminal && !a.showHelp && !a.overlayVisible() {
+		if term := a.activeTerminal(); term != nil {
+			want = term.AppKeypad() && !term.IsScrolling() && !term.IsSearching()
+		}
+	}
+	if want == a.hostKeypad {
+		return nil
+	}
+	a.hostKeypad = want
+
+	seq := "\x1b>" // DECKPNM
+	if want {
+		seq = "\x1b=" // DECKPAM
+	}
+	// 終了時はコマンドが実行されない場合があるので、その場で戻す
+	if a.quitting {
+		_, _ = os.Stderr.WriteString(seq)
+		return nil
+	}
+	return func() tea.Msg {
+		_, _ = os.Stderr.WriteString(seq)
+		return nil
+	}
+}
+
+// overlayVisible reports whether a modal takes the keys from the terminal
+func (a *App) overlayVisible() bool {
+	return a.historySearch.IsVisible() || a.pasteConfirm.IsVisible() ||
+		a.tabRename.IsVisible() || a.historyStats.IsVisible() ||
+		a.resourceMonitor.IsVisible()
+}
@@ -0,0 +1,138 @@
+package emulator
+
+import "strings"
+
+// Color represents a terminal color.
+// The zero value is the default color. Indexed and truecolor values carry
+// their kind in the upper byte.
+type Color uint32
+
+const (
+	colorIndexed  Color = 1 << 24
+	colorRGB      Color = 2 << 24
+	colorKindMask Color = 0xff << 24
+)
+
+// DefaultColor is the terminal's default foreground or background color
+const DefaultColor Color = 0
+
+// IndexedColor returns a color from the 256-color palette
+func IndexedColor(index uint8) Color {
+	return colorIndexed | Color(index)
+}
+
+// RGBColor returns a 24-bit truecolor value
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// IsDefault returns whether the color is the default color
+func (c Color) IsDefault() bool {
+	return c == DefaultColor
+}
+
+// Index returns the palette index if the color is an indexed color
+func (c Color) Index() (uint8, bool) {
+	if c&colorKindMask != colorIndexed {
+		return 0, false
+	}
+	return uint8(c), true
+}
+
+// RGB returns the components if the color is a truecolor value
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	if c&colorKindMask != colorRGB {
+		return 0, 0, 0, false
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+}
+
+// AttrFlags holds the boolean graphic rendition attributes
+type AttrFlags uint16
+
+const (
+	AttrBold AttrFlags = 1 << iota
+	AttrFaint
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrStrikethrough
+)
+
+// Attr holds the graphic rendition of a cell
+type Attr struct {
+	FG    Color
+	BG    Color
+	Flags AttrFlags
+}
+
+// Has returns whether all of the given flags are set
+func (a Attr) Has(flags AttrFlags) bool {
+	return a.Flags&flags == flags
+}
+
+// Cell is a single character cell of the screen grid
+type Cell struct {
+	Rune rune
+	// Width is 1 or 2 for a character and 0 for the trailing half of a
+	// wide character.
+	Width uint8
+	Attr
+}
+
+// blankCell returns an empty cell. Erased cells keep the current
+// background color like xterm does.
+func blankCell(attr Attr) Cell {
+	return Cell{Rune: ' ', Width: 1, Attr: Attr{BG: attr.BG}}
+}
+
+// isBlank returns whether the cell is an empty cell without attributes
+func (c Cell) isBlank() bool {
+	return (c.Rune == ' ' || c.Rune == 0) && c.Width == 1 && c.Attr == Attr{}
+}
+
+// Line is a single row of cells
+type Line struct {
+	Cells []Cell
+	// Wrapped is set when the line was soft-wrapped into the next row.
+	Wrapped bool
+}
+
+// newLine creates a blank line of the given width
+func newLine(cols int, attr Attr) Line {
+	cells := make([]Cell, cols)
+	blank := blankCell(attr)
+	for i := range cells {
+		cells[i] = blank
+	}
+	return Line{Cells: cells}
+}
+
+// String returns the text of the line without trailing spaces
+func (l Line) String() string {
+	var sb strings.Builder
+	for _, c := range l.Cells {
+		if c.Width == 0 {
+			continue
+		}
+		if c.Rune == 0 {
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteRune(c.Rune)
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// trimmed returns a copy of the line without trailing blank cells
+func (l Line) trimmed() Line {
+	n := len(l.Cells)
+	for n > 0 && l.Cells[n-1].isBlank() {
+		n--
+	}
+	cells := make([]Cell, n)
+	copy(cells, l.Cells[:n])
+	return Line{Cells: cells, Wrapped: l.Wrapped}
+}
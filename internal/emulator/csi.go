@@ -0,0 +1,265 @@
+package emulator
+
+import "fmt"
+
+// csiDispatch handles a control sequence
+func (e *Emulator) csiDispatch(seq *sequence) {
+	switch seq.private {
+	case '?':
+		e.csiPrivate(seq)
+		return
+	case '>':
+		if seq.final == 'c' { // Secondary DA
+			e.respond("\x1b[>0;10;1c")
+		}
+		return
+	case 0:
+	default:
+		return
+	}
+
+	if len(seq.intermediates) > 0 {
+		if seq.final == 'p' && seq.hasIntermediate('!') { // DECSTR
+			e.softReset()
+		}
+		return
+	}
+
+	switch seq.final {
+	case '@': // ICH
+		e.screen.insertCells(e.x, e.y, seq.count(0), e.attr)
+		e.pendingWrap = false
+	case 'A': // CUU
+		e.cursorUp(seq.count(0))
+	case 'B', 'e': // CUD, VPR
+		e.cursorDown(seq.count(0))
+	case 'C', 'a': // CUF, HPR
+		e.x = clamp(e.x+seq.count(0), 0, e.cols-1)
+		e.pendingWrap = false
+	case 'D': // CUB
+		e.x = clamp(e.x-seq.count(0), 0, e.cols-1)
+		e.pendingWrap = false
+	case 'E': // CNL
+		e.cursorDown(seq.count(0))
+		e.x = 0
+	case 'F': // CPL
+		e.cursorUp(seq.count(0))
+		e.x = 0
+	case 'G', '`': // CHA, HPA
+		e.x = clamp(seq.count(0)-1, 0, e.cols-1)
+		e.pendingWrap = false
+	case 'H', 'f': // CUP, HVP
+		e.moveTo(seq.count(1)-1, seq.count(0)-1)
+	case 'I': // CHT
+		e.tabForward(seq.count(0))
+	case 'J': // ED
+		e.eraseDisplay(seq.param(0, 0))
+	case 'K': // EL
+		e.eraseLine(seq.param(0, 0))
+	case 'L': // IL
+		if e.y >= e.top && e.y <= e.bottom {
+			e.screen.scrollDown(e.y, e.bottom, seq.count(0), e.attr)
+			e.x = 0
+			e.pendingWrap = false
+		}
+	case 'M': // DL
+		if e.y >= e.top && e.y <= e.bottom {
+			e.screen.scrollUp(e.y, e.bottom, seq.count(0), e.attr)
+			e.x = 0
+			e.pendingWrap = false
+		}
+	case 'P': // DCH
+		e.screen.deleteCells(e.x, e.y, seq.count(0), e.attr)
+		e.pendingWrap = false
+	case 'S': // SU
+		e.scrollUp(seq.count(0))
+	case 'T': // SD
+		if len(seq.params) <= 1 {
+			e.scrollDown(seq.count(0))
+		}
+	case 'X': // ECH
+		n := seq.count(0)
+		e.screen.clear(e.x, e.y, min(e.x+n, e.cols), e.y, e.attr)
+		e.pendingWrap = false
+	case 'Z': // CBT
+		e.tabBackward(seq.count(0))
+	case 'b': // REP
+		if e.lastRune != 0 {
+			for i := seq.count(0); i > 0; i-- {
+				e.print(e.lastRune)
+			}
+		}
+	case 'c': // Primary DA
+		if seq.param(0, 0) == 0 {
+			e.respond("\x1b[?62;22c")
+		}
+	case 'd': // VPA
+		e.moveTo(e.x, seq.count(0)-1)
+	case 'g': // TBC
+		switch seq.param(0, 0) {
+		case 0:
+			e.tabStops[e.x] = false
+		case 3:
+			for i := range e.tabStops {
+				e.tabStops[i] = false
+			}
+		}
+	case 'h', 'l': // SM, RM
+		set := seq.final == 'h'
+		for i := range seq.params {
+			switch seq.param(i, 0) {
+			case 4:
+				e.modes.Insert = set
+			case 20:
+				e.modes.NewLine = set
+			}
+		}
+	case 'm': // SGR
+		e.setGraphics(seq)
+	case 'n': // DSR
+		switch seq.param(0, 0) {
+		case 5:
+			e.respond("\x1b[0n")
+		case 6:
+			e.reportCursor("")
+		}
+	case 'r': // DECSTBM
+		top := seq.count(0) - 1
+		bottom := seq.param(1, e.rows)
+		if bottom == 0 || bottom > e.rows {
+			bottom = e.rows
+		}
+		bottom--
+		if top < bottom {
+			e.top = top
+			e.bottom = bottom
+			e.moveTo(0, 0)
+		}
+	case 's': // SCOSC
+		e.saveCursor()
+	case 'u': // SCORC
+		e.restoreCursor()
+	}
+}
+
+// csiPrivate handles DEC private control sequences
+func (e *Emulator) csiPrivate(seq *sequence) {
+	switch seq.final {
+	case 'h', 'l': // DECSET, DECRST
+		set := seq.final == 'h'
+		for i := range seq.params {
+			e.setPrivateMode(seq.param(i, 0), set)
+		}
+	case 'J': // DECSED
+		e.eraseDisplay(seq.param(0, 0))
+	case 'K': // DECSEL
+		e.eraseLine(seq.param(0, 0))
+	case 'n': // DECDSR
+		if seq.param(0, 0) == 6 {
+			e.reportCursor("?")
+		}
+	}
+}
+
+// setPrivateMode sets or resets a DEC private mode
+func (e *Emulator) setPrivateMode(mode int, set bool) {
+	switch mode {
+	case 1: // DECCKM
+		e.modes.AppCursor = set
+	case 6: // DECOM
+		e.modes.Origin = set
+		e.moveTo(0, 0)
+	case 7: // DECAWM
+		e.modes.AutoWrap = set
+		if !set {
+			e.pendingWrap = false
+		}
+	case 25: // DECTCEM
+		e.modes.CursorVisible = set
+	case 66: // DECNKM
+		e.modes.AppKeypad = set
+	case 47: // Alternate screen
+		e.switchScreen(set)
+	case 1047: // Alternate screen, cleared when leaving
+		if !set && e.modes.AltScreen {
+			e.clearScreen()
+		}
+		e.switchScreen(set)
+	case 1048: // Save/restore cursor
+		if set {
+			e.saveCursor()
+		} else {
+			e.restoreCursor()
+		}
+	case 1049: // Save cursor and switch to a cleared alternate screen
+		if set {
+			if !e.modes.AltScreen {
+				e.saveCursor()
+				e.switchScreen(true)
+				e.clearScreen()
+			}
+		} else if e.modes.AltScreen {
+			e.switchScreen(false)
+			e.restoreCursor()
+		}
+	case 2004: // Bracketed paste
+		e.modes.BracketedPaste = set
+	}
+}
+
+// reportCursor replies with the cursor position (CPR)
+func (e *Emulator) reportCursor(prefix string) {
+	y := e.y
+	if e.modes.Origin {
+		y -= e.top
+	}
+	e.respond(fmt.Sprintf("\x1b[%s%d;%dR", prefix, y+1, e.x+1))
+}
+
+// cursorUp moves the cursor up, stopping at the top margin
+func (e *Emulator) cursorUp(n int) {
+	minY := 0
+	if e.y >= e.top {
+		minY = e.top
+	}
+	e.y = clamp(e.y-n, minY, e.rows-1)
+	e.pendingWrap = false
+}
+
+// cursorDown moves the cursor down, stopping at the bottom margin
+func (e *Emulator) cursorDown(n int) {
+	maxY := e.rows - 1
+	if e.y <= e.bottom {
+		maxY = e.bottom
+	}
+	e.y = clamp(e.y+n, 0, maxY)
+	e.pendingWrap = false
+}
+
+// eraseDisplay erases part of the screen (ED)
+func (e *Emulator) eraseDisplay(mode int) {
+	switch mode {
+	case 0: // Cursor to end of screen
+		e.screen.clear(e.x, e.y, e.cols, e.rows-1, e.attr)
+	case 1: // Start of screen to cursor
+		e.screen.clear(0, 0, e.x+1, e.y, e.attr)
+	case 2: // Entire screen
+		e.screen.clear(0, 0, e.cols, e.rows-1, e.attr)
+	case 3: // Scrollback
+		e.scrollback = nil
+	}
+	e.pendingWrap = false
+}
+
+// eraseLine erases part of the cursor line (EL)
+func (e *Emulator) eraseLine(mode int) {
+	switch mode {
+	case 0: // Cursor to end of line
+		e.screen.clear(e.x, e.y, e.cols, e.y, e.attr)
+	case 1: // Start of line to cursor
+		e.screen.clear(0, e.y, e.x+1, e.y, e.attr)
+	case 2: // Entire line
+		e.screen.clear(0, e.y, e.cols, e.y, e.attr)
+	}
+	e.pendingWrap = false
+}
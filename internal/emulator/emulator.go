@@ -0,0 +1,659 @@
+// Package emulator provides a VT100/xterm compatible terminal screen model for GoNeSh.
+// It parses the output of programs running in a PTY into a grid of cells.
+package emulator
+
+import (
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// DefaultMaxScrollback is the default number of scrollback lines
+	DefaultMaxScrollback = 10000
+	// Default tab stop interval
+	tabWidth = 8
+)
+
+// charset is a character set designated to G0/G1
+type charset uint8
+
+const (
+	charsetASCII charset = iota
+	charsetDECSpecial
+)
+
+// Cursor holds the cursor position and visibility
+type Cursor struct {
+	X       int
+	Y       int
+	Visible bool
+}
+
+// Modes holds the terminal modes set by the running program
+type Modes struct {
+	Origin         bool // DECOM
+	AutoWrap       bool // DECAWM
+	Insert         bool // IRM
+	NewLine        bool // LNM
+	CursorVisible  bool // DECTCEM
+	AltScreen      bool // DECSET 47/1047/1049
+	AppCursor      bool // DECCKM
+	AppKeypad      bool // DECKPAM/DECKPNM
+	BracketedPaste bool // DECSET 2004
+}
+
+// savedCursor is the state stored by DECSC
+type savedCursor struct {
+	x, y        int
+	attr        Attr
+	origin      bool
+	pendingWrap bool
+	charsets    [2]charset
+	gl          int
+}
+
+// Emulator is a terminal screen model.
+// It is not safe for concurrent use; callers must serialize access.
+type Emulator struct {
+	parser *parser
+	cols   int
+	rows   int
+
+	// The active screen is either the primary or the alternate screen.
+	// Full-screen programs draw into the alternate screen so that the
+	// primary screen and its scrollback survive untouched.
+	screen  *screen
+	primary *screen
+	alt     *screen
+
+	// Lines scrolled off the top of the screen
+	scrollback    []Line
+	maxScrollback int
+	// Total number of lines ever pushed into the scrollback
+	pushed int
+
+	// Cursor state
+	x           int
+	y           int
+	pendingWrap bool
+	attr        Attr
+	lastRune    rune
+
+	// Scroll region (inclusive)
+	top    int
+	bottom int
+
+	tabStops []bool
+	charsets [2]charset
+	gl       int
+	modes    Modes
+	title    string
+
+	// Working directory reported with OSC 7
+	cwd     string
+	cwdHost string
+
+	// Replies to device queries are written here
+	reply io.Writer
+
+	// Called when the bell rings (BEL)
+	onBell func()
+
+	// Shell commands marked with OSC 133
+	commands  []Command
+	onCommand func(Command)
+	atInput   bool // Between B and C: the shell is reading a command
+}
+
+// New creates a new emulator with the given size
+func New(cols, rows, maxScrollback int) *Emulator {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if maxScrollback < 0 {
+		maxScrollback = DefaultMaxScrollback
+	}
+
+	e := &Emulator{
+		cols:          cols,
+		rows:          rows,
+		maxScrollback: maxScrollback,
+	}
+	e.parser = newParser(e)
+	e.reset()
+	return e
+}
+
+// SetReplyWriter sets the writer that receives replies to device queries,
+// usually the PTY input
+func (e *Emulator) SetReplyWriter(w io.Writer) {
+	e.reply = w
+}
+
+// Write feeds program output to the emulator
+func (e *Emulator) Write(p []byte) (int, error) {
+	e.parser.parse(p)
+	return len(p), nil
+}
+
+// Size returns the screen size in cells
+func (e *Emulator) Size() (cols, rows int) {
+	return e.cols, e.rows
+}
+
+// Cursor returns the cursor state
+func (e *Emulator) Cursor() Cursor {
+	return Cursor{X: e.x, Y: e.y, Visible: e.modes.CursorVisible}
+}
+
+// Modes returns the current terminal modes
+func (e *Emulator) Modes() Modes {
+	return e.modes
+}
+
+// WorkingDir returns the working directory of the shell and the host it
+// runs on, as reported with OSC 7. Both are empty if it was not reported.
+func (e *Emulator) WorkingDir() (dir, host string) {
+	return e.cwd, e.cwdHost
+}
+
+// SetBellFunc sets a function called when the bell rings
+func (e *Emulator) SetBellFunc(fn func()) {
+	e.onBell = fn
+}
+
+// Title returns the window title set with OSC 0/2
+func (e *Emulator) Title() string {
+	return e.title
+}
+
+// Line returns the y-th visible line.
+// The returned cells are shared with the emulator and must not be modified.
+func (e *Emulator) Line(y int) Line {
+	if y < 0 || y >= e.rows {
+		return Line{}
+	}
+	return e.screen.lines[y]
+}
+
+// ScrollbackLen returns the number of lines in the scrollback buffer
+func (e *Emulator) ScrollbackLen() int {
+	if len(e.scrollback) > e.maxScrollback {
+		return e.maxScrollback
+	}
+	return len(e.scrollback)
+}
+
+// ScrollbackLine returns the i-th scrollback line, oldest first
+func (e *Emulator) ScrollbackLine(i int) Line {
+	offset := len(e.scrollback) - e.ScrollbackLen()
+	if i < 0 || i >= e.ScrollbackLen() {
+		return Line{}
+	}
+	return e.scrollback[offset+i]
+}
+
+// BufferLen returns the number of lines in the scrollback plus the screen
+func (e *Emulator) BufferLen() int {
+	return e.ScrollbackLen() + e.rows
+}
+
+// BufferLine returns the i-th line of the scrollback followed by the
+// primary screen, oldest first
+func (e *Emulator) BufferLine(i int) Line {
+	if n := e.ScrollbackLen(); i >= n {
+		y := i - n
+		if y < 0 || y >= e.rows {
+			return Line{}
+		}
+		return e.primary.lines[y]
+	}
+	return e.ScrollbackLine(i)
+}
+
+// TrimmedLines returns the number of lines dropped from the head of the
+// scrollback. Adding it to a buffer index gives a line number that stays
+// the same while new output scrolls the buffer.
+func (e *Emulator) TrimmedLines() int {
+	return e.pushed - e.ScrollbackLen()
+}
+
+// Resize changes the screen size.
+// When the primary screen shrinks, lines above the cursor move into the
+// scrollback; when it grows, they are pulled back. The alternate screen is
+// simply cropped or padded.
+func (e *Emulator) Resize(cols, rows int) {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if cols == e.cols && rows == e.rows {
+		return
+	}
+
+	// While the alternate screen is active, the primary cursor is the one
+	// saved when switching screens
+	cursorY := &e.primary.saved.y
+	if e.screen == e.primary {
+		cursorY = &e.y
+	}
+
+	if rows < e.rows {
+		// Keep the cursor on screen by pushing lines into the scrollback
+		if shift := *cursorY - rows + 1; shift > 0 {
+			e.pushScrollback(e.primary.lines[:shift])
+			e.primary.lines = e.primary.lines[shift:]
+			*cursorY -= shift
+			if cursorY == &e.y {
+				e.primary.saved.y -= shift
+			}
+		}
+	} else if rows > e.rows {
+		// Pull lines back from the scrollback
+		pull := rows - e.rows
+		if n := e.ScrollbackLen(); pull > n {
+			pull = n
+		}
+		if pull > 0 {
+			restored := make([]Line, 0, pull+len(e.primary.lines))
+			for i := e.ScrollbackLen() - pull; i < e.ScrollbackLen(); i++ {
+				restored = append(restored, e.ScrollbackLine(i))
+			}
+			e.scrollback = e.scrollback[:len(e.scrollback)-pull]
+			e.pushed -= pull
+			e.primary.lines = append(restored, e.primary.lines...)
+			*cursorY += pull
+			if cursorY == &e.y {
+				e.primary.saved.y += pull
+			}
+		}
+	}
+
+	for _, s := range []*screen{e.primary, e.alt} {
+		s.resize(cols, rows)
+		s.saved.x = clamp(s.saved.x, 0, cols-1)
+		s.saved.y = clamp(s.saved.y, 0, rows-1)
+	}
+	e.cols = cols
+	e.rows = rows
+
+	e.resetTabStops()
+	e.top = 0
+	e.bottom = rows - 1
+	e.x = clamp(e.x, 0, cols-1)
+	e.y = clamp(e.y, 0, rows-1)
+	e.pendingWrap = false
+}
+
+// reset restores the initial state (RIS)
+func (e *Emulator) reset() {
+	e.primary = newScreen(e.cols, e.rows)
+	e.alt = newScreen(e.cols, e.rows)
+	e.screen = e.primary
+	e.x, e.y = 0, 0
+	e.pendingWrap = false
+	e.attr = Attr{}
+	e.top = 0
+	e.bottom = e.rows - 1
+	e.charsets = [2]charset{}
+	e.gl = 0
+	e.modes = Modes{AutoWrap: true, CursorVisible: true}
+	e.title = ""
+	e.resetTabStops()
+	e.saveCursor()
+}
+
+// softReset restores the default modes (DECSTR)
+func (e *Emulator) softReset() {
+	e.modes.Insert = false
+	e.modes.Origin = false
+	e.modes.AutoWrap = true
+	e.modes.CursorVisible = true
+	e.modes.AppCursor = false
+	e.modes.AppKeypad = false
+	e.attr = Attr{}
+	e.top = 0
+	e.bottom = e.rows - 1
+	e.charsets = [2]charset{}
+	e.gl = 0
+	e.screen.saved = savedCursor{}
+}
+
+// resetTabStops sets a tab stop every eight columns
+func (e *Emulator) resetTabStops() {
+	e.tabStops = make([]bool, e.cols)
+	for i := tabWidth; i < e.cols; i += tabWidth {
+		e.tabStops[i] = true
+	}
+}
+
+// pushScrollback appends lines to the scrollback buffer
+func (e *Emulator) pushScrollback(lines []Line) {
+	if e.maxScrollback == 0 {
+		return
+	}
+	for _, l := range lines {
+		e.scrollback = append(e.scrollback, l.trimmed())
+	}
+	e.pushed += len(lines)
+	// Trim lazily so that every scrolled line does not copy the buffer
+	if len(e.scrollback) > e.maxScrollback+e.maxScrollback/4 {
+		kept := make([]Line, e.maxScrollback)
+		copy(kept, e.scrollback[len(e.scrollback)-e.maxScrollback:])
+		e.scrollback = kept
+	}
+}
+
+// respond writes a reply to a device query
+func (e *Emulator) respond(s string) {
+	if e.reply != nil {
+		_, _ = io.WriteString(e.reply, s)
+	}
+}
+
+// print writes a character at the cursor position
+func (e *Emulator) print(r rune) {
+	if e.charsets[e.gl] == charsetDECSpecial {
+		r = decSpecial(r)
+	}
+
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		// Combining characters are not stored
+		return
+	}
+	if width > e.cols {
+		return
+	}
+
+	if e.pendingWrap {
+		e.wrap()
+	}
+	if width == 2 && e.x == e.cols-1 {
+		// A wide character does not fit at the last column
+		if !e.modes.AutoWrap {
+			return
+		}
+		e.screen.lines[e.y].Cells[e.x] = blankCell(e.attr)
+		e.wrap()
+	}
+
+	if e.modes.Insert {
+		e.screen.insertCells(e.x, e.y, width, e.attr)
+	}
+
+	e.screen.splitWide(e.x, e.y)
+	e.screen.splitWide(e.x+width, e.y)
+	cells := e.screen.lines[e.y].Cells
+	cells[e.x] = Cell{Rune: r, Width: uint8(width), Attr: e.attr}
+	if width == 2 {
+		cells[e.x+1] = Cell{Width: 0, Attr: e.attr}
+	}
+	e.lastRune = r
+
+	if e.x+width >= e.cols {
+		e.x = e.cols - 1
+		e.pendingWrap = e.modes.AutoWrap
+	} else {
+		e.x += width
+	}
+}
+
+// wrap moves the cursor to the start of the next line as a soft wrap
+func (e *Emulator) wrap() {
+	e.screen.lines[e.y].Wrapped = true
+	e.index()
+	e.x = 0
+	e.pendingWrap = false
+}
+
+// execute handles a C0 control character
+func (e *Emulator) execute(b byte) {
+	switch b {
+	case 0x07: // BEL
+		if e.onBell != nil {
+			e.onBell()
+		}
+	case 0x08: // BS
+		if e.x > 0 {
+			e.x--
+		}
+		e.pendingWrap = false
+	case 0x09: // HT
+		e.tabForward(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		e.index()
+		if e.modes.NewLine {
+			e.x = 0
+		}
+		e.pendingWrap = false
+	case 0x0d: // CR
+		e.x = 0
+		e.pendingWrap = false
+	case 0x0e: // SO
+		e.gl = 1
+	case 0x0f: // SI
+		e.gl = 0
+	}
+}
+
+// escDispatch handles an escape sequence
+func (e *Emulator) escDispatch(seq *sequence) {
+	if len(seq.intermediates) > 0 {
+		switch seq.intermediates[0] {
+		case '(', ')':
+			g := 0
+			if seq.intermediates[0] == ')' {
+				g = 1
+			}
+			e.charsets[g] = charsetASCII
+			if seq.final == '0' {
+				e.charsets[g] = charsetDECSpecial
+			}
+		case '#':
+			if seq.final == '8' {
+				e.alignmentTest()
+			}
+		}
+		return
+	}
+
+	switch seq.final {
+	case '7': // DECSC
+		e.saveCursor()
+	case '8': // DECRC
+		e.restoreCursor()
+	case 'D': // IND
+		e.index()
+		e.pendingWrap = false
+	case 'E': // NEL
+		e.index()
+		e.x = 0
+		e.pendingWrap = false
+	case 'H': // HTS
+		e.tabStops[e.x] = true
+	case 'M': // RI
+		e.reverseIndex()
+		e.pendingWrap = false
+	case 'c': // RIS
+		e.reset()
+	case '=': // DECKPAM
+		e.modes.AppKeypad = true
+	case '>': // DECKPNM
+		e.modes.AppKeypad = false
+	}
+}
+
+// oscDispatch handles an operating system command
+func (e *Emulator) oscDispatch(data []byte) {
+	code, arg, _ := strings.Cut(string(data), ";")
+	switch code {
+	case "0", "2":
+		e.title = arg
+	case "7": // Working directory from shell integration
+		e.setWorkingDir(arg)
+	case "133": // Semantic prompt marks from shell integration
+		e.semanticPrompt(arg)
+	}
+}
+
+// dcsDispatch handles a device control string.
+// No DCS sequences are supported; they are parsed only to be skipped.
+func (e *Emulator) dcsDispatch(seq *sequence, data []byte) {}
+
+// index moves the cursor down, scrolling at the bottom margin
+func (e *Emulator) index() {
+	if e.y == e.bottom {
+		e.scrollUp(1)
+	} else if e.y < e.rows-1 {
+		e.y++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling at the top margin
+func (e *Emulator) reverseIndex() {
+	if e.y == e.top {
+		e.scrollDown(1)
+	} else if e.y > 0 {
+		e.y--
+	}
+}
+
+// scrollUp scrolls the scroll region up by n lines.
+// Lines leaving a region that starts at the top of the primary screen go
+// into the scrollback.
+func (e *Emulator) scrollUp(n int) {
+	removed := e.screen.scrollUp(e.top, e.bottom, n, e.attr)
+	if e.top == 0 && e.screen == e.primary {
+		e.pushScrollback(removed)
+	}
+}
+
+// scrollDown scrolls the scroll region down by n lines
+func (e *Emulator) scrollDown(n int) {
+	e.screen.scrollDown(e.top, e.bottom, n, e.attr)
+}
+
+// tabForward moves the cursor to the n-th next tab stop
+func (e *Emulator) tabForward(n int) {
+	for ; n > 0 && e.x < e.cols-1; n-- {
+		e.x++
+		for e.x < e.cols-1 && !e.tabStops[e.x] {
+			e.x++
+		}
+	}
+	e.pendingWrap = false
+}
+
+// tabBackward moves the cursor to the n-th previous tab stop
+func (e *Emulator) tabBackward(n int) {
+	for ; n > 0 && e.x > 0; n-- {
+		e.x--
+		for e.x > 0 && !e.tabStops[e.x] {
+			e.x--
+		}
+	}
+	e.pendingWrap = false
+}
+
+// saveCursor stores the cursor state of the active screen (DECSC)
+func (e *Emulator) saveCursor() {
+	e.screen.saved = savedCursor{
+		x:           e.x,
+		y:           e.y,
+		attr:        e.attr,
+		origin:      e.modes.Origin,
+		pendingWrap: e.pendingWrap,
+		charsets:    e.charsets,
+		gl:          e.gl,
+	}
+}
+
+// restoreCursor restores the cursor state of the active screen (DECRC)
+func (e *Emulator) restoreCursor() {
+	saved := e.screen.saved
+	e.x = clamp(saved.x, 0, e.cols-1)
+	e.y = clamp(saved.y, 0, e.rows-1)
+	e.attr = saved.attr
+	e.modes.Origin = saved.origin
+	e.pendingWrap = saved.pendingWrap
+	e.charsets = saved.charsets
+	e.gl = saved.gl
+}
+
+// switchScreen activates the alternate or the primary screen
+func (e *Emulator) switchScreen(alt bool) {
+	if alt == e.modes.AltScreen {
+		return
+	}
+	e.modes.AltScreen = alt
+	if alt {
+		e.screen = e.alt
+	} else {
+		e.screen = e.primary
+	}
+	e.pendingWrap = false
+}
+
+// clearScreen erases the whole active screen
+func (e *Emulator) clearScreen() {
+	e.screen.clear(0, 0, e.cols, e.rows-1, e.attr)
+}
+
+// moveTo moves the cursor to an absolute position, honoring origin mode
+func (e *Emulator) moveTo(x, y int) {
+	minY, maxY := 0, e.rows-1
+	if e.modes.Origin {
+		y += e.top
+		minY, maxY = e.top, e.bottom
+	}
+	e.x = clamp(x, 0, e.cols-1)
+	e.y = clamp(y, minY, maxY)
+	e.pendingWrap = false
+}
+
+// alignmentTest fills the screen with 'E' (DECALN)
+func (e *Emulator) alignmentTest() {
+	for _, l := range e.screen.lines {
+		for i := range l.Cells {
+			l.Cells[i] = Cell{Rune: 'E', Width: 1}
+		}
+	}
+	e.top = 0
+	e.bottom = e.rows - 1
+	e.moveTo(0, 0)
+}
+
+// decSpecialGraphics maps the DEC special graphics set to Unicode
+var decSpecialGraphics = map[rune]rune{
+	'`': '◆', 'a': '▒', 'b': '␉', 'c': '␌', 'd': '␍', 'e': '␊', 'f': '°', 'g': '±',
+	'h': '␤', 'i': '␋', 'j': '┘', 'k': '┐', 'l': '┌', 'm': '└', 'n': '┼', 'o': '⎺',
+	'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽', 't': '├', 'u': '┤', 'v': '┴', 'w': '┬',
+	'x': '│', 'y': '≤', 'z': '≥', '{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+// decSpecial translates a character in the DEC special graphics set
+func decSpecial(r rune) rune {
+	if mapped, ok := decSpecialGraphics[r]; ok {
+		return mapped
+	}
+	return r
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,330 @@
+package emulator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// screenLines returns the text of every line of the active screen
+func screenLines(e *Emulator) []string {
+	_, rows := e.Size()
+	lines := make([]string, rows)
+	for y := range lines {
+		lines[y] = e.Line(y).String()
+	}
+	return lines
+}
+
+// scrollbackLines returns the text of every scrollback line, oldest first
+func scrollbackLines(e *Emulator) []string {
+	lines := make([]string, e.ScrollbackLen())
+	for i := range lines {
+		lines[i] = e.ScrollbackLine(i).String()
+	}
+	return lines
+}
+
+// fill returns output writing the labels on consecutive rows, without a
+// newline after the last one
+func fill(labels ...string) string {
+	return strings.Join(labels, "\r\n")
+}
+
+func TestScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		lines  []string
+		cursor Cursor
+	}{
+		{
+			name:   "text",
+			input:  "ab\r\ncd",
+			lines:  []string{"ab", "cd", "", ""},
+			cursor: Cursor{X: 2, Y: 1, Visible: true},
+		},
+		{
+			name:   "autowrap is deferred until the next character",
+			input:  "abcdef",
+			lines:  []string{"abcde", "f", "", ""},
+			cursor: Cursor{X: 1, Y: 1, Visible: true},
+		},
+		{
+			name:   "pending wrap is cleared by CR",
+			input:  "abcde\rx",
+			lines:  []string{"xbcde", "", "", ""},
+			cursor: Cursor{X: 1, Y: 0, Visible: true},
+		},
+		{
+			name:   "no autowrap",
+			input:  "\x1b[?7labcdefg",
+			lines:  []string{"abcdg", "", "", ""},
+			cursor: Cursor{X: 4, Y: 0, Visible: true},
+		},
+		{
+			name:   "wide character does not fit at the last column",
+			input:  "abcd日",
+			lines:  []string{"abcd", "日", "", ""},
+			cursor: Cursor{X: 2, Y: 1, Visible: true},
+		},
+		{
+			name:   "cursor position is clamped",
+			input:  "\x1b[9;9Hx",
+			lines:  []string{"", "", "", "    x"},
+			cursor: Cursor{X: 4, Y: 3, Visible: true},
+		},
+		{
+			name:   "erase to end of line",
+			input:  "abcde\x1b[3G\x1b[K",
+			lines:  []string{"ab", "", "", ""},
+			cursor: Cursor{X: 2, Y: 0, Visible: true},
+		},
+		{
+			name:   "erase display below",
+			input:  fill("aaaaa", "bbbbb", "ccccc") + "\x1b[2;3H\x1b[J",
+			lines:  []string{"aaaaa", "bb", "", ""},
+			cursor: Cursor{X: 2, Y: 1, Visible: true},
+		},
+		{
+			name:   "insert and delete characters",
+			input:  "abcde\x1b[2G\x1b[2@\x1b[4G\x1b[P",
+			lines:  []string{"a  c", "", "", ""},
+			cursor: Cursor{X: 3, Y: 0, Visible: true},
+		},
+		{
+			name:   "insert lines inside the scroll region",
+			input:  fill("1", "2", "3", "4") + "\x1b[1;3r\x1b[2H\x1b[L",
+			lines:  []string{"1", "", "2", "4"},
+			cursor: Cursor{X: 0, Y: 1, Visible: true},
+		},
+		{
+			name:   "delete lines inside the scroll region",
+			input:  fill("1", "2", "3", "4") + "\x1b[2;3r\x1b[2H\x1b[M",
+			lines:  []string{"1", "3", "", "4"},
+			cursor: Cursor{X: 0, Y: 1, Visible: true},
+		},
+		{
+			name:   "newline scrolls only the scroll region",
+			input:  fill("1", "2", "3", "4") + "\x1b[2;3r\x1b[3Hx\n",
+			lines:  []string{"1", "x", "", "4"},
+			cursor: Cursor{X: 1, Y: 2, Visible: true},
+		},
+		{
+			name:   "reverse index at the top margin scrolls down",
+			input:  fill("1", "2", "3", "4") + "\x1b[2;3r\x1b[2H\x1bM",
+			lines:  []string{"1", "", "2", "4"},
+			cursor: Cursor{X: 0, Y: 1, Visible: true},
+		},
+		{
+			name:   "scroll up and down",
+			input:  fill("1", "2", "3", "4") + "\x1b[2S\x1b[T",
+			lines:  []string{"", "3", "4", ""},
+			cursor: Cursor{X: 1, Y: 3, Visible: true},
+		},
+		{
+			name:   "invalid scroll region is ignored",
+			input:  fill("1", "2", "3", "4") + "\x1b[3;2r\n",
+			lines:  []string{"2", "3", "4", ""},
+			cursor: Cursor{X: 1, Y: 3, Visible: true},
+		},
+		{
+			name:   "origin mode is relative to the scroll region",
+			input:  "\x1b[2;3r\x1b[?6h\x1b[9;1Hx",
+			lines:  []string{"", "", "x", ""},
+			cursor: Cursor{X: 1, Y: 2, Visible: true},
+		},
+		{
+			name:   "save and restore cursor",
+			input:  "\x1b[2;2H\x1b7\x1b[4;4H\x1b8x",
+			lines:  []string{"", " x", "", ""},
+			cursor: Cursor{X: 2, Y: 1, Visible: true},
+		},
+		{
+			name:   "tab stops",
+			input:  "\tx",
+			lines:  []string{"    x", "", "", ""},
+			cursor: Cursor{X: 4, Y: 0, Visible: true},
+		},
+		{
+			name:   "DEC special graphics",
+			input:  "\x1b(0lqk\x1b(Bq",
+			lines:  []string{"┌─┐q", "", "", ""},
+			cursor: Cursor{X: 4, Y: 0, Visible: true},
+		},
+		{
+			name:   "hidden cursor",
+			input:  "\x1b[?25l",
+			lines:  []string{"", "", "", ""},
+			cursor: Cursor{X: 0, Y: 0, Visible: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(5, 4, 100)
+			_, _ = e.Write([]byte(tt.input))
+			if got := screenLines(e); !slices.Equal(got, tt.lines) {
+				t.Errorf("lines = %q, want %q", got, tt.lines)
+			}
+			if got := e.Cursor(); got != tt.cursor {
+				t.Errorf("cursor = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestScrollback(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		scrollback []string
+		lines      []string
+	}{
+		{
+			name:       "lines scrolled off the top",
+			input:      fill("1", "2", "3", "4", "5"),
+			scrollback: []string{"1", "2"},
+			lines:      []string{"3", "4", "5"},
+		},
+		{
+			name:       "scroll region below the top keeps no scrollback",
+			input:      fill("1", "2", "3") + "\x1b[2;3r\x1b[3H\n\n",
+			scrollback: []string{},
+			lines:      []string{"1", "", ""},
+		},
+		{
+			name:       "scroll region at the top",
+			input:      fill("1", "2", "3") + "\x1b[1;2r\x1b[2H\n",
+			scrollback: []string{"1"},
+			lines:      []string{"2", "", "3"},
+		},
+		{
+			name:       "oldest lines are dropped",
+			input:      fill("1", "2", "3", "4", "5", "6", "7", "8"),
+			scrollback: []string{"2", "3", "4", "5"},
+			lines:      []string{"6", "7", "8"},
+		},
+		{
+			name:       "erase scrollback",
+			input:      fill("1", "2", "3", "4") + "\x1b[3J",
+			scrollback: []string{},
+			lines:      []string{"2", "3", "4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(5, 3, 4)
+			_, _ = e.Write([]byte(tt.input))
+			if got := scrollbackLines(e); !slices.Equal(got, tt.scrollback) {
+				t.Errorf("scrollback = %q, want %q", got, tt.scrollback)
+			}
+			if got := screenLines(e); !slices.Equal(got, tt.lines) {
+				t.Errorf("lines = %q, want %q", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	e := New(5, 3, 100)
+	_, _ = e.Write([]byte(fill("1", "2", "3")))
+
+	// Shrinking pushes the lines above the cursor into the scrollback
+	e.Resize(5, 2)
+	if got, want := scrollbackLines(e), []string{"1"}; !slices.Equal(got, want) {
+		t.Errorf("scrollback after shrinking = %q, want %q", got, want)
+	}
+	if got, want := screenLines(e), []string{"2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("lines after shrinking = %q, want %q", got, want)
+	}
+
+	// Growing pulls them back
+	e.Resize(5, 3)
+	if got := e.ScrollbackLen(); got != 0 {
+		t.Errorf("scrollback after growing has %d lines, want 0", got)
+	}
+	if got, want := screenLines(e), []string{"1", "2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("lines after growing = %q, want %q", got, want)
+	}
+	if got, want := e.Cursor(), (Cursor{X: 1, Y: 2, Visible: true}); got != want {
+		t.Errorf("cursor after growing = %+v, want %+v", got, want)
+	}
+}
+
+func TestAltScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		alt    bool
+		lines  []string
+		cursor Cursor
+	}{
+		{
+			name:   "1049 switches to a cleared alternate screen",
+			input:  fill("1", "2") + "\x1b[?1049h",
+			alt:    true,
+			lines:  []string{"", "", ""},
+			cursor: Cursor{X: 1, Y: 1, Visible: true},
+		},
+		{
+			name:   "1049 restores the primary screen and the cursor",
+			input:  fill("1", "2") + "\x1b[?1049h\x1b[3;3Hvi\x1b[?1049l",
+			lines:  []string{"1", "2", ""},
+			cursor: Cursor{X: 1, Y: 1, Visible: true},
+		},
+		{
+			name:   "1049 set twice keeps the saved cursor",
+			input:  "x\x1b[?1049h\x1b[3;3H\x1b[?1049h\x1b[?1049l",
+			lines:  []string{"x", "", ""},
+			cursor: Cursor{X: 1, Y: 0, Visible: true},
+		},
+		{
+			name:   "47 keeps the alternate screen contents",
+			input:  "\x1b[?47hvi\x1b[?47l\x1b[?47h",
+			alt:    true,
+			lines:  []string{"vi", "", ""},
+			cursor: Cursor{X: 2, Y: 0, Visible: true},
+		},
+		{
+			name:   "1047 clears the alternate screen when leaving",
+			input:  "\x1b[?1047hvi\x1b[?1047l\x1b[?1047h",
+			alt:    true,
+			lines:  []string{"", "", ""},
+			cursor: Cursor{X: 2, Y: 0, Visible: true},
+		},
+		{
+			name:   "each screen saves its own cursor",
+			input:  "\x1b[2;2H\x1b7\x1b[?47h\x1b[3;3H\x1b7\x1b[?47l\x1b8",
+			lines:  []string{"", "", ""},
+			cursor: Cursor{X: 1, Y: 1, Visible: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(5, 3, 100)
+			_, _ = e.Write([]byte(tt.input))
+			if got := e.Modes().AltScreen; got != tt.alt {
+				t.Errorf("AltScreen = %v, want %v", got, tt.alt)
+			}
+			if got := screenLines(e); !slices.Equal(got, tt.lines) {
+				t.Errorf("lines = %q, want %q", got, tt.lines)
+			}
+			if got := e.Cursor(); got != tt.cursor {
+				t.Errorf("cursor = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestAltScreenKeepsScrollback(t *testing.T) {
+	e := New(5, 2, 100)
+	_, _ = e.Write([]byte(fill("1", "2", "3")))
+	_, _ = e.Write([]byte("\x1b[?1049h" + fill("a", "b", "c", "d") + "\x1b[?1049l"))
+
+	if got, want := scrollbackLines(e), []string{"1"}; !slices.Equal(got, want) {
+		t.Errorf("scrollback = %q, want %q", got, want)
+	}
+	if got, want := screenLines(e), []string{"2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
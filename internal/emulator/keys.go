@@ -0,0 +1,167 @@
+package emulator
+
+import (
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Modifier bits as encoded by xterm (the parameter sent is 1 + bits)
+const (
+	modShift = 1
+	modAlt   = 2
+	modCtrl  = 4
+)
+
+// keyKind is the encoding family of a special key
+type keyKind uint8
+
+const (
+	// CSI <final>, or SS3 <final> in application cursor mode
+	keyCursor keyKind = iota
+	// CSI <code> ~
+	keyTilde
+	// SS3 <final> (F1-F4)
+	keySS3
+)
+
+// keyCode describes how a special key is encoded
+type keyCode struct {
+	kind  keyKind
+	final byte
+	code  int
+	mods  int
+}
+
+// keyCodes maps special keys to their xterm encoding
+var keyCodes = map[tea.KeyType]keyCode{
+	tea.KeyUp:    {kind: keyCursor, final: 'A'},
+	tea.KeyDown:  {kind: keyCursor, final: 'B'},
+	tea.KeyRight: {kind: keyCursor, final: 'C'},
+	tea.KeyLeft:  {kind: keyCursor, final: 'D'},
+	tea.KeyHome:  {kind: keyCursor, final: 'H'},
+	tea.KeyEnd:   {kind: keyCursor, final: 'F'},
+
+	tea.KeyShiftUp:    {kind: keyCursor, final: 'A', mods: modShift},
+	tea.KeyShiftDown:  {kind: keyCursor, final: 'B', mods: modShift},
+	tea.KeyShiftRight: {kind: keyCursor, final: 'C', mods: modShift},
+	tea.KeyShiftLeft:  {kind: keyCursor, final: 'D', mods: modShift},
+	tea.KeyShiftHome:  {kind: keyCursor, final: 'H', mods: modShift},
+	tea.KeyShiftEnd:   {kind: keyCursor, final: 'F', mods: modShift},
+
+	tea.KeyCtrlUp:    {kind: keyCursor, final: 'A', mods: modCtrl},
+	tea.KeyCtrlDown:  {kind: keyCursor, final: 'B', mods: modCtrl},
+	tea.KeyCtrlRight: {kind: keyCursor, final: 'C', mods: modCtrl},
+	tea.KeyCtrlLeft:  {kind: keyCursor, final: 'D', mods: modCtrl},
+	tea.KeyCtrlHome:  {kind: keyCursor, final: 'H', mods: modCtrl},
+	tea.KeyCtrlEnd:   {kind: keyCursor, final: 'F', mods: modCtrl},
+
+	tea.KeyCtrlShiftUp:    {kind: keyCursor, final: 'A', mods: modCtrl | modShift},
+	tea.KeyCtrlShiftDown:  {kind: keyCursor, final: 'B', mods: modCtrl | modShift},
+	tea.KeyCtrlShiftRight: {kind: keyCursor, final: 'C', mods: modCtrl | modShift},
+	tea.KeyCtrlShiftLeft:  {kind: keyCursor, final: 'D', mods: modCtrl | modShift},
+	tea.KeyCtrlShiftHome:  {kind: keyCursor, final: 'H', mods: modCtrl | modShift},
+	tea.KeyCtrlShiftEnd:   {kind: keyCursor, final: 'F', mods: modCtrl | modShift},
+
+	tea.KeyInsert:     {kind: keyTilde, code: 2},
+	tea.KeyDelete:     {kind: keyTilde, code: 3},
+	tea.KeyPgUp:       {kind: keyTilde, code: 5},
+	tea.KeyPgDown:     {kind: keyTilde, code: 6},
+	tea.KeyCtrlPgUp:   {kind: keyTilde, code: 5, mods: modCtrl},
+	tea.KeyCtrlPgDown: {kind: keyTilde, code: 6, mods: modCtrl},
+
+	tea.KeyF1:  {kind: keySS3, final: 'P'},
+	tea.KeyF2:  {kind: keySS3, final: 'Q'},
+	tea.KeyF3:  {kind: keySS3, final: 'R'},
+	tea.KeyF4:  {kind: keySS3, final: 'S'},
+	tea.KeyF5:  {kind: keyTilde, code: 15},
+	tea.KeyF6:  {kind: keyTilde, code: 17},
+	tea.KeyF7:  {kind: keyTilde, code: 18},
+	tea.KeyF8:  {kind: keyTilde, code: 19},
+	tea.KeyF9:  {kind: keyTilde, code: 20},
+	tea.KeyF10: {kind: keyTilde, code: 21},
+	tea.KeyF11: {kind: keyTilde, code: 23},
+	tea.KeyF12: {kind: keyTilde, code: 24},
+
+	// xterm reports F13-F20 as Shift+F1-F8
+	tea.KeyF13: {kind: keySS3, final: 'P', mods: modShift},
+	tea.KeyF14: {kind: keySS3, final: 'Q', mods: modShift},
+	tea.KeyF15: {kind: keySS3, final: 'R', mods: modShift},
+	tea.KeyF16: {kind: keySS3, final: 'S', mods: modShift},
+	tea.KeyF17: {kind: keyTilde, code: 15, mods: modShift},
+	tea.KeyF18: {kind: keyTilde, code: 17, mods: modShift},
+	tea.KeyF19: {kind: keyTilde, code: 18, mods: modShift},
+	tea.KeyF20: {kind: keyTilde, code: 19, mods: modShift},
+}
+
+// EncodeKey returns the bytes an xterm sends to the program for a key press.
+// Cursor keys and Home/End follow application cursor mode (DECCKM). Alt is
+// sent as an ESC prefix for characters and as a modifier parameter for
+// special keys.
+//
+// Bubbletea cannot tell keypad keys from the main keyboard, so application
+// keypad mode (DECKPAM) is not applied here: the caller switches the host
+// terminal's keypad instead, and the ESC O sequences it sends arrive as Alt
+// plus a rune, which are passed through unchanged.
+func EncodeKey(k tea.Key, m Modes) []byte {
+	switch k.Type {
+	case tea.KeyRunes:
+		return withAlt([]byte(string(k.Runes)), k.Alt && !k.Paste)
+	case tea.KeySpace:
+		return withAlt([]byte{' '}, k.Alt)
+	case tea.KeyShiftTab:
+		return []byte("\x1b[Z")
+	case tea.KeyEnter:
+		if m.NewLine {
+			return withAlt([]byte("\r\n"), k.Alt)
+		}
+		return withAlt([]byte{'\r'}, k.Alt)
+	}
+
+	if code, ok := keyCodes[k.Type]; ok {
+		mods := code.mods
+		if k.Alt {
+			mods |= modAlt
+		}
+		return code.encode(mods, m.AppCursor)
+	}
+
+	// Control characters (Ctrl+letter, Tab, Esc, Backspace) are their own code
+	if k.Type >= 0 && (k.Type < 0x20 || k.Type == 0x7f) {
+		return withAlt([]byte{byte(k.Type)}, k.Alt)
+	}
+
+	return nil
+}
+
+// encode returns the escape sequence of a special key
+func (c keyCode) encode(mods int, appCursor bool) []byte {
+	switch c.kind {
+	case keyCursor:
+		if mods != 0 {
+			return []byte("\x1b[1;" + strconv.Itoa(mods+1) + string(c.final))
+		}
+		if appCursor {
+			return []byte("\x1bO" + string(c.final))
+		}
+		return []byte("\x1b[" + string(c.final))
+	case keySS3:
+		if mods != 0 {
+			return []byte("\x1b[1;" + strconv.Itoa(mods+1) + string(c.final))
+		}
+		return []byte("\x1bO" + string(c.final))
+	default:
+		if mods != 0 {
+			return []byte("\x1b[" + strconv.Itoa(c.code) + ";" + strconv.Itoa(mods+1) + "~")
+		}
+		return []byte("\x1b[" + strconv.Itoa(c.code) + "~")
+	}
+}
+
+// withAlt prefixes data with ESC when alt is held
+func withAlt(data []byte, alt bool) []byte {
+	if !alt {
+		return data
+	}
+	return append([]byte{0x1b}, data...)
+}
@@ -0,0 +1,334 @@
+package emulator
+
+import "unicode/utf8"
+
+// parserState is a state of the escape sequence parser.
+// The states follow the DEC ANSI parser described at
+// https://vt100.net/emu/dec_ansi_parser
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSIEntry
+	stateCSIParam
+	stateCSIIntermediate
+	stateCSIIgnore
+	stateOSCString
+	stateDCSEntry
+	stateDCSParam
+	stateDCSIntermediate
+	stateDCSPassthrough
+	stateDCSIgnore
+	stateSOSPMAPCString
+)
+
+const (
+	// Maximum number of CSI parameters kept
+	maxParams = 32
+	// Maximum value of a single CSI parameter
+	maxParamValue = 65535
+	// Maximum length of an OSC or DCS payload
+	maxStringLen = 1 << 20
+)
+
+// sequence holds a parsed control sequence
+type sequence struct {
+	private       byte
+	intermediates []byte
+	params        []int  // -1 marks an omitted parameter
+	subs          []bool // params[i] was separated from params[i-1] by ':'
+	final         byte
+}
+
+// param returns the i-th parameter or def if it was omitted
+func (s *sequence) param(i, def int) int {
+	if i >= len(s.params) || s.params[i] < 0 {
+		return def
+	}
+	return s.params[i]
+}
+
+// count returns the i-th parameter as a repeat count (0 means 1)
+func (s *sequence) count(i int) int {
+	n := s.param(i, 1)
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// hasIntermediate returns whether the sequence has the given intermediate
+func (s *sequence) hasIntermediate(b byte) bool {
+	for _, ib := range s.intermediates {
+		if ib == b {
+			return true
+		}
+	}
+	return false
+}
+
+// handler receives the actions produced by the parser
+type handler interface {
+	print(r rune)
+	execute(b byte)
+	escDispatch(seq *sequence)
+	csiDispatch(seq *sequence)
+	oscDispatch(data []byte)
+	dcsDispatch(seq *sequence, data []byte)
+}
+
+// parser turns a byte stream into terminal actions
+type parser struct {
+	h     handler
+	state parserState
+	seq   sequence
+	buf   []byte // OSC/DCS payload
+	utf8  []byte // pending bytes of an incomplete UTF-8 sequence
+}
+
+// newParser creates a parser that reports to h
+func newParser(h handler) *parser {
+	return &parser{h: h}
+}
+
+// parse feeds data to the parser
+func (p *parser) parse(data []byte) {
+	for _, b := range data {
+		p.advance(b)
+	}
+}
+
+// advance feeds a single byte to the parser
+func (p *parser) advance(b byte) {
+	// String states consume everything up to their terminator
+	switch p.state {
+	case stateOSCString:
+		switch b {
+		case 0x07:
+			p.h.oscDispatch(p.buf)
+			p.state = stateGround
+		case 0x1b:
+			p.h.oscDispatch(p.buf)
+			p.enterEscape()
+		case 0x18, 0x1a:
+			p.state = stateGround
+		default:
+			if b >= 0x20 {
+				p.collectString(b)
+			}
+		}
+		return
+	case stateDCSPassthrough, stateDCSIgnore, stateSOSPMAPCString:
+		switch b {
+		case 0x1b:
+			if p.state == stateDCSPassthrough {
+				p.h.dcsDispatch(&p.seq, p.buf)
+			}
+			p.enterEscape()
+		case 0x18, 0x1a:
+			p.state = stateGround
+		default:
+			if p.state == stateDCSPassthrough {
+				p.collectString(b)
+			}
+		}
+		return
+	}
+
+	// An incomplete UTF-8 sequence is interrupted by any non-continuation byte
+	if len(p.utf8) > 0 && (b < 0x80 || b >= 0xc0) {
+		p.utf8 = p.utf8[:0]
+		p.h.print(utf8.RuneError)
+	}
+
+	// C0 controls are handled the same way in all remaining states
+	switch {
+	case b == 0x1b:
+		p.enterEscape()
+		return
+	case b == 0x18 || b == 0x1a:
+		p.state = stateGround
+		return
+	case b < 0x20:
+		p.h.execute(b)
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		switch {
+		case b >= 0x80:
+			p.advanceUTF8(b)
+		case b != 0x7f:
+			p.h.print(rune(b))
+		}
+
+	case stateEscape:
+		switch {
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+			p.state = stateEscapeIntermediate
+		case b == '[':
+			p.state = stateCSIEntry
+		case b == ']':
+			p.buf = p.buf[:0]
+			p.state = stateOSCString
+		case b == 'P':
+			p.state = stateDCSEntry
+		case b == 'X' || b == '^' || b == '_':
+			p.state = stateSOSPMAPCString
+		case b >= 0x30 && b <= 0x7e:
+			p.seq.final = b
+			p.h.escDispatch(&p.seq)
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+		case b >= 0x30 && b <= 0x7e:
+			p.seq.final = b
+			p.h.escDispatch(&p.seq)
+			p.state = stateGround
+		}
+
+	case stateCSIEntry, stateCSIParam:
+		switch {
+		case b >= '0' && b <= '9', b == ';', b == ':':
+			p.collectParam(b)
+			p.state = stateCSIParam
+		case b >= '<' && b <= '?':
+			if p.state == stateCSIEntry {
+				p.seq.private = b
+				p.state = stateCSIParam
+			} else {
+				p.state = stateCSIIgnore
+			}
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+			p.state = stateCSIIntermediate
+		case b >= 0x40 && b <= 0x7e:
+			p.seq.final = b
+			p.h.csiDispatch(&p.seq)
+			p.state = stateGround
+		}
+
+	case stateCSIIntermediate:
+		switch {
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+		case b >= 0x30 && b <= 0x3f:
+			p.state = stateCSIIgnore
+		case b >= 0x40 && b <= 0x7e:
+			p.seq.final = b
+			p.h.csiDispatch(&p.seq)
+			p.state = stateGround
+		}
+
+	case stateCSIIgnore:
+		if b >= 0x40 && b <= 0x7e {
+			p.state = stateGround
+		}
+
+	case stateDCSEntry, stateDCSParam:
+		switch {
+		case b >= '0' && b <= '9', b == ';', b == ':':
+			p.collectParam(b)
+			p.state = stateDCSParam
+		case b >= '<' && b <= '?':
+			if p.state == stateDCSEntry {
+				p.seq.private = b
+				p.state = stateDCSParam
+			} else {
+				p.state = stateDCSIgnore
+			}
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+			p.state = stateDCSIntermediate
+		case b >= 0x40 && b <= 0x7e:
+			p.enterDCSPassthrough(b)
+		}
+
+	case stateDCSIntermediate:
+		switch {
+		case b >= 0x20 && b <= 0x2f:
+			p.seq.intermediates = append(p.seq.intermediates, b)
+		case b >= 0x30 && b <= 0x3f:
+			p.state = stateDCSIgnore
+		case b >= 0x40 && b <= 0x7e:
+			p.enterDCSPassthrough(b)
+		}
+	}
+}
+
+// enterEscape starts a new escape sequence
+func (p *parser) enterEscape() {
+	p.seq.private = 0
+	p.seq.intermediates = p.seq.intermediates[:0]
+	p.seq.params = p.seq.params[:0]
+	p.seq.subs = p.seq.subs[:0]
+	p.seq.final = 0
+	p.utf8 = p.utf8[:0]
+	p.state = stateEscape
+}
+
+// enterDCSPassthrough starts collecting a DCS payload
+func (p *parser) enterDCSPassthrough(final byte) {
+	p.seq.final = final
+	p.buf = p.buf[:0]
+	p.state = stateDCSPassthrough
+}
+
+// collectParam adds a parameter byte to the current sequence
+func (p *parser) collectParam(b byte) {
+	if len(p.seq.params) == 0 {
+		p.seq.params = append(p.seq.params, -1)
+		p.seq.subs = append(p.seq.subs, false)
+	}
+	if b == ';' || b == ':' {
+		if len(p.seq.params) < maxParams {
+			p.seq.params = append(p.seq.params, -1)
+			p.seq.subs = append(p.seq.subs, b == ':')
+		}
+		return
+	}
+
+	last := len(p.seq.params) - 1
+	v := p.seq.params[last]
+	if v < 0 {
+		v = 0
+	}
+	v = v*10 + int(b-'0')
+	if v > maxParamValue {
+		v = maxParamValue
+	}
+	p.seq.params[last] = v
+}
+
+// collectString adds a byte to the OSC/DCS payload
+func (p *parser) collectString(b byte) {
+	if len(p.buf) < maxStringLen {
+		p.buf = append(p.buf, b)
+	}
+}
+
+// advanceUTF8 collects a multi-byte UTF-8 character
+func (p *parser) advanceUTF8(b byte) {
+	if len(p.utf8) == 0 && (b < 0xc0 || b > 0xf4) {
+		// Stray continuation byte or invalid lead byte
+		p.h.print(utf8.RuneError)
+		return
+	}
+
+	p.utf8 = append(p.utf8, b)
+	if !utf8.FullRune(p.utf8) {
+		return
+	}
+
+	r, _ := utf8.DecodeRune(p.utf8)
+	p.utf8 = p.utf8[:0]
+	p.h.print(r)
+}
@@ -0,0 +1,127 @@
+package emulator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recorder is a handler that records the actions of the parser as text
+type recorder struct {
+	actions []string
+}
+
+func (r *recorder) print(c rune) {
+	// Runs of printed characters are recorded as one action
+	if n := len(r.actions); n > 0 && strings.HasPrefix(r.actions[n-1], "print ") {
+		r.actions[n-1] += string(c)
+		return
+	}
+	r.actions = append(r.actions, "print "+string(c))
+}
+
+func (r *recorder) execute(b byte) {
+	r.actions = append(r.actions, fmt.Sprintf("exec %02x", b))
+}
+
+func (r *recorder) escDispatch(seq *sequence) {
+	r.actions = append(r.actions, fmt.Sprintf("esc %s%c", seq.intermediates, seq.final))
+}
+
+func (r *recorder) csiDispatch(seq *sequence) {
+	r.actions = append(r.actions, "csi "+formatSequence(seq))
+}
+
+func (r *recorder) oscDispatch(data []byte) {
+	r.actions = append(r.actions, fmt.Sprintf("osc %s", data))
+}
+
+func (r *recorder) dcsDispatch(seq *sequence, data []byte) {
+	r.actions = append(r.actions, fmt.Sprintf("dcs %s %s", formatSequence(seq), data))
+}
+
+// formatSequence writes the private marker, the parameters (with ':' before
+// sub-parameters and '_' for omitted ones), the intermediates and the final
+func formatSequence(seq *sequence) string {
+	var sb strings.Builder
+	if seq.private != 0 {
+		sb.WriteByte(seq.private)
+	}
+	for i, v := range seq.params {
+		if i > 0 {
+			if seq.subs[i] {
+				sb.WriteByte(':')
+			} else {
+				sb.WriteByte(';')
+			}
+		}
+		if v < 0 {
+			sb.WriteByte('_')
+		} else {
+			fmt.Fprint(&sb, v)
+		}
+	}
+	sb.Write(seq.intermediates)
+	sb.WriteByte(seq.final)
+	return sb.String()
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"text", "hello", []string{"print hello"}},
+		{"controls", "a\r\nb\x07", []string{"print a", "exec 0d", "exec 0a", "print b", "exec 07"}},
+		{"DEL is ignored", "a\x7fb", []string{"print ab"}},
+		{"UTF-8", "日本語", []string{"print 日本語"}},
+		{"invalid UTF-8", "a\xffb", []string{"print a�b"}},
+		{"truncated UTF-8", "\xe6\x97a", []string{"print �a"}},
+		{"ESC", "\x1b7\x1bc", []string{"esc 7", "esc c"}},
+		{"ESC intermediate", "\x1b(0\x1b#8", []string{"esc (0", "esc #8"}},
+		{"CSI without parameters", "\x1b[H", []string{"csi H"}},
+		{"CSI parameters", "\x1b[12;34H", []string{"csi 12;34H"}},
+		{"CSI omitted parameters", "\x1b[;5H\x1b[3;r", []string{"csi _;5H", "csi 3;_r"}},
+		{"CSI private", "\x1b[?1049h", []string{"csi ?1049h"}},
+		{"CSI sub-parameters", "\x1b[38:2::1:2:3m", []string{"csi 38:2:_:1:2:3m"}},
+		{"CSI intermediate", "\x1b[2 q", []string{"csi 2 q"}},
+		{"CSI parameter limit", "\x1b[99999999A", []string{"csi 65535A"}},
+		{"CSI private marker after parameters", "\x1b[1?2hx", []string{"print x"}},
+		{"CSI parameter after intermediate", "\x1b[ 1qx", []string{"print x"}},
+		{"C0 inside CSI", "\x1b[1\n2H", []string{"exec 0a", "csi 12H"}},
+		{"CAN cancels CSI", "\x1b[1\x18Hx", []string{"print Hx"}},
+		{"ESC restarts CSI", "\x1b[1\x1b[2H", []string{"csi 2H"}},
+		{"OSC with BEL", "\x1b]0;title\x07", []string{"osc 0;title"}},
+		{"OSC with ST", "\x1b]2;title\x1b\\", []string{"osc 2;title", "esc \\"}},
+		{"OSC drops controls", "\x1b]0;a\nb\x07", []string{"osc 0;ab"}},
+		{"SUB cancels OSC", "\x1b]0;a\x1ax", []string{"print x"}},
+		{"DCS", "\x1bP1$qm\x1b\\", []string{"dcs 1$q m", "esc \\"}},
+		{"APC is ignored", "\x1b_hidden\x1b\\x", []string{"esc \\", "print x"}},
+		{"sequence split across writes", "\x1b[3", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			newParser(r).parse([]byte(tt.input))
+			if !slices.Equal(r.actions, tt.want) {
+				t.Errorf("parse(%q) = %q, want %q", tt.input, r.actions, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserByteAtATime(t *testing.T) {
+	input := "a\x1b[1;31m日本\x1b]0;t\x07\x1b[?25l"
+	want := []string{"print a", "csi 1;31m", "print 日本", "osc 0;t", "csi ?25l"}
+
+	r := &recorder{}
+	p := newParser(r)
+	for i := 0; i < len(input); i++ {
+		p.parse([]byte{input[i]})
+	}
+	if !slices.Equal(r.actions, want) {
+		t.Errorf("actions = %q, want %q", r.actions, want)
+	}
+}
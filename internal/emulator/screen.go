@@ -0,0 +1,165 @@
+package emulator
+
+// screen is a fixed-size grid of cells
+type screen struct {
+	lines []Line
+	cols  int
+	rows  int
+	// Cursor stored by DECSC; each screen buffer keeps its own
+	saved savedCursor
+}
+
+// newScreen creates a blank screen
+func newScreen(cols, rows int) *screen {
+	s := &screen{cols: cols, rows: rows}
+	s.lines = make([]Line, rows)
+	for i := range s.lines {
+		s.lines[i] = newLine(cols, Attr{})
+	}
+	return s
+}
+
+// resize crops or pads the grid to the given size.
+// Rows are added or removed at the bottom.
+func (s *screen) resize(cols, rows int) {
+	for i := range s.lines {
+		s.lines[i] = resizeLine(s.lines[i], cols)
+	}
+	for len(s.lines) < rows {
+		s.lines = append(s.lines, newLine(cols, Attr{}))
+	}
+	s.lines = s.lines[:rows]
+	s.cols = cols
+	s.rows = rows
+}
+
+// resizeLine crops or pads a line to the given width
+func resizeLine(l Line, cols int) Line {
+	if len(l.Cells) == cols {
+		return l
+	}
+	if len(l.Cells) > cols {
+		l.Cells = l.Cells[:cols]
+		// Do not leave half of a wide character at the edge
+		if cols > 0 && l.Cells[cols-1].Width == 2 {
+			l.Cells[cols-1] = blankCell(l.Cells[cols-1].Attr)
+		}
+		l.Wrapped = false
+		return l
+	}
+	blank := blankCell(Attr{})
+	for len(l.Cells) < cols {
+		l.Cells = append(l.Cells, blank)
+	}
+	return l
+}
+
+// clear erases the cells from (x0, y0) up to but not including (x1, y1),
+// in reading order
+func (s *screen) clear(x0, y0, x1, y1 int, attr Attr) {
+	blank := blankCell(attr)
+	for y := y0; y <= y1 && y < s.rows; y++ {
+		start, end := 0, s.cols
+		if y == y0 {
+			start = x0
+		}
+		if y == y1 {
+			end = x1
+		}
+		s.splitWide(start, y)
+		s.splitWide(end, y)
+		cells := s.lines[y].Cells
+		for x := start; x < end && x < s.cols; x++ {
+			cells[x] = blank
+		}
+		if end >= s.cols {
+			s.lines[y].Wrapped = false
+		}
+	}
+}
+
+// scrollUp moves the lines of the region [top, bottom] up by n and returns
+// the lines that were pushed out at the top
+func (s *screen) scrollUp(top, bottom, n int, attr Attr) []Line {
+	if n > bottom-top+1 {
+		n = bottom - top + 1
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	removed := make([]Line, n)
+	copy(removed, s.lines[top:top+n])
+	copy(s.lines[top:], s.lines[top+n:bottom+1])
+	for y := bottom - n + 1; y <= bottom; y++ {
+		s.lines[y] = newLine(s.cols, attr)
+	}
+	return removed
+}
+
+// scrollDown moves the lines of the region [top, bottom] down by n
+func (s *screen) scrollDown(top, bottom, n int, attr Attr) {
+	if n > bottom-top+1 {
+		n = bottom - top + 1
+	}
+	if n <= 0 {
+		return
+	}
+
+	copy(s.lines[top+n:bottom+1], s.lines[top:bottom+1-n])
+	for y := top; y < top+n; y++ {
+		s.lines[y] = newLine(s.cols, attr)
+	}
+}
+
+// insertCells inserts n blank cells at (x, y), shifting the rest right
+func (s *screen) insertCells(x, y, n int, attr Attr) {
+	cells := s.lines[y].Cells
+	if n > s.cols-x {
+		n = s.cols - x
+	}
+	if n <= 0 {
+		return
+	}
+	s.splitWide(x, y)
+	copy(cells[x+n:], cells[x:s.cols-n])
+	blank := blankCell(attr)
+	for i := x; i < x+n; i++ {
+		cells[i] = blank
+	}
+	if cells[s.cols-1].Width == 2 {
+		cells[s.cols-1] = blank
+	}
+	s.lines[y].Wrapped = false
+}
+
+// deleteCells removes n cells at (x, y), shifting the rest left
+func (s *screen) deleteCells(x, y, n int, attr Attr) {
+	cells := s.lines[y].Cells
+	if n > s.cols-x {
+		n = s.cols - x
+	}
+	if n <= 0 {
+		return
+	}
+	s.splitWide(x, y)
+	s.splitWide(x+n, y)
+	copy(cells[x:], cells[x+n:])
+	blank := blankCell(attr)
+	for i := s.cols - n; i < s.cols; i++ {
+		cells[i] = blank
+	}
+	s.lines[y].Wrapped = false
+}
+
+// splitWide blanks a wide character that would be cut in half at column x
+func (s *screen) splitWide(x, y int) {
+	if x <= 0 || x >= s.cols {
+		return
+	}
+	cells := s.lines[y].Cells
+	if cells[x].Width == 0 {
+		cells[x-1] = blankCell(cells[x-1].Attr)
+		cells[x] = blankCell(cells[x].Attr)
+	}
+}
@@ -0,0 +1,222 @@
+package emulator
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum number of commands remembered for prompt navigation
+const maxCommands = 1000
+
+// Command is a shell command delimited by OSC 133 semantic prompt marks:
+// A at the start of the prompt, B where the command input starts, C when the
+// command starts running and D;<status> when it finishes.
+//
+// Lines are line numbers of the primary screen buffer; line n is
+// BufferLine(n - TrimmedLines()) while it is still in the buffer.
+type Command struct {
+	PromptLine int // A
+	InputLine  int // B
+	InputCol   int
+	OutputLine int // C, -1 until the command runs
+	OutputCol  int
+	EndLine    int // D, -1 until the command finishes
+	EndCol     int
+
+	// Command text as read from the screen between B and C
+	Text     string
+	ExitCode int
+	Finished bool
+
+	// Working directory and host the command ran in (OSC 7)
+	Dir  string
+	Host string
+	// When the command started (C) and finished (D)
+	Start time.Time
+	End   time.Time
+}
+
+// SetCommandFunc sets a function called when a shell command finishes
+func (e *Emulator) SetCommandFunc(fn func(Command)) {
+	e.onCommand = fn
+}
+
+// Commands returns the commands whose prompt is still in the buffer,
+// oldest first
+func (e *Emulator) Commands() []Command {
+	first := e.TrimmedLines()
+	i := 0
+	for i < len(e.commands) && e.commands[i].PromptLine < first {
+		i++
+	}
+	return append([]Command(nil), e.commands[i:]...)
+}
+
+// cursorLine returns the buffer line number of the cursor
+func (e *Emulator) cursorLine() int {
+	return e.pushed + e.y
+}
+
+// semanticPrompt handles an OSC 133 mark.
+// Marks drawn on the alternate screen are ignored.
+func (e *Emulator) semanticPrompt(arg string) {
+	if e.modes.AltScreen {
+		return
+	}
+
+	kind, params, _ := strings.Cut(arg, ";")
+	var cur *Command
+	if n := len(e.commands); n > 0 {
+		cur = &e.commands[n-1]
+	}
+
+	e.atInput = kind == "B" && cur != nil && cur.OutputLine < 0
+	switch kind {
+	case "A":
+		// A prompt without D means the previous command was interrupted
+		// before it reported its status
+		if cur != nil && cur.OutputLine >= 0 && !cur.Finished {
+			e.finishCommand(cur, -1)
+		}
+		line := e.cursorLine()
+		e.commands = append(e.commands, Command{
+			PromptLine: line,
+			InputLine:  line,
+			InputCol:   e.x,
+			OutputLine: -1,
+			EndLine:    -1,
+		})
+		if len(e.commands) > maxCommands {
+			e.commands = append(e.commands[:0], e.commands[len(e.commands)-maxCommands:]...)
+		}
+	case "B":
+		if cur != nil && cur.OutputLine < 0 {
+			cur.InputLine = e.cursorLine()
+			cur.InputCol = e.x
+		}
+	case "C":
+		if cur != nil && cur.OutputLine < 0 {
+			cur.OutputLine = e.cursorLine()
+			cur.OutputCol = e.x
+			cur.Text = e.textBetween(cur.InputLine, cur.InputCol, cur.OutputLine, cur.OutputCol)
+			cur.Dir, cur.Host = e.cwd, e.cwdHost
+			cur.Start = time.Now()
+		}
+	case "D":
+		if cur == nil || cur.Finished || cur.OutputLine < 0 {
+			return
+		}
+		code := 0
+		if s, _, _ := strings.Cut(params, ";"); s != "" {
+			if n, err := strconv.Atoi(s); err == nil {
+				code = n
+			}
+		}
+		e.finishCommand(cur, code)
+	}
+}
+
+// finishCommand records the end of a command and reports it
+func (e *Emulator) finishCommand(c *Command, code int) {
+	c.EndLine = e.cursorLine()
+	c.EndCol = e.x
+	c.ExitCode = code
+	c.Finished = true
+	c.End = time.Now()
+	if e.onCommand != nil {
+		e.onCommand(*c)
+	}
+}
+
+// Input returns the command line typed at the current prompt up to the
+// cursor. ok is false unless the shell is reading a command (after OSC 133
+// B) and nothing is drawn after the cursor.
+func (e *Emulator) Input() (text string, ok bool) {
+	if !e.atInput || e.modes.AltScreen || len(e.commands) == 0 {
+		return "", false
+	}
+	cur := e.commands[len(e.commands)-1]
+	line := e.cursorLine()
+	if line < cur.InputLine || (line == cur.InputLine && e.x < cur.InputCol) {
+		return "", false
+	}
+
+	cells := e.Line(e.y).Cells
+	for x := e.x; x < len(cells); x++ {
+		if cells[x].Rune != 0 && cells[x].Rune != ' ' {
+			return "", false
+		}
+	}
+
+	// Blanks typed before the cursor are part of the input
+	text = e.textBetween(cur.InputLine, cur.InputCol, line, e.x)
+	from := 0
+	if line == cur.InputLine {
+		from = cur.InputCol
+	}
+	blanks := 0
+	for x := min(e.x, len(cells)) - 1; x >= from && (cells[x].Rune == 0 || cells[x].Rune == ' '); x-- {
+		blanks++
+	}
+	return text + strings.Repeat(" ", blanks), true
+}
+
+// setWorkingDir handles an OSC 7 working directory report,
+// file://<host>/<path> with the path percent-encoded
+func (e *Emulator) setWorkingDir(arg string) {
+	rest, ok := strings.CutPrefix(arg, "file://")
+	if !ok {
+		return
+	}
+	host, path := rest, "/"
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if p, err := url.PathUnescape(path); err == nil {
+		path = p
+	}
+	e.cwd = path
+	e.cwdHost = host
+}
+
+// textBetween returns the text of the primary buffer from (line0, col0) up
+// to (line1, col1). Lines wrapped by the terminal are joined and trailing
+// blanks are dropped. Leading blanks are kept, since a command typed with
+// a leading space is not to be recorded.
+func (e *Emulator) textBetween(line0, col0, line1, col1 int) string {
+	first := e.TrimmedLines()
+	var sb strings.Builder
+	for n := max(line0, first); n <= line1; n++ {
+		l := e.BufferLine(n - first)
+		from, to := 0, len(l.Cells)
+		if n == line0 {
+			from = col0
+		}
+		if n == line1 {
+			to = min(col1, to)
+		}
+		var text strings.Builder
+		for x := from; x < to; x++ {
+			c := l.Cells[x]
+			if c.Width == 0 {
+				continue
+			}
+			if c.Rune == 0 {
+				text.WriteByte(' ')
+			} else {
+				text.WriteRune(c.Rune)
+			}
+		}
+		if n < line1 && l.Wrapped {
+			sb.WriteString(text.String())
+			continue
+		}
+		sb.WriteString(strings.TrimRight(text.String(), " "))
+		if n < line1 {
+			sb.WriteByte('\n')
+		}
+	}
+	return strings.TrimRight(sb.String(), " \n")
+}
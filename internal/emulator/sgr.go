@@ -0,0 +1,126 @@
+package emulator
+
+// setGraphics applies a Select Graphic Rendition sequence (SGR)
+func (e *Emulator) setGraphics(seq *sequence) {
+	if len(seq.params) == 0 {
+		e.attr = Attr{}
+		return
+	}
+
+	for i := 0; i < len(seq.params); i++ {
+		p := seq.param(i, 0)
+		switch {
+		case p == 0:
+			e.attr = Attr{}
+		case p == 1:
+			e.attr.Flags |= AttrBold
+		case p == 2:
+			e.attr.Flags |= AttrFaint
+		case p == 3:
+			e.attr.Flags |= AttrItalic
+		case p == 4:
+			// 4:0 turns underline off; other styles are shown as underline
+			style := 1
+			if i+1 < len(seq.params) && seq.subs[i+1] {
+				style = seq.param(i+1, 1)
+				i += seq.skipSubs(i)
+			}
+			if style == 0 {
+				e.attr.Flags &^= AttrUnderline
+			} else {
+				e.attr.Flags |= AttrUnderline
+			}
+		case p == 5 || p == 6:
+			e.attr.Flags |= AttrBlink
+		case p == 7:
+			e.attr.Flags |= AttrReverse
+		case p == 8:
+			e.attr.Flags |= AttrHidden
+		case p == 9:
+			e.attr.Flags |= AttrStrikethrough
+		case p == 21:
+			e.attr.Flags |= AttrUnderline
+		case p == 22:
+			e.attr.Flags &^= AttrBold | AttrFaint
+		case p == 23:
+			e.attr.Flags &^= AttrItalic
+		case p == 24:
+			e.attr.Flags &^= AttrUnderline
+		case p == 25:
+			e.attr.Flags &^= AttrBlink
+		case p == 27:
+			e.attr.Flags &^= AttrReverse
+		case p == 28:
+			e.attr.Flags &^= AttrHidden
+		case p == 29:
+			e.attr.Flags &^= AttrStrikethrough
+		case p >= 30 && p <= 37:
+			e.attr.FG = IndexedColor(uint8(p - 30))
+		case p == 38:
+			c, n := seq.extendedColor(i)
+			e.attr.FG = c
+			i += n
+		case p == 39:
+			e.attr.FG = DefaultColor
+		case p >= 40 && p <= 47:
+			e.attr.BG = IndexedColor(uint8(p - 40))
+		case p == 48:
+			c, n := seq.extendedColor(i)
+			e.attr.BG = c
+			i += n
+		case p == 49:
+			e.attr.BG = DefaultColor
+		case p == 58:
+			// Underline color is not supported; skip its arguments
+			_, n := seq.extendedColor(i)
+			i += n
+		case p >= 90 && p <= 97:
+			e.attr.FG = IndexedColor(uint8(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			e.attr.BG = IndexedColor(uint8(p - 100 + 8))
+		}
+	}
+}
+
+// skipSubs returns the number of ':' sub-parameters following params[i]
+func (s *sequence) skipSubs(i int) int {
+	n := 0
+	for j := i + 1; j < len(s.params) && s.subs[j]; j++ {
+		n++
+	}
+	return n
+}
+
+// extendedColor parses the arguments of SGR 38/48/58 at params[i].
+// Both the "38;5;n" / "38;2;r;g;b" and the "38:5:n" / "38:2::r:g:b" forms
+// are accepted. It returns the color and the number of parameters consumed.
+func (s *sequence) extendedColor(i int) (Color, int) {
+	if n := s.skipSubs(i); n > 0 {
+		sub := make([]int, n)
+		for j := range sub {
+			sub[j] = s.param(i+1+j, 0)
+		}
+		switch {
+		case sub[0] == 5 && n >= 2:
+			return IndexedColor(uint8(sub[1])), n
+		case sub[0] == 2 && n >= 5:
+			// 38:2:<colorspace>:r:g:b
+			return RGBColor(uint8(sub[2]), uint8(sub[3]), uint8(sub[4])), n
+		case sub[0] == 2 && n == 4:
+			return RGBColor(uint8(sub[1]), uint8(sub[2]), uint8(sub[3])), n
+		}
+		return DefaultColor, n
+	}
+
+	switch s.param(i+1, 0) {
+	case 5:
+		if i+2 < len(s.params) {
+			return IndexedColor(uint8(s.param(i+2, 0))), 2
+		}
+	case 2:
+		if i+4 < len(s.params) {
+			return RGBColor(uint8(s.param(i+2, 0)), uint8(s.param(i+3, 0)), uint8(s.param(i+4, 0))), 4
+		}
+	}
+	return DefaultColor, len(s.params) - i - 1
+}
@@ -0,0 +1,206 @@
+package emulator
+
+import "slices"
+
+// Snapshot is a copy of the state of an emulator, including a control
+// sequence it is in the middle of. An emulator restored from a snapshot
+// continues parsing the output where the copied one was, so that a screen
+// can be moved to another process and kept up to date there.
+//
+// Snapshots contain only exported fields, so that they can be encoded with
+// encoding/gob or encoding/json.
+type Snapshot struct {
+	Cols int
+	Rows int
+
+	Primary   ScreenSnapshot
+	Alt       ScreenSnapshot
+	AltActive bool
+
+	Scrollback    []Line
+	MaxScrollback int
+	Pushed        int
+
+	CursorX     int
+	CursorY     int
+	PendingWrap bool
+	Attr        Attr
+	LastRune    rune
+
+	Top    int
+	Bottom int
+
+	TabStops []bool
+	Charsets [2]uint8
+	GL       int
+	Modes    Modes
+	Title    string
+	Dir      string
+	Host     string
+
+	Commands []Command
+	AtInput  bool
+
+	Parser ParserSnapshot
+}
+
+// ScreenSnapshot is a copy of a screen buffer and its saved cursor
+type ScreenSnapshot struct {
+	Lines []Line
+	Saved SavedCursor
+}
+
+// SavedCursor is a copy of the cursor state stored by DECSC
+type SavedCursor struct {
+	X, Y        int
+	Attr        Attr
+	Origin      bool
+	PendingWrap bool
+	Charsets    [2]uint8
+	GL          int
+}
+
+// ParserSnapshot is a copy of the state of the escape sequence parser
+type ParserSnapshot struct {
+	State         uint8
+	Private       byte
+	Intermediates []byte
+	Params        []int
+	Subs          []bool
+	Final         byte
+	Buf           []byte
+	UTF8          []byte
+}
+
+// Snapshot returns a copy of the state of the emulator
+func (e *Emulator) Snapshot() Snapshot {
+	p := e.parser
+	return Snapshot{
+		Cols:          e.cols,
+		Rows:          e.rows,
+		Primary:       e.primary.snapshot(),
+		Alt:           e.alt.snapshot(),
+		AltActive:     e.screen == e.alt,
+		Scrollback:    cloneLines(e.scrollback),
+		MaxScrollback: e.maxScrollback,
+		Pushed:        e.pushed,
+		CursorX:       e.x,
+		CursorY:       e.y,
+		PendingWrap:   e.pendingWrap,
+		Attr:          e.attr,
+		LastRune:      e.lastRune,
+		Top:           e.top,
+		Bottom:        e.bottom,
+		TabStops:      slices.Clone(e.tabStops),
+		Charsets:      [2]uint8{uint8(e.charsets[0]), uint8(e.charsets[1])},
+		GL:            e.gl,
+		Modes:         e.modes,
+		Title:         e.title,
+		Dir:           e.cwd,
+		Host:          e.cwdHost,
+		Commands:      slices.Clone(e.commands),
+		AtInput:       e.atInput,
+		Parser: ParserSnapshot{
+			State:         uint8(p.state),
+			Private:       p.seq.private,
+			Intermediates: slices.Clone(p.seq.intermediates),
+			Params:        slices.Clone(p.seq.params),
+			Subs:          slices.Clone(p.seq.subs),
+			Final:         p.seq.final,
+			Buf:           slices.Clone(p.buf),
+			UTF8:          slices.Clone(p.utf8),
+		},
+	}
+}
+
+// Restore replaces the state of the emulator with a snapshot. The reply
+// writer and the callbacks of the emulator are kept.
+func (e *Emulator) Restore(s Snapshot) {
+	e.cols = max(s.Cols, 1)
+	e.rows = max(s.Rows, 1)
+	e.primary = restoreScreen(s.Primary, e.cols, e.rows)
+	e.alt = restoreScreen(s.Alt, e.cols, e.rows)
+	e.screen = e.primary
+	if s.AltActive {
+		e.screen = e.alt
+	}
+	e.scrollback = cloneLines(s.Scrollback)
+	e.maxScrollback = s.MaxScrollback
+	e.pushed = s.Pushed
+
+	e.x = min(max(s.CursorX, 0), e.cols-1)
+	e.y = min(max(s.CursorY, 0), e.rows-1)
+	e.pendingWrap = s.PendingWrap
+	e.attr = s.Attr
+	e.lastRune = s.LastRune
+	e.top = min(max(s.Top, 0), e.rows-1)
+	e.bottom = min(max(s.Bottom, e.top), e.rows-1)
+
+	e.tabStops = slices.Clone(s.TabStops)
+	if len(e.tabStops) != e.cols {
+		e.resetTabStops()
+	}
+	e.charsets = [2]charset{charset(s.Charsets[0]), charset(s.Charsets[1])}
+	e.gl = s.GL
+	e.modes = s.Modes
+	e.title = s.Title
+	e.cwd = s.Dir
+	e.cwdHost = s.Host
+	e.commands = slices.Clone(s.Commands)
+	e.atInput = s.AtInput
+
+	p := e.parser
+	p.state = parserState(s.Parser.State)
+	p.seq = sequence{
+		private:       s.Parser.Private,
+		intermediates: slices.Clone(s.Parser.Intermediates),
+		params:        slices.Clone(s.Parser.Params),
+		subs:          slices.Clone(s.Parser.Subs),
+		final:         s.Parser.Final,
+	}
+	p.buf = slices.Clone(s.Parser.Buf)
+	p.utf8 = slices.Clone(s.Parser.UTF8)
+}
+
+// snapshot returns a copy of the screen
+func (s *screen) snapshot() ScreenSnapshot {
+	c := s.saved
+	return ScreenSnapshot{
+		Lines: cloneLines(s.lines),
+		Saved: SavedCursor{
+			X:           c.x,
+			Y:           c.y,
+			Attr:        c.attr,
+			Origin:      c.origin,
+			PendingWrap: c.pendingWrap,
+			Charsets:    [2]uint8{uint8(c.charsets[0]), uint8(c.charsets[1])},
+			GL:          c.gl,
+		},
+	}
+}
+
+// restoreScreen creates a screen of the given size from a snapshot
+func restoreScreen(s ScreenSnapshot, cols, rows int) *screen {
+	scr := &screen{lines: cloneLines(s.Lines), cols: cols, rows: rows}
+	scr.resize(cols, rows)
+	c := s.Saved
+	scr.saved = savedCursor{
+		x:           c.X,
+		y:           c.Y,
+		attr:        c.Attr,
+		origin:      c.Origin,
+		pendingWrap: c.PendingWrap,
+		charsets:    [2]charset{charset(c.Charsets[0]), charset(c.Charsets[1])},
+		gl:          c.GL,
+	}
+	return scr
+}
+
+// cloneLines returns a deep copy of lines
+func cloneLines(lines []Line) []Line {
+	out := make([]Line, len(lines))
+	for i, l := range lines {
+		out[i] = Line{Cells: slices.Clone(l.Cells), Wrapped: l.Wrapped}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+)
+
+// encodeSnapshot returns the gob encoding of the state of an emulator.
+// Comparing encodings ignores the difference between nil and empty slices.
+// The times of commands are left out, since they differ between runs.
+func encodeSnapshot(t *testing.T, e *Emulator) []byte {
+	t.Helper()
+	s := e.Snapshot()
+	for i := range s.Commands {
+		s.Commands[i].Start = time.Time{}
+		s.Commands[i].End = time.Time{}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatalf("encoding the snapshot: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	output := []byte("" +
+		"\x1b]0;title\x07\x1b]7;file://host/tmp\x07" +
+		"\x1b]133;A\x07$ \x1b]133;B\x07ls\r\n\x1b]133;C\x07" +
+		"1\r\n2\r\n3\r\n4\r\n5\r\n" +
+		"\x1b[1;38;2;10;20;30m日本語\x1b[0m\x1b(0q\x1b(B\tx" +
+		"\x1b]133;D;0\x07" +
+		"\x1b[2;4r\x1b[?6h\x1b[2;2H\x1b7\x1b[?1h\x1b=\x1b[?2004h" +
+		"\x1b[?1049h\x1b[3;1Hvi\x1b[?25l" +
+		"\x1b[?1049l\x1b[?6l\x1b[r\x1b[4;5Hend")
+
+	want := New(10, 4, 3)
+	_, _ = want.Write(output)
+
+	// Split the output at every byte, so that snapshots are taken in the
+	// middle of control sequences and UTF-8 characters as well
+	for i := range output {
+		e := New(10, 4, 3)
+		_, _ = e.Write(output[:i])
+
+		var s Snapshot
+		if err := gob.NewDecoder(bytes.NewReader(encodeSnapshot(t, e))).Decode(&s); err != nil {
+			t.Fatalf("split at %d: decoding the snapshot: %v", i, err)
+		}
+		restored := New(1, 1, 0)
+		restored.Restore(s)
+		_, _ = restored.Write(output[i:])
+
+		if !bytes.Equal(encodeSnapshot(t, restored), encodeSnapshot(t, want)) {
+			t.Fatalf("split at %d (%q|%q): restored emulator differs\nlines %q, want %q",
+				i, output[:i], output[i:], screenLines(restored), screenLines(want))
+		}
+	}
+}
+
+func TestRestoreKeepsCallbacks(t *testing.T) {
+	src := New(10, 3, 100)
+	_, _ = src.Write([]byte("\x1b]133;A\x07$ \x1b]133;B\x07true\r\n\x1b]133;C\x07"))
+
+	var replies bytes.Buffer
+	var finished []Command
+	e := New(1, 1, 0)
+	e.SetReplyWriter(&replies)
+	e.SetCommandFunc(func(c Command) { finished = append(finished, c) })
+	e.Restore(src.Snapshot())
+
+	_, _ = e.Write([]byte("\x1b]133;D;1\x07\x1b[6n"))
+	if len(finished) != 1 || finished[0].ExitCode != 1 {
+		t.Errorf("finished commands = %+v, want one with exit status 1", finished)
+	}
+	if got, want := replies.String(), "\x1b[2;1R"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestRestoreClampsInvalidSnapshots(t *testing.T) {
+	e := New(1, 1, 0)
+	e.Restore(Snapshot{Cols: 4, Rows: 2, CursorX: 10, CursorY: -3, Top: 5, Bottom: 1})
+
+	if got, want := e.Cursor(), (Cursor{X: 3, Y: 0}); got != want {
+		t.Errorf("cursor = %+v, want %+v", got, want)
+	}
+	// The emulator must stay usable
+	_, _ = e.Write([]byte("\r\nabcdef"))
+	if got := screenLines(e); len(got) != 2 {
+		t.Errorf("lines = %q, want 2 lines", got)
+	}
+}
@@ -49,6 +49,13 @@ const (
 	E6002 ErrorCode = "E6002" // No changes to commit
 	E6003 ErrorCode = "E6003" // Git command failed
 
+	// E7xxx: Session errors
+	E7001 ErrorCode = "E7001" // Invalid session name
+	E7002 ErrorCode = "E7002" // Session file parse error
+	E7003 ErrorCode = "E7003" // Session save failed
+	E7004 ErrorCode = "E7004" // Server connection failed
+	E7005 ErrorCode = "E7005" // Server start failed
+
 	// E9xxx: System errors
 	E9001 ErrorCode = "E9001" // File system error
 	E9002 ErrorCode = "E9002" // Process execution error
@@ -90,6 +97,12 @@ var errorMessages = map[ErrorCode]string{
 	E6002: "コミットする変更がありません",
 	E6003: "Gitコマンドの実行に失敗しました",
 
+	E7001: "無効なセッション名です",
+	E7002: "セッションファイルの読み込みに失敗しました",
+	E7003: "セッションの保存に失敗しました",
+	E7004: "GoNeSh サーバーに接続できません",
+	E7005: "GoNeSh サーバーの起動に失敗しました",
+
 	E9001: "ファイルシステムエラーが発生しました",
 	E9002: "プロセス実行エラーが発生しました",
 	E9003: "リソース監視エラーが発生しました",
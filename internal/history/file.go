@@ -0,0 +1,199 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FormatVersion is the version of the history file format written by this
+// version of GoNeSh
+const FormatVersion = 1
+
+// formatName identifies a GoNeSh history file in its header
+const formatName = "gonesh-history"
+
+// Longest line read from a history file
+const maxLineSize = 1024 * 1024
+
+// The history file is JSON Lines: a header line followed by one entry per
+// line, oldest first. Entries are only ever appended; the file is rewritten
+// when it has grown past the maximum number of entries. GoNeSh instances
+// share the file and change it only while holding its lock (see lockFile).
+//
+//	{"format":"gonesh-history","version":1}
+//	{"cmd":"make test","time":"2025-01-02T15:04:05+09:00","dir":"/src","exit":0,...}
+type fileHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// parseFile parses history file data. The data starts with the header if
+// it is read from the start of the file. Only complete lines are parsed and
+// n is the number of bytes they take, so that a line still being written
+// is read later. Lines that cannot be parsed, such as a line cut short by
+// a crash, are skipped. lines is the number of entry lines.
+func parseFile(data []byte, header bool) (entries []Entry, lines, n int, err error) {
+	for {
+		i := bytes.IndexByte(data[n:], '\n')
+		if i < 0 {
+			return entries, lines, n, nil
+		}
+		line := bytes.TrimSpace(data[n : n+i])
+		n += i + 1
+		if len(line) == 0 {
+			continue
+		}
+		if header {
+			header = false
+			var h fileHeader
+			if err := json.Unmarshal(line, &h); err != nil || h.Format != formatName {
+				return nil, 0, 0, fmt.Errorf("history: not a history file")
+			}
+			if h.Version > FormatVersion {
+				return nil, 0, 0, fmt.Errorf("history: unsupported format version %d", h.Version)
+			}
+			continue
+		}
+
+		lines++
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Command == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// readLegacyFile reads the plain-text history file of older versions,
+// one command per line
+func readLegacyFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			entries = append(entries, Entry{Command: line, ExitCode: -1})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// writeFile replaces the history file with the given entries
+func writeFile(path string, entries []Entry) error {
+	return replaceFile(path, func(writer *bufio.Writer) error {
+		if err := writeHeader(writer); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := writeLine(writer, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeLegacyFile replaces the plain-text history file of older versions
+// with the given commands
+func writeLegacyFile(path string, commands []string) error {
+	return replaceFile(path, func(writer *bufio.Writer) error {
+		for _, command := range commands {
+			if _, err := writer.WriteString(command + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceFile replaces a file with what write writes.
+// The file is written next to it and renamed over it, so that a crash
+// leaves either the old or the new file.
+func replaceFile(path string, write func(*bufio.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	if err := write(writer); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendFile appends entries to the history file, creating it if needed.
+// It returns the file as it is after the write.
+func appendFile(path string, entries []Entry) (os.FileInfo, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if info.Size() == 0 {
+		if err := writeHeader(&buf); err != nil {
+			return nil, err
+		}
+	} else {
+		// Start a new line if the last one was cut short
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			return nil, err
+		}
+		if last[0] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	for _, entry := range entries {
+		if err := writeLine(&buf, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return file.Stat()
+}
+
+// writeHeader writes the header line of a history file
+func writeHeader(w io.Writer) error {
+	return writeLine(w, fileHeader{Format: formatName, Version: FormatVersion})
+}
+
+// writeLine writes v as a line of JSON
+func writeLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
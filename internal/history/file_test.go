@@ -0,0 +1,242 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testHeader = `{"format":"gonesh-history","version":1}` + "\n"
+
+// commands returns the commands of entries
+func commands(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Command
+	}
+	return out
+}
+
+// newTestHistory returns a history whose file is in a new home directory
+func newTestHistory(t *testing.T) (*History, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".gonesh")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return New(100), dir
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		header   bool
+		commands []string
+		lines    int
+		rest     string // left unread
+		err      bool
+	}{
+		{
+			name:     "entries after the header",
+			data:     testHeader + `{"cmd":"ls","exit":0}` + "\n" + `{"cmd":"pwd","exit":1}` + "\n",
+			header:   true,
+			commands: []string{"ls", "pwd"},
+			lines:    2,
+		},
+		{
+			name:     "entries without the header",
+			data:     `{"cmd":"ls","exit":0}` + "\n",
+			commands: []string{"ls"},
+			lines:    1,
+		},
+		{
+			name:     "a line still being written is left for later",
+			data:     `{"cmd":"ls","exit":0}` + "\n" + `{"cmd":"pw`,
+			commands: []string{"ls"},
+			lines:    1,
+			rest:     `{"cmd":"pw`,
+		},
+		{
+			name:     "a line cut short by a crash is skipped",
+			data:     `{"cmd":"ls","ex` + "\n" + `{"cmd":"pwd","exit":0}` + "\n",
+			commands: []string{"pwd"},
+			lines:    2,
+		},
+		{
+			name:     "entries without a command are skipped",
+			data:     `{"exit":0}` + "\n" + `{"cmd":"","exit":0}` + "\n",
+			commands: []string{},
+			lines:    2,
+		},
+		{
+			name:     "blank lines are skipped",
+			data:     "\n" + testHeader + "\n" + `{"cmd":"ls","exit":0}` + "\n",
+			header:   true,
+			commands: []string{"ls"},
+			lines:    1,
+		},
+		{
+			name:   "not a history file",
+			data:   "ls\npwd\n",
+			header: true,
+			err:    true,
+		},
+		{
+			name:   "newer format version",
+			data:   `{"format":"gonesh-history","version":2}` + "\n",
+			header: true,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, lines, n, err := parseFile([]byte(tt.data), tt.header)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if got := commands(entries); !slices.Equal(got, tt.commands) {
+				t.Errorf("commands = %q, want %q", got, tt.commands)
+			}
+			if lines != tt.lines {
+				t.Errorf("lines = %d, want %d", lines, tt.lines)
+			}
+			if rest := tt.data[n:]; rest != tt.rest {
+				t.Errorf("left unread %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestAppendAfterTruncatedLine(t *testing.T) {
+	h, dir := newTestHistory(t)
+	path := filepath.Join(dir, HistoryFileName)
+	data := testHeader + `{"cmd":"ls","exit":0}` + "\n" + `{"cmd":"rm -r`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+	h.Add("pwd")
+
+	// The new entry starts on a line of its own
+	reloaded := New(100)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(reloaded.Entries()), []string{"ls", "pwd"}; !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestLoadNewerFormat(t *testing.T) {
+	h, dir := newTestHistory(t)
+	path := filepath.Join(dir, HistoryFileName)
+	data := `{"format":"gonesh-history","version":2}` + "\n" + `{"cmd":"ls","new":true}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Load(); err == nil {
+		t.Error("Load succeeded on a newer format")
+	}
+	h.Add("pwd")
+	if err := h.Save(); err == nil {
+		t.Error("Save succeeded on a newer format")
+	}
+	if _, err := h.Import([]Entry{{Command: "make"}}); err == nil {
+		t.Error("Import succeeded on a newer format")
+	}
+	if h.Err() == nil {
+		t.Error("Err() = nil, want the format error")
+	}
+
+	// The file is left alone, but the history still works in memory
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("file = %q, want it unchanged", got)
+	}
+	if got, want := commands(h.Entries()), []string{"pwd"}; !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	// The file is used again once it can be read
+	if err := os.WriteFile(path, []byte(testHeader+`{"cmd":"ls","exit":0}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.Add("make")
+	if err := h.Err(); err != nil {
+		t.Errorf("Err() = %v after the file was replaced", err)
+	}
+	reloaded := New(100)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(reloaded.Entries()), []string{"ls", "make"}; !slices.Equal(got, want) {
+		t.Errorf("commands in the file = %q, want %q", got, want)
+	}
+}
+
+func TestMigrateLegacyFile(t *testing.T) {
+	h, dir := newTestHistory(t)
+	legacy := filepath.Join(dir, LegacyFileName)
+	if err := os.WriteFile(legacy, []byte("ls\n\ncd /tmp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(h.Entries()), []string{"ls", "cd /tmp"}; !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, HistoryFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), testHeader) {
+		t.Errorf("history file starts with %q, want the header", data)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Errorf("legacy file was not kept: %v", err)
+	}
+}
+
+func TestCompactOnSave(t *testing.T) {
+	h, dir := newTestHistory(t)
+	h.maxEntries = 3
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"a", "b", "c", "d", "e"} {
+		h.Add(c)
+	}
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, HistoryFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, _, err := parseFile(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(entries), []string{"c", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("commands in the file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package history
+
+// Scope is the part of the history a search looks at
+type Scope int
+
+const (
+	// ScopeGlobal searches all commands
+	ScopeGlobal Scope = iota
+	// ScopeDir searches commands run in the current directory
+	ScopeDir
+	// ScopeHost searches commands run on the current host
+	ScopeHost
+	// ScopeSuccess searches commands that succeeded
+	ScopeSuccess
+	// ScopeSession searches commands run in the current session
+	ScopeSession
+
+	scopeCount
+)
+
+// String returns the name of the scope
+func (s Scope) String() string {
+	switch s {
+	case ScopeDir:
+		return "directory"
+	case ScopeHost:
+		return "host"
+	case ScopeSuccess:
+		return "success"
+	case ScopeSession:
+		return "session"
+	default:
+		return "global"
+	}
+}
+
+// Next returns the scope after s, wrapping around
+func (s Scope) Next() Scope {
+	return (s + 1) % scopeCount
+}
+
+// Prev returns the scope before s, wrapping around
+func (s Scope) Prev() Scope {
+	return (s + scopeCount - 1) % scopeCount
+}
+
+// Filter selects the entries of a scope. Dir, Host and Session describe
+// where the search is made from.
+type Filter struct {
+	Scope   Scope
+	Dir     string
+	Host    string
+	Session string
+}
+
+// Match returns whether an entry is in the scope of the filter
+func (f Filter) Match(e Entry) bool {
+	switch f.Scope {
+	case ScopeDir:
+		return e.Dir != "" && e.Dir == f.Dir
+	case ScopeHost:
+		return e.Host != "" && e.Host == f.Host
+	case ScopeSuccess:
+		return e.ExitCode == 0
+	case ScopeSession:
+		return e.Session != "" && e.Session == f.Session
+	default:
+		return true
+	}
+}
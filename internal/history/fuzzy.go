@@ -0,0 +1,165 @@
+package history
+
+import (
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Scores of fuzzy matches, after fzf
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	// Matching at the start of a word, e.g. "gc" in "git commit"
+	bonusBoundary = 8
+	// Matching right after the previous match
+	bonusConsecutive = 4
+	// Weight of the most recent use and of the number of uses
+	bonusRecency   = 32
+	bonusFrequency = 6
+)
+
+// Match is a history search result: a command with its most recent entry
+type Match struct {
+	Entry Entry
+	Count int // Times the command was run
+	Score int
+	// Indexes of the runes of the command that matched the query
+	Positions []int
+}
+
+// Search returns the commands in the scope of filter that match the query,
+// best first. The query matches as a subsequence, like fzf; it ignores case
+// unless it has upper case letters. Matches are ranked by how well they
+// match, then how recently and how often they ran. An empty query returns
+// every command, newest first.
+func (h *History) Search(query string, filter Filter) []Match {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	pattern := []rune(query)
+	ignoreCase := !strings.ContainsFunc(query, unicode.IsUpper)
+
+	// One match per command, from the newest entry
+	seen := make(map[string]int)
+	var matches []Match
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entry := h.entries[i]
+		if !filter.Match(entry) {
+			continue
+		}
+		if j, ok := seen[entry.Command]; ok {
+			if j >= 0 {
+				matches[j].Count++
+			}
+			continue
+		}
+		seen[entry.Command] = len(matches)
+
+		score, positions, ok := fuzzyMatch([]rune(entry.Command), pattern, ignoreCase)
+		if !ok {
+			seen[entry.Command] = -1
+			continue
+		}
+		recency := 0
+		if len(h.entries) > 1 {
+			recency = bonusRecency * i / (len(h.entries) - 1)
+		}
+		matches = append(matches, Match{
+			Entry:     entry,
+			Count:     1,
+			Score:     score + recency,
+			Positions: positions,
+		})
+	}
+	if len(pattern) == 0 {
+		return matches
+	}
+
+	for i := range matches {
+		matches[i].Score += bonusFrequency * bits.Len(uint(matches[i].Count))
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return b.Score - a.Score
+	})
+	return matches
+}
+
+// fuzzyMatch matches pattern against text as a subsequence. It returns the
+// score of the match and the indexes of the runes of text that matched.
+// The shortest match ending at the first complete occurrence is scored.
+func fuzzyMatch(text, pattern []rune, ignoreCase bool) (int, []int, bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+	eq := func(a, b rune) bool {
+		if ignoreCase {
+			return unicode.ToLower(a) == b
+		}
+		return a == b
+	}
+	if ignoreCase {
+		pattern = []rune(strings.ToLower(string(pattern)))
+	}
+
+	// Forward to where the first occurrence ends
+	end, p := -1, 0
+	for i, r := range text {
+		if eq(r, pattern[p]) {
+			p++
+			if p == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Backward to where the shortest occurrence starts
+	start, p := end, len(pattern)-1
+	for i := end; i >= 0; i-- {
+		if eq(text[i], pattern[p]) {
+			p--
+			if p < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(pattern))
+	score := 0
+	p = 0
+	for i := start; i <= end && p < len(pattern); i++ {
+		if !eq(text[i], pattern[p]) {
+			continue
+		}
+		score += scoreMatch
+		if i == 0 || isWordBoundary(text[i-1]) {
+			score += bonusBoundary
+			if p == 0 {
+				score += bonusBoundary
+			}
+		}
+		if p > 0 {
+			prev := positions[p-1]
+			if gap := i - prev - 1; gap > 0 {
+				score += scoreGapStart + scoreGapExtension*(gap-1)
+			} else {
+				score += bonusConsecutive
+			}
+		}
+		positions = append(positions, i)
+		p++
+	}
+	return score, positions, true
+}
+
+// isWordBoundary returns whether a word starts after r
+func isWordBoundary(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,139 @@
+package history
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		text      string
+		pattern   string
+		ok        bool
+		positions []int
+	}{
+		{"git commit", "", true, nil},
+		{"git commit", "gc", true, []int{0, 4}},
+		{"git commit", "cg", false, nil},
+		{"git commit", "GC", false, nil},
+		{"Makefile", "make", true, []int{0, 1, 2, 3}},
+		// The shortest match ending at the first complete occurrence
+		{"a a b", "ab", true, []int{2, 4}},
+		{"docker compose up", "dcu", true, []int{0, 2, 15}},
+		{"日本語のテスト", "本テ", true, []int{1, 4}},
+	}
+	for _, tt := range tests {
+		ignoreCase := tt.pattern == "" || tt.pattern[0] < 'A' || tt.pattern[0] > 'Z'
+		_, positions, ok := fuzzyMatch([]rune(tt.text), []rune(tt.pattern), ignoreCase)
+		if ok != tt.ok || !slices.Equal(positions, tt.positions) {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, %v, want %v, %v",
+				tt.text, tt.pattern, positions, ok, tt.positions, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	// Each text matches the pattern better than the one after it
+	tests := []struct {
+		pattern string
+		texts   []string
+	}{
+		// Consecutive matches, then matches at word starts
+		{"gc", []string{"gc", "git commit", "magic"}},
+		// Word starts, then consecutive matches, then gaps
+		{"log", []string{"git log", "blog", "xlxoxg"}},
+		{"mt", []string{"mtime", "make test", "format"}},
+	}
+	for _, tt := range tests {
+		prev := 0
+		for i, text := range tt.texts {
+			score, _, ok := fuzzyMatch([]rune(text), []rune(tt.pattern), true)
+			if !ok {
+				t.Errorf("%q does not match %q", tt.pattern, text)
+				continue
+			}
+			if i > 0 && score >= prev {
+				t.Errorf("%q scores %d in %q, not less than %d in %q",
+					tt.pattern, score, text, prev, tt.texts[i-1])
+			}
+			prev = score
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h := New(100)
+	h.filePath = ""
+	for _, e := range []Entry{
+		{Command: "make test", Dir: "/src", ExitCode: 1},
+		{Command: "git status", Dir: "/src", ExitCode: 0},
+		{Command: "make test", Dir: "/src", ExitCode: 0},
+		{Command: "vim Makefile", Dir: "/tmp", ExitCode: 0},
+		{Command: "make test", Dir: "/tmp", ExitCode: 0},
+		{Command: "ls", Dir: "/tmp", ExitCode: 0},
+	} {
+		h.AddEntry(e)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		filter   Filter
+		commands []string
+		counts   []int
+	}{
+		{
+			name:     "empty query lists commands newest first",
+			commands: []string{"ls", "make test", "vim Makefile", "git status"},
+			counts:   []int{1, 3, 1, 1},
+		},
+		{
+			name:     "better and more frequent matches first",
+			query:    "make",
+			commands: []string{"make test", "vim Makefile"},
+			counts:   []int{3, 1},
+		},
+		{
+			name:     "upper case matches case",
+			query:    "Make",
+			commands: []string{"vim Makefile"},
+			counts:   []int{1},
+		},
+		{
+			name:     "no match",
+			query:    "xyz",
+			commands: []string{},
+			counts:   []int{},
+		},
+		{
+			name:     "filter",
+			filter:   Filter{Scope: ScopeDir, Dir: "/src"},
+			commands: []string{"make test", "git status"},
+			counts:   []int{2, 1},
+		},
+		{
+			name:     "the most recent entry in the scope is returned",
+			query:    "make test",
+			filter:   Filter{Scope: ScopeSuccess},
+			commands: []string{"make test"},
+			counts:   []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := h.Search(tt.query, tt.filter)
+			got := make([]string, len(matches))
+			counts := make([]int, len(matches))
+			for i, m := range matches {
+				got[i] = m.Entry.Command
+				counts[i] = m.Count
+			}
+			if !slices.Equal(got, tt.commands) {
+				t.Errorf("commands = %q, want %q", got, tt.commands)
+			}
+			if !slices.Equal(counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
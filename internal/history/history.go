@@ -2,27 +2,53 @@
 package history
 
 import (
-	"bufio"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	// DefaultMaxEntries is the default maximum number of history entries
 	DefaultMaxEntries = 10000
 	// HistoryFileName is the name of the history file
-	HistoryFileName = "history"
+	HistoryFileName = "history.jsonl"
+	// LegacyFileName is the name of the plain-text history file of older
+	// versions, migrated on first load
+	LegacyFileName = "history"
 )
 
-// History manages command history
+// Entry is a command in the history.
+// Entries loaded from older history files only have the command.
+type Entry struct {
+	Command  string        `json:"cmd"`
+	Time     time.Time     `json:"time,omitzero"`     // When the command started
+	Dir      string        `json:"dir,omitempty"`     // Working directory
+	ExitCode int           `json:"exit"`              // Exit status, -1 if unknown
+	Duration time.Duration `json:"duration,omitzero"` // How long the command ran
+	Tab      string        `json:"tab,omitempty"`     // Name of the tab
+	Host     string        `json:"host,omitempty"`    // Host the command ran on
+	Session  string        `json:"session,omitempty"` // ID of the terminal session
+}
+
+// History manages command history.
+// The history file is shared with other GoNeSh instances: added entries are
+// appended to it right away and entries added by others are merged in by
+// Reload.
 type History struct {
-	entries    []string
+	entries    []Entry
 	maxEntries int
 	filePath   string
 	mu         sync.RWMutex
 	position   int // Current position for navigation
+	rules      *Rules
+
+	// How much of the history file has been read
+	file      os.FileInfo // To notice when the file is replaced
+	offset    int64       // Bytes read
+	fileLines int         // Entry lines read, to know when to compact it
+	fileErr   error       // Why the file is left alone, e.g. a newer format
 }
 
 // New creates a new History instance
@@ -32,7 +58,7 @@ func New(maxEntries int) *History {
 	}
 
 	h := &History{
-		entries:    make([]string, 0),
+		entries:    make([]Entry, 0),
 		maxEntries: maxEntries,
 		position:   -1,
 	}
@@ -52,7 +78,9 @@ func (h *History) defaultFilePath() string {
 	return filepath.Join(home, ".gonesh", HistoryFileName)
 }
 
-// Load loads history from the file
+// Load loads history from the file.
+// The plain-text file of older versions is migrated if there is no history
+// file yet.
 func (h *History) Load() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -61,92 +89,227 @@ func (h *History) Load() error {
 		return nil
 	}
 
-	file, err := os.Open(h.filePath)
+	h.entries = make([]Entry, 0)
+	h.position = 0
+	h.file = nil
+	h.offset = 0
+	h.fileLines = 0
+
+	unlock, err := h.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(h.filePath); os.IsNotExist(err) {
+		if err := h.migrate(); err != nil {
+			return err
+		}
+	}
+	err = h.sync()
+	h.position = len(h.entries)
+	return err
+}
+
+// migrate writes the history file from the plain-text file of older
+// versions. The old file is kept.
+func (h *History) migrate() error {
+	legacy := filepath.Join(filepath.Dir(h.filePath), LegacyFileName)
+	entries, err := readLegacyFile(legacy)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // No history file yet
 		}
 		return err
 	}
-	defer file.Close()
+	return writeFile(h.filePath, entries)
+}
 
-	scanner := bufio.NewScanner(file)
-	h.entries = make([]string, 0)
+// Reload merges the entries other instances added to the history file
+// since it was last read
+func (h *History) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			h.entries = append(h.entries, line)
-		}
+	if h.filePath == "" {
+		return nil
 	}
 
-	// Trim to max entries
-	if len(h.entries) > h.maxEntries {
-		h.entries = h.entries[len(h.entries)-h.maxEntries:]
+	offset := h.offset
+	err := h.sync()
+	if h.offset != offset {
+		h.position = len(h.entries)
 	}
-
-	h.position = len(h.entries)
-	return scanner.Err()
+	return err
 }
 
-// Save saves history to the file
+// Save compacts the history file once it holds more lines than the
+// maximum number of entries. Entries are written as they are added.
 func (h *History) Save() error {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	if h.filePath == "" {
 		return nil
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(h.filePath)
-	if err := os.MkdirAll(dir, 0700); err != nil {
+	unlock, err := h.lock()
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	file, err := os.Create(h.filePath)
-	if err != nil {
+	// Keep what other instances added
+	if err := h.sync(); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	for _, entry := range h.entries {
-		if _, err := writer.WriteString(entry + "\n"); err != nil {
-			return err
-		}
+	if h.fileLines <= h.maxEntries {
+		return nil
 	}
+	return h.rewrite()
+}
 
-	return writer.Flush()
+// Add adds a command to the history
+func (h *History) Add(command string) {
+	h.AddEntry(Entry{Command: command, Time: time.Now(), ExitCode: -1})
 }
 
-// Add adds a new entry to the history
-func (h *History) Add(entry string) {
+// AddEntry adds an entry to the history and appends it to the file.
+// Commands ignored by the rules are not added and secrets are masked.
+func (h *History) AddEntry(entry Entry) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	entry = strings.TrimSpace(entry)
-	if entry == "" {
+	command, ok := h.rules.Apply(entry.Command)
+	if !ok {
+		return
+	}
+	entry.Command = strings.TrimSpace(command)
+	if entry.Command == "" {
 		return
 	}
 
-	// Don't add duplicates of the last entry
-	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+	if h.filePath == "" {
+		h.add(entry)
 		h.position = len(h.entries)
 		return
 	}
 
+	unlock, err := h.lock()
+	if err != nil {
+		h.add(entry)
+		h.position = len(h.entries)
+		return
+	}
+	defer unlock()
+
+	// Entries of other instances come first
+	_ = h.sync()
+	h.add(entry)
+	h.position = len(h.entries)
+	if h.fileErr != nil {
+		return
+	}
+
+	info, err := appendFile(h.filePath, []Entry{entry})
+	if err != nil {
+		return
+	}
+	h.file = info
+	h.offset = info.Size()
+	h.fileLines++
+}
+
+// rewrite replaces the history file with the entries
+func (h *History) rewrite() error {
+	if err := writeFile(h.filePath, h.entries); err != nil {
+		return err
+	}
+	info, err := os.Stat(h.filePath)
+	if err != nil {
+		return err
+	}
+	h.file = info
+	h.offset = info.Size()
+	h.fileLines = len(h.entries)
+	return nil
+}
+
+// lock takes the lock of the history file
+func (h *History) lock() (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(h.filePath), 0700); err != nil {
+		return nil, err
+	}
+	return lockFile(h.filePath)
+}
+
+// sync reads the entries added to the history file since it was last
+// read. The whole file is read again if it was replaced.
+// A file that cannot be read, e.g. because it has a newer format, is left
+// alone until it changes; entries are meanwhile only kept in memory.
+func (h *History) sync() error {
+	file, err := os.Open(h.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.fileErr = nil
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if h.fileErr != nil {
+		if h.file != nil && os.SameFile(info, h.file) && info.Size() == h.file.Size() &&
+			info.ModTime().Equal(h.file.ModTime()) {
+			return h.fileErr
+		}
+		h.file = nil
+	}
+	if h.file == nil || !os.SameFile(info, h.file) || info.Size() < h.offset {
+		h.entries = h.entries[:0]
+		h.offset = 0
+		h.fileLines = 0
+	}
+	h.file = info
+	if info.Size() == h.offset {
+		return nil
+	}
+
+	data := make([]byte, info.Size()-h.offset)
+	if _, err := file.ReadAt(data, h.offset); err != nil {
+		return err
+	}
+	entries, lines, n, err := parseFile(data, h.offset == 0)
+	h.fileErr = err
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		h.add(entry)
+	}
+	h.offset += int64(n)
+	h.fileLines += lines
+	return nil
+}
+
+// add adds an entry to the list. Every run of a command is kept, so that
+// its exit status and duration are recorded even when it repeats the last
+// one.
+func (h *History) add(entry Entry) {
 	h.entries = append(h.entries, entry)
 
 	// Trim to max entries
 	if len(h.entries) > h.maxEntries {
 		h.entries = h.entries[1:]
 	}
-
-	h.position = len(h.entries)
 }
 
-// Previous returns the previous entry in history
+// Previous returns the previous command in history.
+// A command run several times in a row is returned once.
 func (h *History) Previous() (string, bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -158,11 +321,16 @@ func (h *History) Previous() (string, bool) {
 	if h.position > 0 {
 		h.position--
 	}
+	// Stop at the first of the runs
+	for h.position > 0 && h.entries[h.position-1].Command == h.entries[h.position].Command {
+		h.position--
+	}
 
-	return h.entries[h.position], true
+	return h.entries[h.position].Command, true
 }
 
-// Next returns the next entry in history
+// Next returns the next command in history.
+// A command run several times in a row is returned once.
 func (h *History) Next() (string, bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -173,7 +341,15 @@ func (h *History) Next() (string, bool) {
 	}
 
 	h.position++
-	return h.entries[h.position], true
+	// Skip the other runs of the command
+	for h.position > 0 && h.position < len(h.entries) &&
+		h.entries[h.position].Command == h.entries[h.position-1].Command {
+		h.position++
+	}
+	if h.position == len(h.entries) {
+		return "", false
+	}
+	return h.entries[h.position].Command, true
 }
 
 // ResetPosition resets the history navigation position
@@ -183,28 +359,6 @@ func (h *History) ResetPosition() {
 	h.position = len(h.entries)
 }
 
-// Search searches for entries matching the query
-func (h *History) Search(query string) []string {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	if query == "" {
-		return h.entries
-	}
-
-	query = strings.ToLower(query)
-	var results []string
-
-	// Search from newest to oldest
-	for i := len(h.entries) - 1; i >= 0; i-- {
-		if strings.Contains(strings.ToLower(h.entries[i]), query) {
-			results = append(results, h.entries[i])
-		}
-	}
-
-	return results
-}
-
 // SearchReverse searches for entries matching the query (reverse incremental search)
 func (h *History) SearchReverse(query string, startPos int) (string, int, bool) {
 	h.mu.RLock()
@@ -223,20 +377,29 @@ func (h *History) SearchReverse(query string, startPos int) (string, int, bool)
 	}
 
 	for i := start; i >= 0; i-- {
-		if strings.Contains(strings.ToLower(h.entries[i]), query) {
-			return h.entries[i], i, true
+		if strings.Contains(strings.ToLower(h.entries[i].Command), query) {
+			return h.entries[i].Command, i, true
 		}
 	}
 
 	return "", -1, false
 }
 
-// Entries returns all history entries
-func (h *History) Entries() []string {
+// Err returns why the history file is left alone, e.g. because it has a
+// newer format, or nil if it is in use. Added commands are then only kept
+// in memory. The file is checked again when it is next read.
+func (h *History) Err() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.fileErr
+}
+
+// Entries returns all history entries, oldest first
+func (h *History) Entries() []Entry {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	result := make([]string, len(h.entries))
+	result := make([]Entry, len(h.entries))
 	copy(result, h.entries)
 	return result
 }
@@ -252,6 +415,6 @@ func (h *History) Len() int {
 func (h *History) Clear() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.entries = make([]string, 0)
+	h.entries = make([]Entry, 0)
 	h.position = 0
 }
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAddKeepsRepeatedRuns(t *testing.T) {
+	h := New(100)
+	h.filePath = ""
+	h.AddEntry(Entry{Command: "make", ExitCode: 2})
+	h.AddEntry(Entry{Command: "make", ExitCode: 0})
+
+	entries := h.Entries()
+	if len(entries) != 2 || entries[0].ExitCode != 2 || entries[1].ExitCode != 0 {
+		t.Errorf("entries = %+v, want the failed and the successful run", entries)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	h := New(100)
+	h.filePath = ""
+	for _, c := range []string{"ls", "make", "make", "make", "git status", "ls", "ls"} {
+		h.Add(c)
+	}
+
+	// Runs in a row are returned once
+	var got []string
+	for {
+		command, ok := h.Previous()
+		if !ok || len(got) > 0 && command == got[len(got)-1] {
+			break
+		}
+		got = append(got, command)
+	}
+	if want := []string{"ls", "git status", "make", "ls"}; !slices.Equal(got, want) {
+		t.Fatalf("going back = %q, want %q", got, want)
+	}
+
+	got = got[:0]
+	for {
+		command, ok := h.Next()
+		if !ok {
+			break
+		}
+		got = append(got, command)
+	}
+	if want := []string{"make", "git status", "ls"}; !slices.Equal(got, want) {
+		t.Errorf("going forward = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,310 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shell is a shell whose history can be imported
+type Shell string
+
+const (
+	ShellBash Shell = "bash"
+	ShellZsh  Shell = "zsh"
+	ShellFish Shell = "fish"
+)
+
+// Shells lists the shells whose history can be imported
+var Shells = []Shell{ShellBash, ShellZsh, ShellFish}
+
+// ShellHistoryFile returns where the shell keeps its history by default
+func ShellHistoryFile(shell Shell) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch shell {
+	case ShellBash:
+		return filepath.Join(home, ".bash_history"), nil
+	case ShellZsh:
+		dir := os.Getenv("ZDOTDIR")
+		if dir == "" {
+			dir = home
+		}
+		return filepath.Join(dir, ".zsh_history"), nil
+	case ShellFish:
+		dir := os.Getenv("XDG_DATA_HOME")
+		if dir == "" {
+			dir = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dir, "fish", "fish_history"), nil
+	}
+	return "", fmt.Errorf("history: unknown shell %q", shell)
+}
+
+// ReadShellHistory reads the history file of a shell, oldest first.
+// Commands only have a time if the shell recorded one.
+func ReadShellHistory(shell Shell, r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch shell {
+	case ShellBash:
+		return parseBashHistory(data), nil
+	case ShellZsh:
+		return parseZshHistory(data), nil
+	case ShellFish:
+		return parseFishHistory(data), nil
+	}
+	return nil, fmt.Errorf("history: unknown shell %q", shell)
+}
+
+// parseBashHistory parses ~/.bash_history. With HISTTIMEFORMAT set, bash
+// writes the time of a command on a "#<unix time>" line before it.
+func parseBashHistory(data []byte) []Entry {
+	var entries []Entry
+	var when time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ts, ok := strings.CutPrefix(line, "#"); ok {
+			if sec, err := strconv.ParseInt(ts, 10, 64); err == nil {
+				when = time.Unix(sec, 0)
+				continue
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entries = append(entries, Entry{Command: line, Time: when, ExitCode: -1})
+		when = time.Time{}
+	}
+	return entries
+}
+
+// parseZshHistory parses ~/.zsh_history. Lines of the extended history
+// format are ": <start>:<duration>;<command>"; lines of a multi-line
+// command end with a backslash.
+func parseZshHistory(data []byte) []Entry {
+	data = unmetafy(data)
+
+	var entries []Entry
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + "\n" + lines[i]
+		}
+
+		entry := Entry{ExitCode: -1}
+		if rest, ok := strings.CutPrefix(line, ": "); ok {
+			meta, command, found := strings.Cut(rest, ";")
+			start, dur, _ := strings.Cut(meta, ":")
+			sec, err1 := strconv.ParseInt(start, 10, 64)
+			elapsed, err2 := strconv.ParseInt(dur, 10, 64)
+			if found && err1 == nil && err2 == nil {
+				entry.Time = time.Unix(sec, 0)
+				entry.Duration = time.Duration(elapsed) * time.Second
+				line = command
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry.Command = line
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// unmetafy undoes the escaping zsh applies to bytes in its history file:
+// 0x83 followed by the byte xor 0x20
+func unmetafy(data []byte) []byte {
+	if bytes.IndexByte(data, 0x83) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == 0x83 && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^0x20)
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+// parseFishHistory parses fish_history, a YAML-like list in which each
+// command starts with a "- cmd: <command>" line, followed by indented
+// "when: <unix time>" and "paths:" lines. Newlines and backslashes in the
+// command are escaped.
+func parseFishHistory(data []byte) []Entry {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if command, ok := strings.CutPrefix(line, "- cmd: "); ok {
+			entries = append(entries, Entry{Command: unescapeFish(command), ExitCode: -1})
+			continue
+		}
+		if when, ok := strings.CutPrefix(strings.TrimSpace(line), "when: "); ok && len(entries) > 0 {
+			if sec, err := strconv.ParseInt(when, 10, 64); err == nil {
+				entries[len(entries)-1].Time = time.Unix(sec, 0)
+			}
+		}
+	}
+	return entries
+}
+
+// unescapeFish undoes the escaping of commands in fish_history
+func unescapeFish(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// Import adds entries read from another shell's history, oldest first,
+// keeping their times and placing them among the existing entries by time.
+// The rules apply as for added entries.
+// Entries already in the history are skipped: those with a time if the
+// history has the command at the same time or without a time, as migrated
+// from the plain-text file, and those without one if it has the command at
+// all. Each existing entry accounts for one imported entry, so importing
+// the same history again adds nothing and commands repeated in it keep
+// their count. It returns the number of entries added.
+func (h *History) Import(entries []Entry) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var unlock func()
+	if h.filePath != "" {
+		var err error
+		if unlock, err = h.lock(); err != nil {
+			return 0, err
+		}
+		defer unlock()
+		if err := h.sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	type key struct {
+		command string
+		time    int64
+	}
+	keyOf := func(e Entry) key {
+		if e.Time.IsZero() {
+			return key{command: e.Command}
+		}
+		return key{command: e.Command, time: e.Time.Unix()}
+	}
+	existing := make(map[key]int, 2*len(h.entries))
+	untimed := make(map[string]int)
+	for _, e := range h.entries {
+		existing[key{command: e.Command}]++
+		if e.Time.IsZero() {
+			untimed[e.Command]++
+		} else {
+			existing[keyOf(e)]++
+		}
+	}
+
+	var added []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		command, ok := h.rules.Apply(e.Command)
+		if !ok {
+			continue
+		}
+		e.Command = strings.TrimSpace(command)
+		if e.Command == "" {
+			continue
+		}
+		if k := keyOf(e); existing[k] > 0 {
+			existing[k]--
+			continue
+		}
+		// Entries migrated from the plain-text file have no time
+		if !e.Time.IsZero() && untimed[e.Command] > 0 {
+			untimed[e.Command]--
+			existing[key{command: e.Command}]--
+			continue
+		}
+		added = append(added, e)
+	}
+	slices.Reverse(added)
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	merged := mergeByTime(added, h.entries)
+	h.entries = make([]Entry, 0, len(merged))
+	for _, e := range merged {
+		h.add(e)
+	}
+	h.position = len(h.entries)
+
+	if h.filePath == "" {
+		return len(added), nil
+	}
+	if err := h.rewrite(); err != nil {
+		return 0, err
+	}
+	return len(added), nil
+}
+
+// mergeByTime merges two lists of entries ordered by time. Entries without
+// a time stay after the entry before them in their list; a comes first
+// among entries of the same time.
+func mergeByTime(a, b []Entry) []Entry {
+	merged := make([]Entry, 0, len(a)+len(b))
+	var ta, tb time.Time
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !a[i].Time.IsZero() {
+			ta = a[i].Time
+		}
+		if !b[j].Time.IsZero() {
+			tb = b[j].Time
+		}
+		if !ta.After(tb) {
+			merged = append(merged, a[i])
+			i++
+		} else {
+			merged = append(merged, b[j])
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
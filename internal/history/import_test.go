@@ -0,0 +1,197 @@
+package history
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadShellHistory(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+
+	tests := []struct {
+		name  string
+		shell Shell
+		data  string
+		want  []Entry
+	}{
+		{
+			name:  "bash",
+			shell: ShellBash,
+			data:  "ls\n\ncd /tmp\n",
+			want: []Entry{
+				{Command: "ls", ExitCode: -1},
+				{Command: "cd /tmp", ExitCode: -1},
+			},
+		},
+		{
+			name:  "bash with HISTTIMEFORMAT",
+			shell: ShellBash,
+			data:  "#1700000000\nls\n#1700000060\n# a comment\nmake\n",
+			want: []Entry{
+				{Command: "ls", Time: at(1700000000), ExitCode: -1},
+				{Command: "# a comment", Time: at(1700000060), ExitCode: -1},
+				{Command: "make", ExitCode: -1},
+			},
+		},
+		{
+			name:  "zsh",
+			shell: ShellZsh,
+			data:  "ls\ncd /tmp\n",
+			want: []Entry{
+				{Command: "ls", ExitCode: -1},
+				{Command: "cd /tmp", ExitCode: -1},
+			},
+		},
+		{
+			name:  "zsh extended history",
+			shell: ShellZsh,
+			data:  ": 1700000000:5;make test\n: 1700000010:0;echo a;b\n",
+			want: []Entry{
+				{Command: "make test", Time: at(1700000000), Duration: 5 * time.Second, ExitCode: -1},
+				{Command: "echo a;b", Time: at(1700000010), ExitCode: -1},
+			},
+		},
+		{
+			name:  "zsh multi-line command",
+			shell: ShellZsh,
+			data:  ": 1700000000:0;for i in 1 2\\\ndo echo $i\\\ndone\nls\n",
+			want: []Entry{
+				{Command: "for i in 1 2\ndo echo $i\ndone", Time: at(1700000000), ExitCode: -1},
+				{Command: "ls", ExitCode: -1},
+			},
+		},
+		{
+			name:  "zsh metafied bytes",
+			shell: ShellZsh,
+			// ム is e3 83 a0; zsh escapes the 83 byte
+			data: "echo \xe3\x83\xa3\xa0\n",
+			want: []Entry{
+				{Command: "echo ム", ExitCode: -1},
+			},
+		},
+		{
+			name:  "fish",
+			shell: ShellFish,
+			data: "- cmd: ls\n  when: 1700000000\n" +
+				"- cmd: echo a\\nb \\\\n\n  when: 1700000060\n  paths:\n    - /tmp\n" +
+				"- cmd: pwd\n",
+			want: []Entry{
+				{Command: "ls", Time: at(1700000000), ExitCode: -1},
+				{Command: "echo a\nb \\n", Time: at(1700000060), ExitCode: -1},
+				{Command: "pwd", ExitCode: -1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadShellHistory(tt.shell, strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(got, tt.want, equalEntry) {
+				t.Errorf("entries = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// equalEntry compares entries, with times compared as instants
+func equalEntry(a, b Entry) bool {
+	ta, tb := a.Time, b.Time
+	a.Time, b.Time = time.Time{}, time.Time{}
+	return a == b && ta.Equal(tb)
+}
+
+func TestImport(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+
+	tests := []struct {
+		name     string
+		existing []Entry
+		imported []Entry
+		added    int
+		commands []string
+	}{
+		{
+			name:     "untimed entries keep their repeats",
+			imported: []Entry{{Command: "ls"}, {Command: "cd /"}, {Command: "ls"}, {Command: "make"}, {Command: "ls"}},
+			added:    5,
+			commands: []string{"ls", "cd /", "ls", "make", "ls"},
+		},
+		{
+			name:     "repeats in a row are kept",
+			imported: []Entry{{Command: "ls"}, {Command: "ls"}, {Command: "make"}},
+			added:    3,
+			commands: []string{"ls", "ls", "make"},
+		},
+		{
+			name:     "untimed entries are skipped by command",
+			existing: []Entry{{Command: "ls", Time: at(100)}},
+			imported: []Entry{{Command: "ls"}, {Command: "make"}, {Command: "ls"}},
+			added:    2,
+			commands: []string{"ls", "make", "ls"},
+		},
+		{
+			name:     "timed entries are skipped by command and time",
+			existing: []Entry{{Command: "make", Time: at(100)}},
+			imported: []Entry{{Command: "make", Time: at(100)}, {Command: "ls", Time: at(150)}, {Command: "make", Time: at(200)}},
+			added:    2,
+			commands: []string{"make", "ls", "make"},
+		},
+		{
+			name:     "timed entries are skipped by command if the history has no times",
+			existing: []Entry{{Command: "ls"}, {Command: "make"}},
+			imported: []Entry{{Command: "ls", Time: at(100)}, {Command: "make", Time: at(200)}, {Command: "ls", Time: at(300)}},
+			added:    1,
+			commands: []string{"ls", "make", "ls"},
+		},
+		{
+			name:     "timed entries are placed by time",
+			existing: []Entry{{Command: "a", Time: at(100)}, {Command: "c", Time: at(300)}},
+			imported: []Entry{{Command: "b", Time: at(200)}, {Command: "d", Time: at(400)}},
+			added:    2,
+			commands: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "empty commands are skipped",
+			imported: []Entry{{Command: "  "}, {Command: " ls "}},
+			added:    1,
+			commands: []string{"ls"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHistory(t)
+			for _, e := range tt.existing {
+				h.AddEntry(e)
+			}
+
+			added, err := h.Import(tt.imported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != tt.added {
+				t.Errorf("added = %d, want %d", added, tt.added)
+			}
+			if got := commands(h.Entries()); !slices.Equal(got, tt.commands) {
+				t.Errorf("commands = %q, want %q", got, tt.commands)
+			}
+
+			// Importing the same entries again adds nothing
+			if added, err := h.Import(tt.imported); err != nil || added != 0 {
+				t.Errorf("importing again added %d (err %v), want 0", added, err)
+			}
+
+			// The file has the same entries
+			reloaded := New(100)
+			if err := reloaded.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if got := commands(reloaded.Entries()); !slices.Equal(got, tt.commands) {
+				t.Errorf("commands in the file = %q, want %q", got, tt.commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package history
+
+// lockFile does nothing on systems without flock
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,227 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ousiass/GoNeSh/pkg/config"
+)
+
+// Mask replaces redacted text
+const Mask = "****"
+
+// secretPatterns match known secret formats. Where a pattern has groups,
+// only the groups are masked.
+var secretPatterns = []*regexp.Regexp{
+	// AWS access key IDs
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// GitHub tokens
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	// JSON Web Tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	// Authorization headers, and elsewhere bearer tokens with a digit, so
+	// that words following "token" in text are kept
+	regexp.MustCompile(`(?i)\bauthorization:\s*(?:bearer|token|basic)\s+([A-Za-z0-9._~+/=-]+)`),
+	regexp.MustCompile(`(?i)\b(?:bearer|token)\s+([A-Za-z._~+/=-]*[0-9][A-Za-z0-9._~+/=-]*)`),
+	// Variables and options named like secrets, e.g. AWS_SECRET_ACCESS_KEY=...
+	regexp.MustCompile(`(?i)[A-Z0-9_-]*(?:secret|token|passw(?:or)?d|api[_-]?key|access[_-]?key|private[_-]?key)[A-Z0-9_-]*[=:]\s*("[^"]*"|'[^']*'|[^\s"']+)`),
+	// Options named like secrets with the value as the next argument, e.g.
+	// --password hunter2, but not --token-file path
+	regexp.MustCompile(`(?i)(?:^|\s)--?(?:[A-Z0-9]+[_-])*(?:secret|token|passw(?:or)?d|api[_-]?key|access[_-]?key|private[_-]?key)\s+("[^"]*"|'[^']*'|[^\s"'-][^\s"']*)`),
+	// -p of commands where it is the password, e.g. sshpass -p hunter2 and
+	// mysql -phunter2, but not mkdir -p or ssh -p
+	regexp.MustCompile(`\b(?:sshpass|(?:docker|podman|buildah|skopeo) login|mongo(?:sh|dump|restore)?)\b[^|;&\n]*?\s-p\s*("[^"]*"|'[^']*'|[^\s"']+)`),
+	regexp.MustCompile(`\b(?:mysql|mysqldump|mysqladmin|mariadb)\b[^|;&\n]*?\s-p([^\s"']+|"[^"]*"|'[^']*')`),
+	// curl -u user:password
+	regexp.MustCompile(`\bcurl\b[^|;&\n]*?\s(?:-u\s*|--user[\s=]+)["']?[^\s:"']*:([^\s"']+)`),
+	// Passwords in URLs
+	regexp.MustCompile(`://[^/\s:@]+:([^/\s@]+)@`),
+}
+
+// Rules decide which commands are recorded in the history and mask the
+// secrets in them before they are written
+type Rules struct {
+	ignoreSpace bool
+	ignore      []*regexp.Regexp
+	redact      []*regexp.Regexp
+}
+
+// NewRules creates rules from the history settings
+func NewRules(cfg config.HistoryConfig) (*Rules, error) {
+	r := &Rules{ignoreSpace: cfg.IgnoreSpace}
+	for _, p := range cfg.Ignore {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("history.ignore: %w", err)
+		}
+		r.ignore = append(r.ignore, re)
+	}
+	for _, p := range cfg.Redact {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("history.redact: %w", err)
+		}
+		r.redact = append(r.redact, re)
+	}
+	if cfg.RedactSecrets {
+		r.redact = append(r.redact, secretPatterns...)
+	}
+	return r, nil
+}
+
+// Apply returns the command as it is to be recorded, with secrets masked.
+// ok is false if the command is not to be recorded at all.
+func (r *Rules) Apply(command string) (string, bool) {
+	if r == nil {
+		return command, true
+	}
+	if r.ignoreSpace && strings.HasPrefix(command, " ") {
+		return "", false
+	}
+	for _, re := range r.ignore {
+		if re.MatchString(command) {
+			return "", false
+		}
+	}
+	for _, re := range r.redact {
+		command = mask(re, command)
+	}
+	return command, true
+}
+
+// mask replaces the matches of re in s with Mask, or only their groups if
+// re has any
+func mask(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		spans := [][]int{{m[0], m[1]}}
+		if len(m) > 2 {
+			spans = spans[:0]
+			for g := 2; g < len(m); g += 2 {
+				if m[g] >= 0 && m[g+1] > m[g] {
+					spans = append(spans, []int{m[g], m[g+1]})
+				}
+			}
+		}
+		for _, span := range spans {
+			if span[0] < last {
+				continue
+			}
+			sb.WriteString(s[last:span[0]])
+			sb.WriteString(Mask)
+			last = span[1]
+		}
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// SetRules sets the rules applied to added entries
+func (h *History) SetRules(r *Rules) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rules = r
+}
+
+// ScrubResult is what Scrub changed
+type ScrubResult struct {
+	Removed int // Entries removed
+	Masked  int // Entries with secrets masked
+
+	// Plain-text history file of older versions, "" if there is none, and
+	// the commands removed from and masked in it
+	LegacyFile    string
+	LegacyRemoved int
+	LegacyMasked  int
+}
+
+// Scrub applies the rules to the entries already in the history and
+// rewrites the history file. The plain-text file of older versions, which
+// is kept after migrating it, is rewritten as well.
+func (h *History) Scrub() (ScrubResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var res ScrubResult
+	if h.filePath != "" {
+		unlock, err := h.lock()
+		if err != nil {
+			return res, err
+		}
+		defer unlock()
+		if err := h.sync(); err != nil {
+			return res, err
+		}
+		if err := h.scrubLegacy(&res); err != nil {
+			return res, err
+		}
+	}
+
+	kept := make([]Entry, 0, len(h.entries))
+	for _, e := range h.entries {
+		command, ok := h.rules.Apply(e.Command)
+		switch {
+		case !ok:
+			res.Removed++
+			continue
+		case command != e.Command:
+			res.Masked++
+			e.Command = command
+		}
+		kept = append(kept, e)
+	}
+	if res.Removed == 0 && res.Masked == 0 {
+		return res, nil
+	}
+	h.entries = kept
+	h.position = len(h.entries)
+
+	if h.filePath == "" {
+		return res, nil
+	}
+	if err := h.rewrite(); err != nil {
+		res.Removed, res.Masked = 0, 0
+		return res, err
+	}
+	return res, nil
+}
+
+// scrubLegacy applies the rules to the plain-text history file of older
+// versions, if there is one
+func (h *History) scrubLegacy(res *ScrubResult) error {
+	path := filepath.Join(filepath.Dir(h.filePath), LegacyFileName)
+	entries, err := readLegacyFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.LegacyFile = path
+
+	kept := make([]string, 0, len(entries))
+	for _, e := range entries {
+		command, ok := h.rules.Apply(e.Command)
+		switch {
+		case !ok:
+			res.LegacyRemoved++
+			continue
+		case command != e.Command:
+			res.LegacyMasked++
+		}
+		kept = append(kept, command)
+	}
+	if res.LegacyRemoved == 0 && res.LegacyMasked == 0 {
+		return nil
+	}
+	return writeLegacyFile(path, kept)
+}
@@ -0,0 +1,164 @@
+package history
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// CommandCount is how often a command ran
+type CommandCount struct {
+	Command string `json:"command"`
+	Count   int    `json:"count"`
+}
+
+// CommandFailures is how often a command failed
+type CommandFailures struct {
+	Command  string `json:"command"`
+	Failures int    `json:"failures"`
+	Runs     int    `json:"runs"` // Runs with a known exit status
+}
+
+// CommandDuration is how long a command took
+type CommandDuration struct {
+	Command string        `json:"command"`
+	Max     time.Duration `json:"max_ns"`
+	Mean    time.Duration `json:"mean_ns"`
+	Runs    int           `json:"runs"` // Runs with a recorded duration
+}
+
+// HostCount is how many commands ran on a host
+type HostCount struct {
+	Host     string `json:"host"`
+	Count    int    `json:"count"`
+	Failures int    `json:"failures"`
+}
+
+// Stats summarizes the history
+type Stats struct {
+	Total    int       `json:"total"`
+	Unique   int       `json:"unique"`
+	Failures int       `json:"failures"`
+	First    time.Time `json:"first,omitzero"` // Time of the oldest timed entry
+	Last     time.Time `json:"last,omitzero"`  // Time of the newest timed entry
+
+	MostUsed   []CommandCount    `json:"most_used"`
+	MostFailed []CommandFailures `json:"most_failed"`
+	Slowest    []CommandDuration `json:"slowest"`
+	Hosts      []HostCount       `json:"hosts"`
+
+	// Commands run per hour of the day and per day of the week (Sunday
+	// first), in local time. Entries without a time are not counted.
+	ByHour    [24]int `json:"by_hour"`
+	ByWeekday [7]int  `json:"by_weekday"`
+}
+
+// ComputeStats summarizes entries, listing at most limit commands and
+// hosts in each ranking
+func ComputeStats(entries []Entry, limit int) Stats {
+	type commandTotals struct {
+		runs, known, failures int
+		timed                 int
+		total, max            time.Duration
+	}
+	commands := make(map[string]*commandTotals)
+	hosts := make(map[string]*HostCount)
+
+	var s Stats
+	for _, e := range entries {
+		s.Total++
+		c := commands[e.Command]
+		if c == nil {
+			c = &commandTotals{}
+			commands[e.Command] = c
+		}
+		c.runs++
+		if e.ExitCode >= 0 {
+			c.known++
+		}
+		if e.ExitCode > 0 {
+			c.failures++
+			s.Failures++
+		}
+		if e.Duration > 0 {
+			c.timed++
+			c.total += e.Duration
+			c.max = max(c.max, e.Duration)
+		}
+
+		if e.Host != "" {
+			h := hosts[e.Host]
+			if h == nil {
+				h = &HostCount{Host: e.Host}
+				hosts[e.Host] = h
+			}
+			h.Count++
+			if e.ExitCode > 0 {
+				h.Failures++
+			}
+		}
+
+		if !e.Time.IsZero() {
+			if s.First.IsZero() || e.Time.Before(s.First) {
+				s.First = e.Time
+			}
+			if e.Time.After(s.Last) {
+				s.Last = e.Time
+			}
+			local := e.Time.Local()
+			s.ByHour[local.Hour()]++
+			s.ByWeekday[local.Weekday()]++
+		}
+	}
+	s.Unique = len(commands)
+
+	// Empty rankings are empty lists rather than null in JSON
+	s.MostUsed = make([]CommandCount, 0, len(commands))
+	s.MostFailed = []CommandFailures{}
+	s.Slowest = []CommandDuration{}
+	s.Hosts = make([]HostCount, 0, len(hosts))
+
+	for command, c := range commands {
+		s.MostUsed = append(s.MostUsed, CommandCount{Command: command, Count: c.runs})
+		if c.failures > 0 {
+			s.MostFailed = append(s.MostFailed, CommandFailures{
+				Command:  command,
+				Failures: c.failures,
+				Runs:     c.known,
+			})
+		}
+		if c.timed > 0 {
+			s.Slowest = append(s.Slowest, CommandDuration{
+				Command: command,
+				Max:     c.max,
+				Mean:    c.total / time.Duration(c.timed),
+				Runs:    c.timed,
+			})
+		}
+	}
+	for _, h := range hosts {
+		s.Hosts = append(s.Hosts, *h)
+	}
+
+	// Ties are broken by name so that the report is stable
+	slices.SortFunc(s.MostUsed, func(a, b CommandCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Command, b.Command))
+	})
+	slices.SortFunc(s.MostFailed, func(a, b CommandFailures) int {
+		return cmp.Or(cmp.Compare(b.Failures, a.Failures), cmp.Compare(a.Command, b.Command))
+	})
+	slices.SortFunc(s.Slowest, func(a, b CommandDuration) int {
+		return cmp.Or(cmp.Compare(b.Mean, a.Mean), cmp.Compare(a.Command, b.Command))
+	})
+	slices.SortFunc(s.Hosts, func(a, b HostCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Host, b.Host))
+	})
+
+	if limit > 0 {
+		s.MostUsed = s.MostUsed[:min(limit, len(s.MostUsed))]
+		s.MostFailed = s.MostFailed[:min(limit, len(s.MostFailed))]
+		s.Slowest = s.Slowest[:min(limit, len(s.Slowest))]
+		s.Hosts = s.Hosts[:min(limit, len(s.Hosts))]
+	}
+	return s
+}
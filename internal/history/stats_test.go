@@ -0,0 +1,54 @@
+package history
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	h := New(100)
+	h.filePath = ""
+	for _, e := range []Entry{
+		{Command: "make", ExitCode: 2, Duration: 4 * time.Second, Host: "a"},
+		{Command: "make", ExitCode: 0, Duration: 2 * time.Second, Host: "a"},
+		{Command: "make", ExitCode: 0, Duration: 3 * time.Second, Host: "a"},
+		{Command: "ls", ExitCode: 0, Host: "b"},
+		{Command: "ls", ExitCode: -1},
+		{Command: "git push", ExitCode: 1, Duration: 10 * time.Second, Host: "b"},
+		{Command: "git push", ExitCode: 1, Duration: 20 * time.Second, Host: "b"},
+	} {
+		h.AddEntry(e)
+	}
+
+	s := ComputeStats(h.Entries(), 0)
+	if s.Total != 7 || s.Unique != 3 || s.Failures != 3 {
+		t.Errorf("total, unique, failures = %d, %d, %d, want 7, 3, 3", s.Total, s.Unique, s.Failures)
+	}
+
+	// Commands run several times in a row count every run
+	wantUsed := []CommandCount{{"make", 3}, {"git push", 2}, {"ls", 2}}
+	if !slices.Equal(s.MostUsed, wantUsed) {
+		t.Errorf("most used = %+v, want %+v", s.MostUsed, wantUsed)
+	}
+	wantFailed := []CommandFailures{{"git push", 2, 2}, {"make", 1, 3}}
+	if !slices.Equal(s.MostFailed, wantFailed) {
+		t.Errorf("most failed = %+v, want %+v", s.MostFailed, wantFailed)
+	}
+	wantSlowest := []CommandDuration{
+		{"git push", 20 * time.Second, 15 * time.Second, 2},
+		{"make", 4 * time.Second, 3 * time.Second, 3},
+	}
+	if !slices.Equal(s.Slowest, wantSlowest) {
+		t.Errorf("slowest = %+v, want %+v", s.Slowest, wantSlowest)
+	}
+	wantHosts := []HostCount{{"a", 3, 1}, {"b", 3, 2}}
+	if !slices.Equal(s.Hosts, wantHosts) {
+		t.Errorf("hosts = %+v, want %+v", s.Hosts, wantHosts)
+	}
+
+	if s := ComputeStats(h.Entries(), 1); len(s.MostUsed) != 1 || len(s.MostFailed) != 1 ||
+		len(s.Slowest) != 1 || len(s.Hosts) != 1 {
+		t.Errorf("limit 1 = %+v, want one of each ranking", s)
+	}
+}
@@ -0,0 +1,39 @@
+package history
+
+import "strings"
+
+// Suggest returns the command most likely to complete prefix, or "" if
+// there is none: the newest command starting with it, preferring commands
+// run in dir on host, then in dir, then on host. dir and host may be "" if
+// they are unknown. Multi-line commands are not suggested.
+func (h *History) Suggest(prefix, dir, host string) string {
+	if strings.TrimSpace(prefix) == "" {
+		return ""
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	best, bestRank := "", -1
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		e := h.entries[i]
+		if len(e.Command) <= len(prefix) || !strings.HasPrefix(e.Command, prefix) ||
+			strings.Contains(e.Command, "\n") {
+			continue
+		}
+		rank := 0
+		if dir != "" && e.Dir == dir {
+			rank += 2
+		}
+		if host != "" && e.Host == host {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = e.Command, rank
+			if rank == 3 {
+				break
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,45 @@
+package history
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	h := New(100)
+	h.filePath = ""
+	for _, e := range []Entry{
+		{Command: "make test", Dir: "/src", Host: "a"},
+		{Command: "make lint", Dir: "/src", Host: "b"},
+		{Command: "make run --fast", Dir: "/src", Host: "b"},
+		{Command: "make run", Dir: "/tmp", Host: "a"},
+		{Command: "make", Dir: "/tmp", Host: "b"},
+		{Command: "make clean", Dir: "/tmp", Host: "b"},
+		{Command: "echo a\nb", Dir: "/src", Host: "a"},
+	} {
+		h.AddEntry(e)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		dir    string
+		host   string
+		want   string
+	}{
+		{"newest without dir and host", "make", "", "", "make clean"},
+		{"directory and host", "make", "/src", "a", "make test"},
+		{"directory on another host", "make", "/src", "c", "make run --fast"},
+		{"directory with the host unknown", "make", "/src", "", "make run --fast"},
+		{"directory before host", "make r", "/src", "a", "make run --fast"},
+		{"host", "make", "/home", "a", "make run"},
+		{"longer than the prefix", "make clean", "", "", ""},
+		{"no match", "git", "/src", "a", ""},
+		{"blank prefix", " ", "/src", "a", ""},
+		{"multi-line commands are skipped", "echo", "/src", "a", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Suggest(tt.prefix, tt.dir, tt.host); got != tt.want {
+				t.Errorf("Suggest(%q, %q, %q) = %q, want %q", tt.prefix, tt.dir, tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package monitor provides system resource monitoring.
+package monitor
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// CPUDetails holds detailed CPU usage
+type CPUDetails struct {
+	Model string
+	Cores []float64  // utilization of each logical core
+	Load  [3]float64 // load average over 1, 5 and 15 minutes
+}
+
+// MEMDetails holds detailed memory usage in bytes
+type MEMDetails struct {
+	Total     uint64
+	Used      uint64
+	Available uint64
+	SwapTotal uint64
+	SwapUsed  uint64
+}
+
+// Details holds detailed system resource information, shown by the
+// resource monitor
+type Details struct {
+	CPU      CPUDetails
+	MEM      MEMDetails
+	GPUs     []GPU
+	CPUError error
+	MEMError error
+	GPUError error
+}
+
+// FetchDetails retrieves detailed system resource usage
+func FetchDetails() Details {
+	d := Details{}
+	d.CPU, d.CPUError = fetchCPUDetails()
+	d.MEM, d.MEMError = fetchMEMDetails()
+	d.GPUs, d.GPUError = fetchGPUs()
+	return d
+}
+
+func fetchCPUDetails() (CPUDetails, error) {
+	var d CPUDetails
+	cores, err := cpu.Percent(100*time.Millisecond, true)
+	if err != nil {
+		return d, err
+	}
+	d.Cores = cores
+
+	// モデル名と負荷平均は取れなくてもよい
+	if info, err := cpu.Info(); err == nil && len(info) > 0 {
+		d.Model = info[0].ModelName
+	}
+	if avg, err := load.Avg(); err == nil && avg != nil {
+		d.Load = [3]float64{avg.Load1, avg.Load5, avg.Load15}
+	}
+	return d, nil
+}
+
+func fetchMEMDetails() (MEMDetails, error) {
+	var d MEMDetails
+	info, err := mem.VirtualMemory()
+	if err != nil {
+		return d, err
+	}
+	if info != nil {
+		d.Total, d.Used, d.Available = info.Total, info.Used, info.Available
+	}
+	if swap, err := mem.SwapMemory(); err == nil && swap != nil {
+		d.SwapTotal, d.SwapUsed = swap.Total, swap.Used
+	}
+	return d, nil
+}
@@ -0,0 +1,388 @@
+package server
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/session"
+)
+
+// How long a request waits for the reply of the server
+const requestTimeout = 5 * time.Second
+
+// ErrClosed is returned for requests on a closed connection
+var ErrClosed = errors.New("connection to the GoNeSh server closed")
+
+// Client is a connection to the server
+type Client struct {
+	conn net.Conn
+
+	// Guards the encoder, so that requests are written one at a time
+	encMu sync.Mutex
+	enc   *gob.Encoder
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]chan response
+	panes   map[int]*Pane
+	closed  bool
+	done    chan struct{}
+}
+
+// Dial connects to the server listening on the socket at path
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		enc:     gob.NewEncoder(conn),
+		pending: make(map[uint64]chan response),
+		panes:   make(map[int]*Pane),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close disconnects from the server. The panes keep running in the
+// server; the attached Pane values read io.EOF.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// Done returns a channel that is closed when the connection is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Spawn starts a shell in dir in a new pane of rows x cols cells and
+// attaches to it
+func (c *Client) Spawn(dir string, rows, cols int) (*Pane, error) {
+	resp, err := c.call(request{Op: opSpawn, Dir: dir, Rows: rows, Cols: cols})
+	if err != nil {
+		return nil, err
+	}
+	return c.pane(resp.Pane), nil
+}
+
+// Attach attaches to a pane of the server
+func (c *Client) Attach(id int) (*Pane, error) {
+	resp, err := c.call(request{Op: opAttach, Pane: id})
+	if err != nil {
+		return nil, err
+	}
+	return c.pane(resp.Pane), nil
+}
+
+// Panes lists the panes of the server
+func (c *Client) Panes() ([]PaneInfo, error) {
+	resp, err := c.call(request{Op: opList})
+	return resp.Panes, err
+}
+
+// Layout returns the layout saved by the last client, or nil
+func (c *Client) Layout() (*session.Layout, error) {
+	resp, err := c.call(request{Op: opGetLayout})
+	return resp.Layout, err
+}
+
+// SetLayout saves the layout of the tabs of the client in the server,
+// for clients attaching later
+func (c *Client) SetLayout(layout *session.Layout) error {
+	return c.write(request{Op: opSetLayout, Layout: layout})
+}
+
+// Shutdown stops the server and kills the shells of all panes
+func (c *Client) Shutdown() error {
+	_, err := c.call(request{Op: opShutdown})
+	if errors.Is(err, ErrClosed) {
+		// The server may close the connection before its reply is sent
+		return nil
+	}
+	return err
+}
+
+// pane returns the attached pane with the given ID
+func (c *Client) pane(id int) *Pane {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.panes[id]
+}
+
+// call sends a request and waits for the reply
+func (c *Client) call(req request) (response, error) {
+	ch := make(chan response, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return response{}, ErrClosed
+	}
+	c.seq++
+	req.Seq = c.seq
+	c.pending[req.Seq] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.Seq)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(req); err != nil {
+		return response{}, err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return resp, errors.New(resp.Error)
+		}
+		return resp, nil
+	case <-c.done:
+		return response{}, ErrClosed
+	case <-time.After(requestTimeout):
+		return response{}, errors.New("GoNeSh server did not reply")
+	}
+}
+
+// write sends a request without waiting for a reply
+func (c *Client) write(req request) error {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	return c.enc.Encode(req)
+}
+
+// readLoop dispatches the replies and events of the server until the
+// connection is closed
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		panes := c.panes
+		c.panes = nil
+		c.mu.Unlock()
+		for _, p := range panes {
+			p.exit()
+		}
+		close(c.done)
+		_ = c.conn.Close()
+	}()
+
+	dec := gob.NewDecoder(c.conn)
+	for {
+		var resp response
+		if err := dec.Decode(&resp); err != nil {
+			return
+		}
+
+		switch resp.Op {
+		case opOutput:
+			if p := c.pane(resp.Pane); p != nil {
+				p.push(resp.Data)
+			}
+			continue
+		case opInfo:
+			if p := c.pane(resp.Pane); p != nil {
+				p.setInfo(resp.Info)
+			}
+			continue
+		case opExit:
+			c.mu.Lock()
+			p := c.panes[resp.Pane]
+			delete(c.panes, resp.Pane)
+			c.mu.Unlock()
+			if p != nil {
+				p.exit()
+			}
+			continue
+		}
+
+		// The pane is registered before the events that follow the reply
+		// are read
+		if (resp.Op == opSpawn || resp.Op == opAttach) && resp.Error == "" && resp.Screen != nil {
+			p := &Pane{
+				c:      c,
+				id:     resp.Pane,
+				screen: *resp.Screen,
+				info:   resp.Info,
+				ready:  make(chan struct{}, 1),
+				exited: make(chan struct{}),
+				closed: make(chan struct{}),
+			}
+			c.mu.Lock()
+			c.panes[p.id] = p
+			c.mu.Unlock()
+		}
+
+		c.mu.Lock()
+		ch := c.pending[resp.Seq]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+}
+
+// Pane is a pane of the server a client is attached to. It is the shell
+// session of a terminal of the client (see terminal.Session).
+type Pane struct {
+	c      *Client
+	id     int
+	screen emulator.Snapshot
+
+	// Output not read yet. It is queued without a limit, so that a pane
+	// whose output is not read does not hold up the replies of the server
+	// and the other panes; the server disconnects a client that falls far
+	// behind.
+	outMu  sync.Mutex
+	queue  [][]byte
+	ready  chan struct{} // Signaled when output is queued
+	rest   []byte
+	exited chan struct{}
+	closed chan struct{}
+	// Closed by exit and Close
+	exitOnce  sync.Once
+	closeOnce sync.Once
+
+	// Processes of the pane, sent by the server when they change
+	infoMu sync.Mutex
+	info   PaneInfo
+}
+
+// ID returns the ID of the pane in the server
+func (p *Pane) ID() int {
+	return p.id
+}
+
+// Screen returns the screen of the pane when the client attached to it
+func (p *Pane) Screen() emulator.Snapshot {
+	return p.screen
+}
+
+// Read reads the output of the pane. It returns io.EOF once the shell has
+// exited or the client has disconnected.
+func (p *Pane) Read(buf []byte) (int, error) {
+	for len(p.rest) == 0 {
+		if data, ok := p.pop(); ok {
+			p.rest = data
+			continue
+		}
+		select {
+		case <-p.ready:
+		case <-p.exited:
+			// Output sent before the exit is read first
+			data, ok := p.pop()
+			if !ok {
+				return 0, io.EOF
+			}
+			p.rest = data
+		}
+	}
+	n := copy(buf, p.rest)
+	p.rest = p.rest[n:]
+	return n, nil
+}
+
+// push queues output of the pane for Read. Output of a closed pane is no
+// longer read and is dropped.
+func (p *Pane) push(data []byte) {
+	select {
+	case <-p.closed:
+		return
+	default:
+	}
+	p.outMu.Lock()
+	p.queue = append(p.queue, data)
+	p.outMu.Unlock()
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the oldest output from the queue
+func (p *Pane) pop() ([]byte, bool) {
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if len(p.queue) == 0 {
+		return nil, false
+	}
+	data := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+	return data, true
+}
+
+// Write writes to the input of the pane
+func (p *Pane) Write(data []byte) (int, error) {
+	if err := p.c.write(request{Op: opInput, Pane: p.id, Data: data}); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Resize resizes the pane
+func (p *Pane) Resize(rows, cols uint16) error {
+	return p.c.write(request{Op: opResize, Pane: p.id, Rows: int(rows), Cols: int(cols)})
+}
+
+// Close closes the pane in the server and kills its processes
+func (p *Pane) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+	return p.c.write(request{Op: opKill, Pane: p.id})
+}
+
+// ForegroundProcess returns the name of the process in the foreground of
+// the pane, or "" if unknown. The server sends it shortly after it
+// changes, so it does not wait for the server.
+func (p *Pane) ForegroundProcess() string {
+	return p.Info().Process
+}
+
+// ForegroundCommand returns the command line of the process in the
+// foreground of the pane, or nil if unknown
+func (p *Pane) ForegroundCommand() []string {
+	return p.Info().Command
+}
+
+// WorkingDir returns the working directory of the shell of the pane, or
+// "" if unknown
+func (p *Pane) WorkingDir() string {
+	return p.Info().Dir
+}
+
+// Info returns the processes of the pane as last sent by the server
+func (p *Pane) Info() PaneInfo {
+	p.infoMu.Lock()
+	defer p.infoMu.Unlock()
+	return p.info
+}
+
+// setInfo sets the processes of the pane
+func (p *Pane) setInfo(info PaneInfo) {
+	p.infoMu.Lock()
+	p.info = info
+	p.infoMu.Unlock()
+}
+
+// exit marks the pane as exited
+func (p *Pane) exit() {
+	p.exitOnce.Do(func() {
+		close(p.exited)
+	})
+}
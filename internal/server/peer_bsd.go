@@ -0,0 +1,32 @@
+//go:build darwin || freebsd
+
+package server
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// samePeerUser reports whether the process at the other end of a
+// connection runs as the same user as the server. Connections whose user
+// cannot be looked up are refused.
+func samePeerUser(conn net.Conn) bool {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return false
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var cred *unix.Xucred
+	err = raw.Control(func(fd uintptr) {
+		cred, err = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	})
+	if err != nil || cred == nil {
+		return false
+	}
+	return int(cred.Uid) == os.Getuid()
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// Connections of a user other than the one running the server are refused
+func TestRefuseOtherUsers(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("connecting as another user needs root")
+	}
+	_, path := startServer(t)
+
+	// Let the other user reach the socket, which otherwise only its
+	// directory keeps away
+	for dir := filepath.Dir(path); dir != filepath.Clean(os.TempDir()) && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := dialAs(path, 65534)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Panes(); err == nil {
+		t.Error("the server replied to another user")
+	}
+
+	if _, err := dial(t, path).Panes(); err != nil {
+		t.Errorf("the server refused its own user: %v", err)
+	}
+}
+
+// dialAs connects to the server as another user. Only the effective user
+// ID of the calling thread is changed; the server sees it as the user of
+// the connection.
+func dialAs(path string, uid int) (*Client, error) {
+	type result struct {
+		c   *Client
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		// The thread is not unlocked, so that it exits with the goroutine
+		// if its user cannot be restored
+		runtime.LockOSThread()
+		keep := ^uintptr(0) // -1 leaves an ID as it is
+		if _, _, errno := unix.RawSyscall(unix.SYS_SETRESUID, keep, uintptr(uid), keep); errno != 0 {
+			ch <- result{err: errno}
+			return
+		}
+		c, err := Dial(path)
+		_, _, _ = unix.RawSyscall(unix.SYS_SETRESUID, keep, 0, keep)
+		ch <- result{c, err}
+	}()
+	r := <-ch
+	return r.c, r.err
+}
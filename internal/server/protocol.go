@@ -0,0 +1,92 @@
+// Package server runs shell sessions in a background process, the GoNeSh
+// server, so that they outlive the terminal window showing them.
+//
+// The server owns the PTYs and keeps a screen model of each, fed by its
+// output. Clients connect over a Unix socket. A client that attaches to a
+// pane receives a snapshot of the screen and then the output of the pane
+// as it arrives, so that its own screen model stays in step with the one
+// of the server. Messages are encoded with encoding/gob in both
+// directions.
+package server
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/session"
+)
+
+const (
+	// SocketDir is the directory of the socket under ~/.gonesh. It is only
+	// accessible to the user, since anyone who can connect to the server
+	// can run commands as the user.
+	SocketDir = "run"
+	// SocketName is the name of the socket of the server in SocketDir
+	SocketName = "server.sock"
+)
+
+// SocketPath returns the path of the socket of the server
+func SocketPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gonesh", SocketDir, SocketName), nil
+}
+
+// op is the kind of a request or an event
+type op uint8
+
+const (
+	// Requests of clients
+	opSpawn     op = iota + 1 // Start a shell in a new pane and attach to it
+	opAttach                  // Attach to a pane
+	opInput                   // Write to a pane
+	opResize                  // Resize a pane
+	opKill                    // Close a pane and kill its processes
+	opList                    // List the panes
+	opGetLayout               // Get the layout saved by a client
+	opSetLayout               // Save the layout of the tabs of a client
+	opShutdown                // Close all panes and stop the server
+
+	// Events sent to attached clients
+	opOutput // Output of a pane
+	opInfo   // The processes of a pane changed
+	opExit   // The shell of a pane exited
+)
+
+// request is sent by a client to the server. The server replies to
+// requests with a non-zero Seq.
+type request struct {
+	Seq    uint64
+	Op     op
+	Pane   int
+	Dir    string
+	Data   []byte
+	Rows   int
+	Cols   int
+	Layout *session.Layout
+}
+
+// response is a reply to a request, with its Seq, or an event about a
+// pane, with Seq 0
+type response struct {
+	Seq    uint64
+	Op     op
+	Pane   int
+	Data   []byte
+	Error  string
+	Screen *emulator.Snapshot
+	Info   PaneInfo
+	Panes  []PaneInfo
+	Layout *session.Layout
+}
+
+// PaneInfo describes a pane of the server
+type PaneInfo struct {
+	ID      int
+	Process string   // Name of the foreground process
+	Command []string // Command line of the foreground process
+	Dir     string   // Working directory of the shell
+}
@@ -0,0 +1,447 @@
+package server
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/session"
+	"github.com/ousiass/GoNeSh/internal/terminal"
+)
+
+const (
+	// Scrollback lines kept for each pane
+	maxScrollback = 10000
+	// Buffer size for reading PTY output
+	readBufferSize = 4096
+	// How long after output the processes of a pane are looked up. They
+	// often change just after the output that announced them.
+	infoCheckDelay = 200 * time.Millisecond
+	// Messages queued for a client. A client that falls this far behind is
+	// disconnected, so that it does not hold up the panes; attaching again
+	// restores its screens.
+	clientQueueSize = 1024
+)
+
+// ErrRunning is returned by Listen when a server is already running on
+// the socket
+var ErrRunning = errors.New("a GoNeSh server is already running")
+
+// Server owns shell sessions and serves them to clients
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	panes   map[int]*pane
+	lastID  int
+	layout  *session.Layout
+	clients map[*client]struct{}
+	closed  bool
+	done    chan struct{}
+}
+
+// pane is a shell session of the server and its screen model
+type pane struct {
+	id  int
+	pty *terminal.PTY
+
+	// Input of the clients and replies to device queries, written by a
+	// goroutine of its own until done is closed
+	input *terminal.InputQueue
+	done  chan struct{}
+
+	// Guards the screen model and the attached clients. Output is fed to
+	// the screen and sent to the clients under the lock, so that a client
+	// attaching gets a snapshot that the output sent afterwards continues.
+	mu      sync.Mutex
+	emu     *emulator.Emulator
+	clients map[*client]struct{}
+
+	// Processes last sent to the clients, and whether a look up is due
+	sentInfo  PaneInfo
+	infoCheck bool
+}
+
+// client is a connection of a client
+type client struct {
+	conn net.Conn
+	out  chan response
+	done chan struct{}
+	once sync.Once
+}
+
+// Listen creates a server listening on the socket at path. A socket left
+// behind by a server that is no longer running is replaced.
+func Listen(path string) (*Server, error) {
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return nil, ErrRunning
+	}
+	// A socket nobody listens on is left behind by a server that crashed
+	stale := errors.Is(err, syscall.ECONNREFUSED)
+	// The directory keeps other users away from the socket, also before
+	// its own permissions are set
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if stale {
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &Server{
+		ln:      ln,
+		panes:   make(map[int]*pane),
+		clients: make(map[*client]struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Serve accepts clients until the server is closed
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		// Only processes of the user running the server may connect
+		if !samePeerUser(conn) {
+			conn.Close()
+			continue
+		}
+		c := &client{
+			conn: conn,
+			out:  make(chan response, clientQueueSize),
+			done: make(chan struct{}),
+		}
+		s.mu.Lock()
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+		go c.writeLoop()
+		go s.readLoop(c)
+	}
+}
+
+// Done returns a channel that is closed when the server is closed
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the server, disconnects the clients and kills the shells of
+// all panes
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	panes := make([]*pane, 0, len(s.panes))
+	for _, p := range s.panes {
+		panes = append(panes, p)
+	}
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	err := s.ln.Close()
+	for _, p := range panes {
+		_ = p.pty.Close()
+	}
+	for _, c := range clients {
+		c.close()
+	}
+	return err
+}
+
+// readLoop handles the requests of a client until it disconnects
+func (s *Server) readLoop(c *client) {
+	defer s.disconnect(c)
+
+	dec := gob.NewDecoder(c.conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		resp := s.handle(c, req)
+		if req.Seq != 0 && resp != nil {
+			resp.Seq = req.Seq
+			resp.Op = req.Op
+			c.send(*resp)
+		}
+	}
+}
+
+// disconnect detaches a client from its panes and closes its connection
+func (s *Server) disconnect(c *client) {
+	c.close()
+	s.mu.Lock()
+	delete(s.clients, c)
+	panes := make([]*pane, 0, len(s.panes))
+	for _, p := range s.panes {
+		panes = append(panes, p)
+	}
+	s.mu.Unlock()
+
+	for _, p := range panes {
+		p.mu.Lock()
+		delete(p.clients, c)
+		p.mu.Unlock()
+	}
+}
+
+// handle handles a request and returns the reply. Replies to attaching
+// requests are sent by handle itself, before any output of the pane, and
+// nil is returned for them.
+func (s *Server) handle(c *client, req request) *response {
+	switch req.Op {
+	case opSpawn:
+		p, err := s.spawn(req.Dir, req.Rows, req.Cols)
+		if err != nil {
+			return &response{Error: err.Error()}
+		}
+		s.attach(c, p, req)
+		go s.paneLoop(p)
+		return nil
+
+	case opAttach:
+		p := s.pane(req.Pane)
+		if p == nil {
+			return &response{Error: fmt.Sprintf("no pane %d", req.Pane)}
+		}
+		s.attach(c, p, req)
+		return nil
+
+	case opInput:
+		if p := s.pane(req.Pane); p != nil {
+			_, _ = p.input.Write(req.Data)
+		}
+
+	case opResize:
+		if p := s.pane(req.Pane); p != nil && req.Rows > 0 && req.Cols > 0 {
+			p.mu.Lock()
+			p.emu.Resize(req.Cols, req.Rows)
+			p.mu.Unlock()
+			_ = p.pty.Resize(uint16(req.Rows), uint16(req.Cols))
+		}
+
+	case opKill:
+		if p := s.pane(req.Pane); p != nil {
+			_ = p.pty.Close()
+		}
+
+	case opList:
+		s.mu.Lock()
+		panes := make([]*pane, 0, len(s.panes))
+		for _, p := range s.panes {
+			panes = append(panes, p)
+		}
+		s.mu.Unlock()
+		resp := &response{}
+		for _, p := range panes {
+			resp.Panes = append(resp.Panes, p.info())
+		}
+		return resp
+
+	case opGetLayout:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return &response{Layout: s.layout}
+
+	case opSetLayout:
+		s.mu.Lock()
+		s.layout = req.Layout
+		s.mu.Unlock()
+
+	case opShutdown:
+		go s.Close()
+	}
+	return &response{}
+}
+
+// spawn starts a shell in a new pane
+func (s *Server) spawn(dir string, rows, cols int) (*pane, error) {
+	if rows <= 0 || cols <= 0 {
+		rows, cols = 24, 80
+	}
+	pty, err := terminal.NewInDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	_ = pty.Resize(uint16(rows), uint16(cols))
+
+	p := &pane{
+		pty:     pty,
+		input:   terminal.NewInputQueue(),
+		done:    make(chan struct{}),
+		emu:     emulator.New(cols, rows, maxScrollback),
+		clients: make(map[*client]struct{}),
+	}
+	// Device queries are answered here, also while no client is attached
+	p.emu.SetReplyWriter(p.input)
+	go p.input.Run(pty, p.done)
+
+	s.mu.Lock()
+	s.lastID++
+	p.id = s.lastID
+	s.panes[p.id] = p
+	s.mu.Unlock()
+	return p, nil
+}
+
+// attach sends the screen and the processes of a pane to a client, and
+// then the output of the pane and changes of its processes from there on
+func (s *Server) attach(c *client, p *pane, req request) {
+	info := p.info()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clients == nil {
+		// The shell exited after the pane was looked up
+		c.send(response{Seq: req.Seq, Op: req.Op, Error: fmt.Sprintf("no pane %d", p.id)})
+		return
+	}
+	screen := p.emu.Snapshot()
+	p.clients[c] = struct{}{}
+	c.send(response{Seq: req.Seq, Op: req.Op, Pane: p.id, Screen: &screen, Info: info})
+}
+
+// pane returns the pane with the given ID, or nil
+func (s *Server) pane(id int) *pane {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.panes[id]
+}
+
+// paneLoop feeds the output of a pane to its screen and its clients until
+// the shell exits
+func (s *Server) paneLoop(p *pane) {
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := p.pty.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			p.mu.Lock()
+			_, _ = p.emu.Write(data)
+			for c := range p.clients {
+				c.send(response{Op: opOutput, Pane: p.id, Data: data})
+			}
+			if !p.infoCheck {
+				p.infoCheck = true
+				time.AfterFunc(infoCheckDelay, p.checkInfo)
+			}
+			p.mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	_ = p.pty.Close()
+	close(p.done)
+	s.mu.Lock()
+	delete(s.panes, p.id)
+	s.mu.Unlock()
+
+	p.mu.Lock()
+	for c := range p.clients {
+		c.send(response{Op: opExit, Pane: p.id})
+	}
+	p.clients = nil
+	p.mu.Unlock()
+}
+
+// checkInfo sends the processes of the pane to its clients if they
+// changed since last sent
+func (p *pane) checkInfo() {
+	info := p.info()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.infoCheck = false
+	if p.clients == nil || reflect.DeepEqual(info, p.sentInfo) {
+		return
+	}
+	p.sentInfo = info
+	for c := range p.clients {
+		c.send(response{Op: opInfo, Pane: p.id, Info: info})
+	}
+}
+
+// info describes the pane
+func (p *pane) info() PaneInfo {
+	return PaneInfo{
+		ID:      p.id,
+		Process: p.pty.ForegroundProcess(),
+		Command: p.pty.ForegroundCommand(),
+		Dir:     p.pty.WorkingDir(),
+	}
+}
+
+// send queues a message for the client without blocking. A client whose
+// queue is full is disconnected.
+func (c *client) send(resp response) {
+	select {
+	case c.out <- resp:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+// writeLoop sends the queued messages to the client
+func (c *client) writeLoop() {
+	enc := gob.NewEncoder(c.conn)
+	for {
+		select {
+		case resp := <-c.out:
+			if err := enc.Encode(resp); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close closes the connection of the client
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
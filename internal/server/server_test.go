@@ -0,0 +1,340 @@
+package server
+
+import (
+	"encoding/gob"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/session"
+)
+
+// How long the tests wait for the shells
+const testTimeout = 60 * time.Second
+
+// startServer starts a server on a socket in a new directory, running
+// /bin/sh in its panes
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	t.Setenv("SHELL", "/bin/sh")
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), SocketDir, SocketName)
+	srv, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve() }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv, path
+}
+
+// dial connects a client to the server
+func dial(t *testing.T, path string) *Client {
+	t.Helper()
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// output collects the output of a pane in the background
+type output struct {
+	mu   sync.Mutex
+	data []byte
+	eof  chan struct{}
+}
+
+func readOutput(p *Pane) *output {
+	o := &output{eof: make(chan struct{})}
+	go func() {
+		defer close(o.eof)
+		buf := make([]byte, 4096)
+		for {
+			n, err := p.Read(buf)
+			o.mu.Lock()
+			o.data = append(o.data, buf[:n]...)
+			o.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return o
+}
+
+// bytes returns the output collected so far
+func (o *output) bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.data)
+}
+
+// waitFor waits until the output contains s
+func (o *output) waitFor(t *testing.T, s string) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	from := 0
+	for time.Now().Before(deadline) {
+		o.mu.Lock()
+		found := strings.Contains(string(o.data[from:]), s)
+		from = max(0, len(o.data)-len(s))
+		o.mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("output does not contain %q", s)
+}
+
+// waitForEOF waits until the pane has exited
+func (o *output) waitForEOF(t *testing.T) {
+	t.Helper()
+	select {
+	case <-o.eof:
+	case <-time.After(testTimeout):
+		t.Fatal("the pane did not exit")
+	}
+}
+
+// screen returns the screen of a pane: the screen when the client
+// attached, followed by the output since
+func screen(p *Pane, o *output) []string {
+	e := emulator.New(1, 1, 0)
+	e.Restore(p.Screen())
+	_, _ = e.Write(o.bytes())
+	_, rows := e.Size()
+	lines := make([]string, rows)
+	for y := range lines {
+		lines[y] = e.Line(y).String()
+	}
+	return lines
+}
+
+func TestAttachRestoresScreen(t *testing.T) {
+	_, path := startServer(t)
+
+	c1 := dial(t, path)
+	p1, err := c1.Spawn(t.TempDir(), 6, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out1 := readOutput(p1)
+	// The echo of the command does not contain the output
+	if _, err := p1.Write([]byte("printf 'one%s\\n' 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	out1.waitFor(t, "one1")
+
+	// A client attaching later gets the screen so far
+	c2 := dial(t, path)
+	p2, err := c2.Attach(p1.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols, rows := p2.Screen().Cols, p2.Screen().Rows; cols != 40 || rows != 6 {
+		t.Errorf("screen size = %dx%d, want 40x6", cols, rows)
+	}
+	if got := screen(p2, &output{}); !slices.ContainsFunc(got, func(l string) bool {
+		return strings.Contains(l, "one1")
+	}) {
+		t.Errorf("attached screen = %q, want the earlier output", got)
+	}
+
+	// and then the output, so that both clients show the same screen
+	out2 := readOutput(p2)
+	if _, err := p2.Write([]byte("printf 'two%s\\n' 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	out1.waitFor(t, "two2")
+	out2.waitFor(t, "two2")
+	deadline := time.Now().Add(testTimeout)
+	for {
+		s1, s2 := screen(p1, out1), screen(p2, out2)
+		if slices.Equal(s1, s2) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("screens differ:\n%q\n%q", s1, s2)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAttachUnknownPane(t *testing.T) {
+	_, path := startServer(t)
+	if _, err := dial(t, path).Attach(42); err == nil {
+		t.Error("attached to a pane that does not exist")
+	}
+}
+
+func TestPanesAndLayout(t *testing.T) {
+	srv, path := startServer(t)
+	c1 := dial(t, path)
+	p, err := c1.Spawn(t.TempDir(), 24, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out1 := readOutput(p)
+
+	c2 := dial(t, path)
+	panes, err := c2.Panes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(panes) != 1 || panes[0].ID != p.ID() {
+		t.Errorf("panes = %+v, want pane %d", panes, p.ID())
+	}
+
+	layout := &session.Layout{
+		Tabs: []session.Tab{{Name: "work", Type: "local", Root: session.Pane{Server: p.ID()}}},
+	}
+	if err := c1.SetLayout(layout); err != nil {
+		t.Fatal(err)
+	}
+	// Requests are handled in order, so the layout is set once c1 has a
+	// reply to a later request
+	if _, err := c1.Panes(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c2.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || len(got.Tabs) != 1 || got.Tabs[0].Name != "work" || got.Tabs[0].Root.Server != p.ID() {
+		t.Errorf("layout = %+v, want %+v", got, layout)
+	}
+
+	// Closing the pane ends it for the other attached clients as well
+	p2, err := c2.Attach(p.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2 := readOutput(p2)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out2.waitForEOF(t)
+	out1.waitForEOF(t)
+	if panes, err := c2.Panes(); err != nil || len(panes) != 0 {
+		t.Errorf("panes after closing = %+v, %v, want none", panes, err)
+	}
+
+	if err := c2.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-srv.Done():
+	case <-time.After(testTimeout):
+		t.Fatal("the server did not stop")
+	}
+	select {
+	case <-c1.Done():
+	case <-time.After(testTimeout):
+		t.Fatal("the server did not disconnect the clients")
+	}
+}
+
+func TestSendDisconnectsFullQueue(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	c := &client{conn: conn, out: make(chan response, 2), done: make(chan struct{})}
+
+	c.send(response{Op: opOutput})
+	c.send(response{Op: opOutput})
+	select {
+	case <-c.done:
+		t.Fatal("disconnected before the queue was full")
+	default:
+	}
+
+	c.send(response{Op: opOutput})
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("not disconnected with a full queue")
+	}
+	if _, err := peer.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read from the closed connection = %v, want EOF", err)
+	}
+}
+
+func TestSlowClientIsDisconnected(t *testing.T) {
+	srv, path := startServer(t)
+	c := dial(t, path)
+	p, err := c.Spawn(t.TempDir(), 24, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := readOutput(p)
+
+	// A client that attaches and then reads nothing
+	slow, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	if err := gob.NewEncoder(slow).Encode(request{Seq: 1, Op: opAttach, Pane: p.ID()}); err != nil {
+		t.Fatal(err)
+	}
+	attached := func() bool {
+		sp := srv.pane(p.ID())
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		return len(sp.clients) == 2
+	}
+	for deadline := time.Now().Add(testTimeout); !attached(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the slow client did not attach")
+		}
+	}
+
+	// More output than its queue and the socket buffers hold. The pane is
+	// not held up by the slow client.
+	if _, err := p.Write([]byte("yes | head -n 2000000; printf 'done%s\\n' 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	out.waitFor(t, "done1")
+
+	if err := slow.SetReadDeadline(time.Now().Add(testTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, slow); err != nil {
+		t.Errorf("the slow client was not disconnected: %v", err)
+	}
+}
+
+func TestUnreadPaneDoesNotBlockReplies(t *testing.T) {
+	_, path := startServer(t)
+	c := dial(t, path)
+	p, err := c.Spawn(t.TempDir(), 24, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Output in many more chunks than a pane used to queue, none of it read
+	if _, err := p.Write([]byte("yes | head -n 200000; printf 'done%s\\n' 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		if _, err := c.Panes(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The output is all there when it is read
+	out := readOutput(p)
+	out.waitFor(t, "done1")
+	if n := strings.Count(string(out.bytes()), "y\r\n"); n != 200000 {
+		t.Errorf("read %d lines of yes, want 200000", n)
+	}
+}
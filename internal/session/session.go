@@ -0,0 +1,170 @@
+// Package session saves and restores the layout of GoNeSh sessions.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DirName is the directory under ~/.gonesh that holds saved sessions
+const DirName = "sessions"
+
+// Split directions of a pane
+const (
+	SplitRight = "right"
+	SplitDown  = "down"
+)
+
+// validName matches the names a session can be saved under, so that the
+// name is also a safe file name
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrInvalidName is returned for a session name that is not a plain file
+// name
+var ErrInvalidName = errors.New("session names may only contain letters, digits, '.', '_' and '-'")
+
+// Layout is the saved state of a session: its tabs and their panes
+type Layout struct {
+	Tabs      []Tab `yaml:"tabs"`
+	ActiveTab int   `yaml:"active_tab"`
+}
+
+// Tab is a saved tab
+type Tab struct {
+	// Name given by the user; empty for a tab labelled with its title
+	Name string `yaml:"name,omitempty"`
+	Type string `yaml:"type"` // local or ssh
+	Root Pane   `yaml:"root"`
+}
+
+// Pane is a node of the saved layout tree of a tab. A leaf has the state
+// of its shell; a split has two children.
+type Pane struct {
+	Dir     string   `yaml:"dir,omitempty"`     // Working directory of the shell
+	SSH     []string `yaml:"ssh,omitempty"`     // ssh command running in the shell
+	Server  int      `yaml:"server,omitempty"`  // ID of the pane in the GoNeSh server
+	Focused bool     `yaml:"focused,omitempty"` // Focused pane of the tab
+
+	Split    string  `yaml:"split,omitempty"` // SplitRight or SplitDown
+	Ratio    float64 `yaml:"ratio,omitempty"` // Share of the first child
+	Children []Pane  `yaml:"children,omitempty"`
+}
+
+// IsLeaf returns whether the pane shows a shell
+func (p Pane) IsLeaf() bool {
+	return len(p.Children) == 0
+}
+
+// Prune returns the tree without the leaves for which keep returns false.
+// A split left with one child is replaced by it. ok is false if no leaf
+// is kept.
+func (p Pane) Prune(keep func(Pane) bool) (pruned Pane, ok bool) {
+	if p.IsLeaf() {
+		return p, keep(p)
+	}
+	first, ok1 := p.Children[0].Prune(keep)
+	second, ok2 := p.Children[1].Prune(keep)
+	switch {
+	case ok1 && ok2:
+		p.Children = []Pane{first, second}
+		return p, true
+	case ok1:
+		return first, true
+	case ok2:
+		return second, true
+	}
+	return Pane{}, false
+}
+
+// Path returns the file a session is saved in
+func Path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gonesh", DirName, name+".yaml"), nil
+}
+
+// Load reads a saved session. The error wraps fs.ErrNotExist if the
+// session has not been saved yet.
+func Load(name string) (*Layout, error) {
+	path, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var layout Layout
+	if err := yaml.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, tab := range layout.Tabs {
+		if err := tab.Root.validate(); err != nil {
+			return nil, fmt.Errorf("%s: tab %d: %w", path, i+1, err)
+		}
+	}
+	return &layout, nil
+}
+
+// validate checks that every split of the tree has two children
+func (p Pane) validate() error {
+	if p.IsLeaf() {
+		return nil
+	}
+	if len(p.Children) != 2 {
+		return fmt.Errorf("a split needs 2 panes, got %d", len(p.Children))
+	}
+	if p.Split != SplitRight && p.Split != SplitDown {
+		return fmt.Errorf("unknown split %q", p.Split)
+	}
+	for _, c := range p.Children {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes a session, replacing the saved one.
+// The file is written next to it and renamed over it, so that a crash
+// leaves either the old or the new file.
+func Save(name string, layout *Layout) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := yaml.NewEncoder(tmp)
+	enc.SetIndent(2)
+	if err := enc.Encode(layout); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
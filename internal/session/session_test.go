@@ -0,0 +1,193 @@
+package session
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSession writes a session file in a new home directory
+func writeSession(t *testing.T, name, data string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	path, err := Path(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string // Part of the error, "" for none
+	}{
+		{
+			name: "leaf",
+			data: "tabs:\n  - type: local\n    root:\n      dir: /tmp\n",
+		},
+		{
+			name: "split",
+			data: "tabs:\n  - type: local\n    root:\n      split: right\n      ratio: 0.5\n" +
+				"      children:\n        - dir: /a\n        - dir: /b\n",
+		},
+		{
+			name: "split with one pane",
+			data: "tabs:\n  - type: local\n    root:\n      split: down\n      children:\n        - dir: /a\n",
+			err:  "tab 1: a split needs 2 panes, got 1",
+		},
+		{
+			name: "split with three panes",
+			data: "tabs:\n  - type: local\n    root:\n      split: down\n" +
+				"      children:\n        - dir: /a\n        - dir: /b\n        - dir: /c\n",
+			err: "a split needs 2 panes, got 3",
+		},
+		{
+			name: "unknown split direction",
+			data: "tabs:\n  - type: local\n  - type: local\n    root:\n      split: diagonal\n" +
+				"      children:\n        - dir: /a\n        - dir: /b\n",
+			err: `tab 2: unknown split "diagonal"`,
+		},
+		{
+			name: "nested invalid split",
+			data: "tabs:\n  - type: local\n    root:\n      split: right\n      children:\n        - dir: /a\n" +
+				"        - split: down\n          children:\n            - dir: /b\n",
+			err: "a split needs 2 panes, got 1",
+		},
+		{
+			name: "not YAML",
+			data: "tabs: [\n",
+			err:  "yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeSession(t, "work", tt.data)
+			_, err := Load("work")
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Load: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Load error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadNotSaved(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := Load("work"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestInvalidName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"", "../work", "a/b", ".hidden", "-x", "a b"} {
+		if _, err := Path(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Path(%q) error = %v, want ErrInvalidName", name, err)
+		}
+		if err := Save(name, &Layout{}); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Save(%q) error = %v, want ErrInvalidName", name, err)
+		}
+		if _, err := Load(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Load(%q) error = %v, want ErrInvalidName", name, err)
+		}
+	}
+	for _, name := range []string{"work", "a.b_c-1", "0"} {
+		if _, err := Path(name); err != nil {
+			t.Errorf("Path(%q) error = %v", name, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	leaf := func(dir string) Pane { return Pane{Dir: dir} }
+	split := func(a, b Pane) Pane { return Pane{Split: SplitRight, Ratio: 0.5, Children: []Pane{a, b}} }
+	tree := split(leaf("/a"), split(leaf("/b"), leaf("/c")))
+
+	tests := []struct {
+		name string
+		keep []string
+		want Pane
+		ok   bool
+	}{
+		{"all kept", []string{"/a", "/b", "/c"}, tree, true},
+		{"split collapses to its other child", []string{"/a", "/c"}, split(leaf("/a"), leaf("/c")), true},
+		{"collapses to one leaf", []string{"/b"}, leaf("/b"), true},
+		{"collapses to a split", []string{"/b", "/c"}, split(leaf("/b"), leaf("/c")), true},
+		{"none kept", nil, Pane{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tree.Prune(func(p Pane) bool {
+				for _, dir := range tt.keep {
+					if p.Dir == dir {
+						return true
+					}
+				}
+				return false
+			})
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prune = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	layout := &Layout{
+		Tabs: []Tab{
+			{Type: "local", Root: Pane{Dir: "/tmp", Focused: true}},
+			{
+				Name: "servers",
+				Type: "ssh",
+				Root: Pane{
+					Split: SplitDown,
+					Ratio: 0.3,
+					Children: []Pane{
+						{SSH: []string{"ssh", "-p", "2222", "host"}, Server: 3},
+						{Dir: "/srv", Focused: true},
+					},
+				},
+			},
+		},
+		ActiveTab: 1,
+	}
+	if err := Save("work", layout); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again replaces the session
+	if err := Save("work", layout); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, layout) {
+		t.Errorf("loaded %+v, want %+v", got, layout)
+	}
+
+	// No temporary files are left behind
+	path, _ := Path("work")
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("session directory has %d files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"io"
+	"sync"
+)
+
+// InputQueue passes input to a shell session from a goroutine of its own.
+// A shell that stops reading its input (for example while its output is
+// not drained) blocks writes to the PTY, so writing must not hold a lock
+// that reading the output needs. Writes are queued without limit and sent
+// in order.
+type InputQueue struct {
+	mu      sync.Mutex
+	pending [][]byte
+	ready   chan struct{}
+}
+
+// NewInputQueue creates an empty input queue
+func NewInputQueue() *InputQueue {
+	return &InputQueue{ready: make(chan struct{}, 1)}
+}
+
+// Write queues data for the session. It never blocks.
+func (q *InputQueue) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	q.mu.Lock()
+	q.pending = append(q.pending, append([]byte(nil), data...))
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return len(data), nil
+}
+
+// Run writes the queued input to w until done is closed. Input that w
+// fails to take is dropped.
+func (q *InputQueue) Run(w io.Writer, done <-chan struct{}) {
+	for {
+		select {
+		case <-q.ready:
+		case <-done:
+			return
+		}
+
+		q.mu.Lock()
+		pending := q.pending
+		q.pending = nil
+		q.mu.Unlock()
+
+		for _, data := range pending {
+			_, _ = w.Write(data)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package terminal
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Shell integration scripts, which mark prompts and commands with OSC 133
+// and report the working directory with OSC 7
+//
+//go:embed shell
+var shellScripts embed.FS
+
+// integrationFiles maps the embedded scripts to where they are written in
+// the integration directory
+var integrationFiles = map[string]string{
+	"shell/integration.bash": "gonesh.bash",
+	"shell/integration.fish": "gonesh.fish",
+	"shell/integration.zsh":  "zsh/gonesh.zsh",
+	"shell/zshenv.zsh":       "zsh/.zshenv",
+	"shell/zshrc.zsh":        "zsh/.zshrc",
+}
+
+var (
+	integrationOnce sync.Once
+	integrationPath string
+	integrationErr  error
+)
+
+// integrationDir returns the directory holding the shell integration
+// scripts, writing them on first use
+func integrationDir() (string, error) {
+	integrationOnce.Do(func() {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			base = os.TempDir()
+		}
+		dir := filepath.Join(base, "gonesh", "shell")
+
+		for src, dst := range integrationFiles {
+			data, err := shellScripts.ReadFile(src)
+			if err != nil {
+				integrationErr = err
+				return
+			}
+			path := filepath.Join(dir, dst)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				integrationErr = err
+				return
+			}
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				integrationErr = err
+				return
+			}
+		}
+		integrationPath = dir
+	})
+	return integrationPath, integrationErr
+}
+
+// shellIntegration returns the arguments and extra environment that load
+// the shell integration into shell. Shells without integration get none.
+func shellIntegration(shell string) (args, env []string) {
+	name := filepath.Base(shell)
+	if name != "bash" && name != "zsh" && name != "fish" {
+		return nil, nil
+	}
+
+	dir, err := integrationDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	switch name {
+	case "bash":
+		return []string{"--rcfile", filepath.Join(dir, "gonesh.bash")}, nil
+	case "zsh":
+		// zsh reads its startup files from ZDOTDIR; ours restore the user's
+		env = []string{"ZDOTDIR=" + filepath.Join(dir, "zsh")}
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			env = append(env, "GONESH_ZDOTDIR="+zdotdir)
+		}
+		return nil, env
+	default: // fish
+		return []string{"--init-command", "source " + fishQuote(filepath.Join(dir, "gonesh.fish"))}, nil
+	}
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(s string) string {
+	quoted := make([]byte, 0, len(s)+2)
+	quoted = append(quoted, '\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' || s[i] == '\\' {
+			quoted = append(quoted, '\\')
+		}
+		quoted = append(quoted, s[i])
+	}
+	return string(append(quoted, '\''))
+}
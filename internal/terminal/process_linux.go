@@ -0,0 +1,72 @@
+package terminal
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ForegroundProcess returns the name of the process in the foreground of
+// the PTY, such as the shell or the program it runs, or "" if unknown
+func (p *PTY) ForegroundProcess() string {
+	pgrp := p.foregroundGroup()
+	if pgrp <= 0 {
+		return ""
+	}
+	comm, err := os.ReadFile("/proc/" + strconv.Itoa(pgrp) + "/comm")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// ForegroundCommand returns the command line of the process in the
+// foreground of the PTY, or nil if unknown
+func (p *PTY) ForegroundCommand() []string {
+	pgrp := p.foregroundGroup()
+	if pgrp <= 0 {
+		return nil
+	}
+	cmdline, err := os.ReadFile("/proc/" + strconv.Itoa(pgrp) + "/cmdline")
+	if err != nil || len(cmdline) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+}
+
+// WorkingDir returns the working directory of the command started in the
+// PTY, or "" if unknown
+func (p *PTY) WorkingDir() string {
+	pid := p.Pid()
+	if pid == 0 {
+		return ""
+	}
+	dir, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/cwd")
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// foregroundGroup returns the ID of the foreground process group of the
+// PTY, or 0 if unknown
+func (p *PTY) foregroundGroup() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0
+	}
+
+	var pgrp int
+	err := p.control(func(fd int) error {
+		var err error
+		pgrp, err = unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+		return err
+	})
+	if err != nil || pgrp <= 0 {
+		return 0
+	}
+	return pgrp
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package terminal
+
+// The processes of a PTY are only looked up on Linux
+
+// ForegroundProcess returns ""
+func (p *PTY) ForegroundProcess() string {
+	return ""
+}
+
+// ForegroundCommand returns nil
+func (p *PTY) ForegroundCommand() []string {
+	return nil
+}
+
+// WorkingDir returns ""
+func (p *PTY) WorkingDir() string {
+	return ""
+}
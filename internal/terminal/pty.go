@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
 )
 
 // PTY represents a pseudo-terminal session
@@ -20,27 +21,53 @@ type PTY struct {
 	closed bool
 }
 
-// New creates a new PTY session with the default shell
+// New creates a new PTY session with the default shell.
+// Shell integration is loaded into bash, zsh and fish so that prompts and
+// commands are marked with OSC 133.
 func New() (*PTY, error) {
+	return NewInDir("")
+}
+
+// NewInDir creates a new PTY session with the default shell, started in
+// dir. An empty dir starts it in the current directory.
+func NewInDir(dir string) (*PTY, error) {
 	shell := os.Getenv("SHELL")
 	if shell == "" {
 		shell = "/bin/sh"
 	}
-	return NewWithCommand(shell)
+
+	args, env := shellIntegration(shell)
+	cmd := exec.Command(shell, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Dir = dir
+	return start(cmd)
 }
 
 // NewWithCommand creates a new PTY session with a specific command
 func NewWithCommand(command string, args ...string) (*PTY, error) {
 	cmd := exec.Command(command, args...)
 	cmd.Env = os.Environ()
-	// Create a new process group so we can kill all child processes
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return start(cmd)
+}
+
+// start runs cmd in a new PTY session
+func start(cmd *exec.Cmd) (*PTY, error) {
+	// Run the command in a new session with the PTY as its controlling
+	// terminal. The session leader also leads a new process group, so we
+	// can kill all child processes. Setpgid must not be set as well:
+	// setpgid fails for a session leader.
+	attrs := &syscall.SysProcAttr{Setsid: true, Setctty: true}
 
 	// Start the command with a PTY
-	ptmx, err := pty.Start(cmd)
+	ptmx, err := pty.StartWithAttrs(cmd, nil, attrs)
 	if err != nil {
 		return nil, err
 	}
+	// pty.Start leaves the master in blocking mode, in which closing it
+	// does not interrupt a pending Read
+	if f, err := pollable(ptmx); err == nil {
+		ptmx = f
+	}
 
 	return &PTY{
 		cmd: cmd,
@@ -86,12 +113,43 @@ func (p *PTY) Resize(rows, cols uint16) error {
 		return io.EOF
 	}
 
-	return pty.Setsize(p.pty, &pty.Winsize{
-		Rows: rows,
-		Cols: cols,
+	return p.control(func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
 	})
 }
 
+// control runs fn on the file descriptor of the PTY master. Unlike
+// os.File.Fd, it leaves the master in non-blocking mode.
+func (p *PTY) control(fn func(fd int) error) error {
+	raw, err := p.pty.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := raw.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// pollable returns a non-blocking copy of f, handled by the runtime poller
+// so that closing it interrupts a pending Read, and closes f
+func pollable(f *os.File) (*os.File, error) {
+	fd, err := unix.FcntlInt(f.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	nf := os.NewFile(uintptr(fd), f.Name())
+	_ = f.Close()
+	return nf, nil
+}
+
 // Close closes the PTY session
 func (p *PTY) Close() error {
 	p.mu.Lock()
@@ -0,0 +1,24 @@
+package terminal
+
+import "io"
+
+// Session is a running shell session: a PTY started by this process, or
+// one owned by the GoNeSh server
+type Session interface {
+	io.ReadWriteCloser
+
+	// Resize resizes the terminal of the session
+	Resize(rows, cols uint16) error
+
+	// ForegroundProcess returns the name of the process in the
+	// foreground, or "" if unknown
+	ForegroundProcess() string
+	// ForegroundCommand returns the command line of the process in the
+	// foreground, or nil if unknown
+	ForegroundCommand() []string
+	// WorkingDir returns the working directory of the shell, or "" if
+	// unknown
+	WorkingDir() string
+}
+
+var _ Session = (*PTY)(nil)
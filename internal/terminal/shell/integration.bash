@@ -0,0 +1,68 @@
+# GoNeSh shell integration for bash.
+# Marks the prompt (OSC 133 A), the start of the command line (B), the start
+# of the command output (C) and its exit status (D) so that GoNeSh can tell
+# them apart, and reports the working directory (OSC 7).
+# GoNeSh loads this file with --rcfile in place of ~/.bashrc.
+
+if [ -r ~/.bashrc ]; then
+	. ~/.bashrc
+fi
+
+if [ -z "${__gonesh_integration-}" ]; then
+	__gonesh_integration=1
+	__gonesh_at_prompt=0
+	__gonesh_running=0
+
+	# Runs first in PROMPT_COMMAND, while $? is still the command's status
+	__gonesh_precmd() {
+		local status=$?
+		if [ "$__gonesh_running" = 1 ]; then
+			printf '\033]133;D;%s\007' "$status"
+			__gonesh_running=0
+		fi
+		printf '\033]7;file://%s%s\007' "$HOSTNAME" "$PWD"
+		printf '\033]133;A\007'
+	}
+
+	# Runs last in PROMPT_COMMAND, after prompt themes have set PS1
+	__gonesh_prompt_end() {
+		case "$PS1" in
+		*'\[\033]133;B\007\]') ;;
+		*) PS1="$PS1"'\[\033]133;B\007\]' ;;
+		esac
+		__gonesh_at_prompt=1
+	}
+
+	# DEBUG trap: the first command run from the prompt starts the output.
+	# Returns the status it was called with, for the trap chained after it.
+	__gonesh_preexec() {
+		local status=$?
+		if [ "$__gonesh_at_prompt" = 1 ]; then
+			case "$BASH_COMMAND" in
+			__gonesh_precmd*) return "$status" ;;
+			esac
+			__gonesh_at_prompt=0
+			__gonesh_running=1
+			printf '\033]133;C\007'
+		fi
+		return "$status"
+	}
+
+	# Prints the command of the DEBUG trap set so far, such as by ~/.bashrc
+	__gonesh_debug_trap() {
+		eval "set -- $(trap -p DEBUG)"
+		printf '%s' "${3-}"
+	}
+
+	if [[ "$(declare -p PROMPT_COMMAND 2>/dev/null)" == "declare -a"* ]]; then
+		PROMPT_COMMAND=(__gonesh_precmd "${PROMPT_COMMAND[@]}" __gonesh_prompt_end)
+	else
+		PROMPT_COMMAND="__gonesh_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; __gonesh_prompt_end"
+	fi
+	# An existing DEBUG trap keeps running after ours
+	if [ -n "$(__gonesh_debug_trap)" ]; then
+		trap "__gonesh_preexec; $(__gonesh_debug_trap)" DEBUG
+	else
+		trap '__gonesh_preexec' DEBUG
+	fi
+fi
@@ -0,0 +1,33 @@
+# GoNeSh shell integration for fish.
+# Marks the prompt (OSC 133 A), the start of the command line (B), the start
+# of the command output (C) and its exit status (D) so that GoNeSh can tell
+# them apart, and reports the working directory (OSC 7).
+# GoNeSh loads this file with --init-command.
+
+if not set -q __gonesh_integration
+    set -g __gonesh_integration 1
+
+    function __gonesh_preexec --on-event fish_preexec
+        printf '\e]133;C\a'
+    end
+
+    function __gonesh_postexec --on-event fish_postexec
+        printf '\e]133;D;%s\a' $status
+    end
+
+    # Wrap the prompt so that the marks surround whatever it prints
+    if functions -q fish_prompt
+        functions -c fish_prompt __gonesh_fish_prompt
+    else
+        function __gonesh_fish_prompt
+            printf '> '
+        end
+    end
+
+    function fish_prompt
+        printf '\e]7;file://%s%s\a' $hostname $PWD
+        printf '\e]133;A\a'
+        __gonesh_fish_prompt
+        printf '\e]133;B\a'
+    end
+end
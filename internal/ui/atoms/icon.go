@@ -10,6 +10,8 @@ const (
 	// Tab icons
 	IconTerminal = ""
 	IconSSH      = "󰣀"
+	IconActivity = "●"
+	IconBell     = ""
 
 	// Section icons
 	IconTabs   = "󰓩"
@@ -30,6 +32,7 @@ const (
 	IconInfo     = ""
 	IconKeyboard = "⌨"
 	IconStar     = "✦"
+	IconSearch   = ""
 )
 
 // Icon renders an icon with specified color
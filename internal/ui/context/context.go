@@ -34,6 +34,10 @@ type Theme struct {
 	CPU lipgloss.Color
 	MEM lipgloss.Color
 	GPU lipgloss.Color
+
+	// ANSI colors 0-15 used for terminal output
+	// (black, red, green, yellow, blue, magenta, cyan, white, then bright variants)
+	ANSI [16]lipgloss.Color
 }
 
 // TokyoNight returns the Tokyo Night theme
@@ -62,6 +66,25 @@ func TokyoNight() *Theme {
 		CPU: lipgloss.Color("#61afef"),
 		MEM: lipgloss.Color("#c678dd"),
 		GPU: lipgloss.Color("#98c379"),
+
+		ANSI: [16]lipgloss.Color{
+			lipgloss.Color("#15161e"),
+			lipgloss.Color("#f7768e"),
+			lipgloss.Color("#9ece6a"),
+			lipgloss.Color("#e0af68"),
+			lipgloss.Color("#7aa2f7"),
+			lipgloss.Color("#bb9af7"),
+			lipgloss.Color("#7dcfff"),
+			lipgloss.Color("#a9b1d6"),
+			lipgloss.Color("#414868"),
+			lipgloss.Color("#ff899d"),
+			lipgloss.Color("#9fe044"),
+			lipgloss.Color("#faba4a"),
+			lipgloss.Color("#8db0ff"),
+			lipgloss.Color("#c7a9ff"),
+			lipgloss.Color("#a4daff"),
+			lipgloss.Color("#c0caf5"),
+		},
 	}
 }
 
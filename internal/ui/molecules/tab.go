@@ -2,6 +2,7 @@
 package molecules
 
 import (
+	"github.com/charmbracelet/lipgloss"
 	"github.com/ousiass/GoNeSh/internal/ui/atoms"
 	"github.com/ousiass/GoNeSh/internal/ui/context"
 )
@@ -14,8 +15,20 @@ const (
 	TabTypeSSH   TabType = "ssh"
 )
 
-// Tab renders a single tab with icon and name
-func Tab(ctx *context.UI, name string, tabType TabType, active bool) string {
+// TabMarker marks a background tab that needs attention
+type TabMarker int
+
+const (
+	TabMarkerNone TabMarker = iota
+	// TabMarkerActivity marks a tab that produced output
+	TabMarkerActivity
+	// TabMarkerBell marks a tab that rang the bell
+	TabMarkerBell
+)
+
+// Tab renders a single tab with icon and name, and the marker of an
+// inactive tab
+func Tab(ctx *context.UI, name string, tabType TabType, active bool, marker TabMarker) string {
 	icon := atoms.IconTerminal
 	if tabType == TabTypeSSH {
 		icon = atoms.IconSSH
@@ -26,5 +39,30 @@ func Tab(ctx *context.UI, name string, tabType TabType, active bool) string {
 	if active {
 		return atoms.ActiveBadge(ctx, text)
 	}
-	return atoms.InactiveBadge(ctx, text)
+	if marker == TabMarkerNone {
+		return atoms.InactiveBadge(ctx, text)
+	}
+
+	// The marker takes the place of the right padding, so that the tab
+	// keeps its width
+	markIcon, markColor := atoms.IconActivity, ctx.Theme.Info
+	if marker == TabMarkerBell {
+		markIcon, markColor = atoms.IconBell, ctx.Theme.Warning
+	}
+	label := lipgloss.NewStyle().
+		PaddingLeft(2).
+		Foreground(ctx.Theme.Text).
+		Background(ctx.Theme.Bg).
+		Render(text + " ")
+	mark := lipgloss.NewStyle().
+		Foreground(markColor).
+		Background(ctx.Theme.Bg).
+		Bold(true).
+		Render(markIcon)
+	return label + mark
+}
+
+// NewTabButton renders the button that opens a new tab
+func NewTabButton(ctx *context.UI) string {
+	return atoms.InactiveBadge(ctx, "+")
 }
@@ -39,6 +39,10 @@ func (h *HelpModal) View() string {
 		molecules.HelpItem(h.ctx, "w", "Close"),
 		molecules.HelpItem(h.ctx, "]", "Next"),
 		molecules.HelpItem(h.ctx, "[", "Prev"),
+		molecules.HelpItem(h.ctx, "n", "Rename"),
+		molecules.HelpItem(h.ctx, "{}", "Move"),
+		molecules.HelpItem(h.ctx, "\\", "Split →"),
+		molecules.HelpItem(h.ctx, "-", "Split ↓"),
 	})
 
 	aiSection := molecules.Section(h.ctx, atoms.IconAI, "AI", []string{
@@ -55,7 +59,14 @@ func (h *HelpModal) View() string {
 		molecules.HelpItem(h.ctx, "g", "Git"),
 	})
 
-	// 3-column layout
+	termSection := molecules.Section(h.ctx, atoms.IconTerminal, "TERM", []string{
+		molecules.HelpItem(h.ctx, "v", "Scroll"),
+		molecules.HelpItem(h.ctx, "/", "Search"),
+		molecules.HelpItem(h.ctx, "y", "Paste"),
+		molecules.HelpItem(h.ctx, "h", "Stats"),
+	})
+
+	// 4-column layout
 	colStyle := lipgloss.NewStyle().
 		Padding(0, 1).
 		Background(h.ctx.Theme.Bg)
@@ -65,6 +76,7 @@ func (h *HelpModal) View() string {
 		colStyle.Render(tabsSection),
 		colStyle.Render(aiSection),
 		colStyle.Render(filesSection),
+		colStyle.Render(termSection),
 	)
 
 	contentWidth := lipgloss.Width(columns)
@@ -2,7 +2,11 @@
 package organisms
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -21,8 +25,10 @@ type HistorySearch struct {
 	ctx      *context.UI
 	history  *history.History
 	query    string
-	results  []string
+	filter   history.Filter
+	results  []history.Match
 	selected int
+	offset   int // First result in view
 	width    int
 	height   int
 	visible  bool
@@ -34,14 +40,18 @@ func NewHistorySearch(ctx *context.UI, h *history.History) *HistorySearch {
 	return &HistorySearch{
 		ctx:       ctx,
 		history:   h,
-		results:   []string{},
+		results:   []history.Match{},
 		selected:  0,
 		searchPos: -1,
 	}
 }
 
-// Show shows the history search UI
-func (hs *HistorySearch) Show() {
+// Show shows the history search UI for a terminal in the given directory,
+// host and session. The scope last searched is kept.
+func (hs *HistorySearch) Show(dir, host, session string) {
+	hs.filter.Dir = dir
+	hs.filter.Host = host
+	hs.filter.Session = session
 	hs.visible = true
 	hs.query = ""
 	hs.selected = 0
@@ -53,7 +63,7 @@ func (hs *HistorySearch) Show() {
 func (hs *HistorySearch) Hide() {
 	hs.visible = false
 	hs.query = ""
-	hs.results = []string{}
+	hs.results = []history.Match{}
 }
 
 // IsVisible returns whether the search UI is visible
@@ -84,7 +94,7 @@ func (hs *HistorySearch) Update(msg tea.Msg) (*HistorySearch, tea.Cmd) {
 
 		case tea.KeyEnter:
 			if len(hs.results) > 0 && hs.selected < len(hs.results) {
-				entry := hs.results[hs.selected]
+				entry := hs.results[hs.selected].Entry.Command
 				hs.Hide()
 				return hs, func() tea.Msg {
 					return HistorySearchResult{Entry: entry, Selected: true}
@@ -99,24 +109,39 @@ func (hs *HistorySearch) Update(msg tea.Msg) (*HistorySearch, tea.Cmd) {
 			// Search for next match (going backwards)
 			if len(hs.results) > 0 {
 				hs.selected = (hs.selected + 1) % len(hs.results)
+				hs.scrollToSelected()
 			}
 			return hs, nil
 
-		case tea.KeyUp:
-			if hs.selected > 0 {
-				hs.selected--
-			}
+		case tea.KeyTab:
+			hs.filter.Scope = hs.filter.Scope.Next()
+			hs.updateResults()
 			return hs, nil
 
-		case tea.KeyDown:
-			if hs.selected < len(hs.results)-1 {
-				hs.selected++
-			}
+		case tea.KeyShiftTab:
+			hs.filter.Scope = hs.filter.Scope.Prev()
+			hs.updateResults()
+			return hs, nil
+
+		case tea.KeyUp, tea.KeyCtrlP:
+			hs.moveSelection(-1)
+			return hs, nil
+
+		case tea.KeyDown, tea.KeyCtrlN:
+			hs.moveSelection(1)
+			return hs, nil
+
+		case tea.KeyPgUp:
+			hs.moveSelection(-hs.visibleRows())
+			return hs, nil
+
+		case tea.KeyPgDown:
+			hs.moveSelection(hs.visibleRows())
 			return hs, nil
 
 		case tea.KeyBackspace:
-			if len(hs.query) > 0 {
-				hs.query = hs.query[:len(hs.query)-1]
+			if query := []rune(hs.query); len(query) > 0 {
+				hs.query = string(query[:len(query)-1])
 				hs.updateResults()
 			}
 			return hs, nil
@@ -133,16 +158,33 @@ func (hs *HistorySearch) Update(msg tea.Msg) (*HistorySearch, tea.Cmd) {
 
 // updateResults updates the search results based on the current query
 func (hs *HistorySearch) updateResults() {
-	hs.results = hs.history.Search(hs.query)
+	hs.results = hs.history.Search(hs.query, hs.filter)
 	hs.selected = 0
+	hs.offset = 0
+}
+
+// visibleRows returns the number of results that fit in the view
+func (hs *HistorySearch) visibleRows() int {
+	// Border, header and footer
+	return max(hs.height-4, 1)
+}
 
-	// Limit results to fit in view
-	maxResults := hs.height - 4
-	if maxResults < 1 {
-		maxResults = 5
+// moveSelection moves the selection by n results, older for positive n
+func (hs *HistorySearch) moveSelection(n int) {
+	if len(hs.results) == 0 {
+		return
 	}
-	if len(hs.results) > maxResults {
-		hs.results = hs.results[:maxResults]
+	hs.selected = max(0, min(hs.selected+n, len(hs.results)-1))
+	hs.scrollToSelected()
+}
+
+// scrollToSelected scrolls the selected result into view
+func (hs *HistorySearch) scrollToSelected() {
+	rows := hs.visibleRows()
+	if hs.selected < hs.offset {
+		hs.offset = hs.selected
+	} else if hs.selected >= hs.offset+rows {
+		hs.offset = hs.selected - rows + 1
 	}
 }
 
@@ -160,6 +202,12 @@ func (hs *HistorySearch) View() string {
 		Foreground(hs.ctx.Theme.Primary).
 		Bold(true)
 	sb.WriteString(headerStyle.Render("(reverse-i-search)"))
+	scopeStyle := lipgloss.NewStyle().
+		Foreground(hs.ctx.Theme.Secondary)
+	if hs.filter.Scope != history.ScopeGlobal {
+		scopeStyle = scopeStyle.Bold(true)
+	}
+	sb.WriteString(scopeStyle.Render("[" + hs.filter.Scope.String() + "]"))
 	sb.WriteString(": ")
 
 	// Query
@@ -167,25 +215,44 @@ func (hs *HistorySearch) View() string {
 		Foreground(hs.ctx.Theme.Text)
 	sb.WriteString(queryStyle.Render(hs.query))
 	sb.WriteString("_")
+	if len(hs.results) > 0 {
+		countStyle := lipgloss.NewStyle().
+			Foreground(hs.ctx.Theme.TextMuted)
+		sb.WriteString(countStyle.Render(fmt.Sprintf("  %d/%d", hs.selected+1, len(hs.results))))
+	}
 	sb.WriteString("\n")
 
-	// Results
-	for i, result := range hs.results {
-		var line string
+	// Results, with where and when they ran on the right
+	lineWidth := hs.width - 6
+	end := min(hs.offset+hs.visibleRows(), len(hs.results))
+	for i := hs.offset; i < end; i++ {
+		result := hs.results[i]
+		meta := entryMeta(result.Entry, time.Now())
+		metaWidth := lipgloss.Width(meta)
+		if metaWidth > lineWidth/2 {
+			meta, metaWidth = "", 0
+		}
+
+		textStyle := lipgloss.NewStyle().
+			Foreground(hs.ctx.Theme.TextAlt)
+		matchStyle := lipgloss.NewStyle().
+			Foreground(hs.ctx.Theme.Accent).
+			Bold(true)
+		metaStyle := lipgloss.NewStyle().
+			Foreground(hs.ctx.Theme.TextMuted)
+		if result.Entry.ExitCode > 0 {
+			metaStyle = metaStyle.Foreground(hs.ctx.Theme.Error)
+		}
 		if i == hs.selected {
-			// Selected item
-			selectedStyle := lipgloss.NewStyle().
-				Background(hs.ctx.Theme.Primary).
-				Foreground(hs.ctx.Theme.Bg).
-				Width(hs.width - 4)
-			line = selectedStyle.Render(truncate(result, hs.width-6))
-		} else {
-			// Normal item
-			normalStyle := lipgloss.NewStyle().
-				Foreground(hs.ctx.Theme.TextAlt).
-				Width(hs.width - 4)
-			line = normalStyle.Render(truncate(result, hs.width-6))
+			textStyle = textStyle.Background(hs.ctx.Theme.Primary).Foreground(hs.ctx.Theme.Bg)
+			matchStyle = matchStyle.Background(hs.ctx.Theme.Primary).Foreground(hs.ctx.Theme.Bg).Underline(true)
+			metaStyle = textStyle
 		}
+
+		text, textWidth := highlightMatches(result.Entry.Command, result.Positions,
+			lineWidth-metaWidth-1, textStyle, matchStyle)
+		gap := max(lineWidth-textWidth-metaWidth, 1)
+		line := text + textStyle.Render(strings.Repeat(" ", gap)) + metaStyle.Render(meta)
 		sb.WriteString("  " + line + "\n")
 	}
 
@@ -193,7 +260,7 @@ func (hs *HistorySearch) View() string {
 	hintStyle := lipgloss.NewStyle().
 		Foreground(hs.ctx.Theme.TextAlt).
 		Italic(true)
-	sb.WriteString(hintStyle.Render("Ctrl+R: next | Enter: select | Esc: cancel"))
+	sb.WriteString(hintStyle.Render("Ctrl+R/↑↓: move | Tab: scope | Enter: select | Esc: cancel"))
 
 	// Box style
 	boxStyle := lipgloss.NewStyle().
@@ -205,16 +272,90 @@ func (hs *HistorySearch) View() string {
 	return boxStyle.Render(sb.String())
 }
 
-// truncate truncates a string to the given length
-func truncate(s string, maxLen int) string {
-	if maxLen <= 0 {
-		return ""
+// highlightMatches renders text cut to width cells, with the runes at
+// positions in matchStyle. It returns the rendered text and its width.
+func highlightMatches(text string, positions []int, width int, style, matchStyle lipgloss.Style) (string, int) {
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	runes := []rune(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text))
+	cut := lipgloss.Width(string(runes)) > width
+
+	var sb, run strings.Builder
+	runMatched := false
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if runMatched {
+			sb.WriteString(matchStyle.Render(run.String()))
+		} else {
+			sb.WriteString(style.Render(run.String()))
+		}
+		run.Reset()
+	}
+
+	w := 0
+	for i, r := range runes {
+		rw := lipgloss.Width(string(r))
+		if cut && w+rw > width-1 {
+			break
+		}
+		if matched[i] != runMatched {
+			flush()
+			runMatched = matched[i]
+		}
+		run.WriteRune(r)
+		w += rw
+	}
+	flush()
+	if cut && width > 0 {
+		sb.WriteString(style.Render("…"))
+		w++
 	}
-	if len(s) <= maxLen {
-		return s
+	return sb.String(), w
+}
+
+// entryMeta describes where and when a history entry ran.
+// Entries from old history files have no metadata.
+func entryMeta(e history.Entry, now time.Time) string {
+	var parts []string
+	if e.ExitCode > 0 {
+		parts = append(parts, fmt.Sprintf("✗ %d", e.ExitCode))
 	}
-	if maxLen <= 3 {
-		return s[:maxLen]
+	if e.Dir != "" {
+		dir := filepath.Base(e.Dir)
+		if home, err := os.UserHomeDir(); err == nil && e.Dir == home {
+			dir = "~"
+		}
+		parts = append(parts, dir)
+	}
+	if e.Duration >= time.Second {
+		parts = append(parts, e.Duration.Round(time.Second).String())
+	}
+	if !e.Time.IsZero() {
+		parts = append(parts, timeAgo(now.Sub(e.Time)))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// timeAgo formats an elapsed time briefly, e.g. "5m ago"
+func timeAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
 	}
-	return s[:maxLen-3] + "..."
 }
@@ -0,0 +1,197 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/history"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+	"github.com/ousiass/GoNeSh/internal/ui/context"
+	"github.com/ousiass/GoNeSh/internal/ui/templates"
+)
+
+// Most commands and hosts listed in each ranking of the stats panel
+const statsMaxRows = 5
+
+// sparkLevels are the bars of a sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// HistoryStats shows statistics of the command history
+type HistoryStats struct {
+	ctx     *context.UI
+	width   int
+	height  int
+	visible bool
+	stats   history.Stats
+}
+
+// NewHistoryStats creates a new history stats panel
+func NewHistoryStats(ctx *context.UI) *HistoryStats {
+	return &HistoryStats{ctx: ctx}
+}
+
+// Show shows the panel with the given statistics
+func (s *HistoryStats) Show(stats history.Stats) {
+	s.visible = true
+	s.stats = stats
+}
+
+// Hide hides the panel
+func (s *HistoryStats) Hide() {
+	s.visible = false
+	s.stats = history.Stats{}
+}
+
+// IsVisible returns whether the panel is visible
+func (s *HistoryStats) IsVisible() bool {
+	return s.visible
+}
+
+// SetSize sets the modal size
+func (s *HistoryStats) SetSize(width, height int) {
+	s.width = width
+	s.height = height
+}
+
+// Update handles messages for the panel
+func (s *HistoryStats) Update(msg tea.Msg) (*HistoryStats, tea.Cmd) {
+	if !s.visible {
+		return s, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return s, nil
+	}
+	switch keyMsg.String() {
+	case "esc", "q", "alt+h", "ctrl+c", "ctrl+g":
+		s.Hide()
+	}
+	return s, nil
+}
+
+// View renders the panel
+func (s *HistoryStats) View() string {
+	if !s.visible || s.width == 0 || s.height == 0 {
+		return ""
+	}
+
+	contentWidth := min(max(s.width-8, 40), 76)
+	colWidth := (contentWidth - 2) / 2
+
+	// Besides the two rows of rankings, the panel has 11 rows of titles,
+	// graphs and spacing, and the modal border
+	rows := min(max((s.height-13)/2, 1), statsMaxRows)
+
+	st := s.stats
+	title := atoms.Title(s.ctx, atoms.IconSearch+"  History Stats") + atoms.Fill(s.ctx, 2) +
+		atoms.TextMuted(s.ctx, fmt.Sprintf("%d commands · %d unique · %d failed", st.Total, st.Unique, st.Failures))
+
+	var used, failed, slowest, hosts []string
+	for _, c := range st.MostUsed[:min(rows, len(st.MostUsed))] {
+		used = append(used, s.statsRow(strconv.Itoa(c.Count), c.Command, colWidth, s.ctx.Theme.Accent))
+	}
+	for _, c := range st.MostFailed[:min(rows, len(st.MostFailed))] {
+		failed = append(failed, s.statsRow(fmt.Sprintf("%d/%d", c.Failures, c.Runs), c.Command, colWidth, s.ctx.Theme.Error))
+	}
+	for _, c := range st.Slowest[:min(rows, len(st.Slowest))] {
+		slowest = append(slowest, s.statsRow(shortDuration(c.Mean), c.Command, colWidth, s.ctx.Theme.Warning))
+	}
+	for _, h := range st.Hosts[:min(rows, len(st.Hosts))] {
+		hosts = append(hosts, s.statsRow(strconv.Itoa(h.Count), h.Host, colWidth, s.ctx.Theme.Info))
+	}
+
+	colStyle := lipgloss.NewStyle().Width(colWidth).Background(s.ctx.Theme.Bg)
+	gap := atoms.Fill(s.ctx, 2)
+	grid := func(left, right string) string {
+		return lipgloss.JoinHorizontal(lipgloss.Top, colStyle.Render(left), gap, colStyle.Render(right))
+	}
+
+	hourAxis := "0     6     12    18"
+	activity := grid(
+		s.statsSection("BY HOUR", []string{
+			atoms.Label(s.ctx, sparkline(st.ByHour[:]), s.ctx.Theme.Primary),
+			atoms.TextMuted(s.ctx, hourAxis),
+		}, 2),
+		s.statsSection("BY WEEKDAY", []string{
+			atoms.Label(s.ctx, sparkline(st.ByWeekday[:]), s.ctx.Theme.Primary),
+			atoms.TextMuted(s.ctx, "SMTWTFS"),
+		}, 2),
+	)
+
+	footer := atoms.TextMuted(s.ctx, "Esc/q close • gonesh history stats for more")
+
+	emptyRow := atoms.Fill(s.ctx, 1)
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		emptyRow,
+		grid(s.statsSection("MOST USED", used, rows), s.statsSection("MOST FAILED", failed, rows)),
+		emptyRow,
+		grid(s.statsSection("SLOWEST", slowest, rows), s.statsSection("HOSTS", hosts, rows)),
+		emptyRow,
+		activity,
+		emptyRow,
+		footer,
+	)
+
+	return templates.ModalWithPadding(s.ctx, content, s.width, s.height, 0, 2)
+}
+
+// statsSection renders a ranking under a header, padded to rows lines
+func (s *HistoryStats) statsSection(title string, items []string, rows int) string {
+	lines := []string{atoms.Label(s.ctx, title, s.ctx.Theme.Secondary)}
+	if len(items) == 0 {
+		items = []string{atoms.TextMuted(s.ctx, "none")}
+	}
+	lines = append(lines, items...)
+	for len(lines) < rows+1 {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statsRow renders a value followed by a command or host, cut to width
+func (s *HistoryStats) statsRow(value, text string, width int, color lipgloss.Color) string {
+	value = fmt.Sprintf("%6s ", value)
+	text = strings.ReplaceAll(text, "\n", "↵")
+	return atoms.Label(s.ctx, value, color) +
+		atoms.Text(s.ctx, truncateWidth(text, width-lipgloss.Width(value)))
+}
+
+// sparkline draws counts as a line of bars scaled to the largest.
+// Zero counts are left blank.
+func sparkline(counts []int) string {
+	peak := slices.Max(counts)
+	var sb strings.Builder
+	for _, n := range counts {
+		if n == 0 || peak == 0 {
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteRune(sparkLevels[(n*len(sparkLevels)-1)/peak])
+	}
+	return sb.String()
+}
+
+// shortDuration formats a duration in at most about six cells
+func shortDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	case d >= time.Minute:
+		return fmt.Sprintf("%.1fm", d.Minutes())
+	case d >= time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	default:
+		return "<1ms"
+	}
+}
@@ -0,0 +1,359 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"math"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/ui/context"
+)
+
+// SplitDir is the direction in which a pane is split
+type SplitDir int
+
+const (
+	// SplitRight places the new pane to the right of the split one
+	SplitRight SplitDir = iota
+	// SplitDown places the new pane below the split one
+	SplitDown
+)
+
+const (
+	// Share of a split the first pane gets at least and at most
+	minSplitRatio = 0.1
+	maxSplitRatio = 0.9
+	// How far a resize key moves a split
+	splitRatioStep = 0.05
+)
+
+// paneRect is the cell rectangle a pane is laid out in, relative to the
+// top left corner of the tab's content area
+type paneRect struct {
+	x, y          int
+	width, height int
+}
+
+// contains returns whether the cell (x, y) is inside the rectangle
+func (r paneRect) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.width && y >= r.y && y < r.y+r.height
+}
+
+// Pane is a node of the layout tree of a tab. A leaf shows a terminal;
+// a split divides its area between two panes, with a one cell separator.
+type Pane struct {
+	// Terminal shown in a leaf; nil for a split
+	Terminal *Terminal
+
+	split    SplitDir
+	ratio    float64 // Share of the first child
+	children [2]*Pane
+	parent   *Pane
+	rect     paneRect
+}
+
+// newPane creates a leaf pane showing term
+func newPane(term *Terminal) *Pane {
+	return &Pane{Terminal: term}
+}
+
+// isLeaf returns whether the pane shows a terminal
+func (p *Pane) isLeaf() bool {
+	return p.children[0] == nil
+}
+
+// leaves appends the leaf panes under p to out, left to right and top to
+// bottom
+func (p *Pane) leaves(out []*Pane) []*Pane {
+	if p.isLeaf() {
+		return append(out, p)
+	}
+	out = p.children[0].leaves(out)
+	return p.children[1].leaves(out)
+}
+
+// splitLeaf splits the leaf p, which keeps its terminal in the first half,
+// and returns the new leaf showing term in the second half
+func (p *Pane) splitLeaf(dir SplitDir, term *Terminal) *Pane {
+	first := &Pane{Terminal: p.Terminal, parent: p}
+	second := &Pane{Terminal: term, parent: p}
+	p.Terminal = nil
+	p.split = dir
+	p.ratio = 0.5
+	p.children = [2]*Pane{first, second}
+	return second
+}
+
+// remove removes the leaf p from the tree; its sibling takes the place of
+// their parent. It returns the node that took the place, or nil if p is
+// the root.
+func (p *Pane) remove() *Pane {
+	parent := p.parent
+	if parent == nil {
+		return nil
+	}
+	sibling := parent.children[0]
+	if sibling == p {
+		sibling = parent.children[1]
+	}
+
+	// The parent node becomes the sibling, so that references to the
+	// parent (such as the tab's root) stay valid
+	*parent = Pane{
+		Terminal: sibling.Terminal,
+		split:    sibling.split,
+		ratio:    sibling.ratio,
+		children: sibling.children,
+		parent:   parent.parent,
+		rect:     parent.rect,
+	}
+	for _, c := range parent.children {
+		if c != nil {
+			c.parent = parent
+		}
+	}
+	return parent
+}
+
+// layout lays out the pane and its children in r and sizes their
+// terminals, which resizes their PTYs
+func (p *Pane) layout(r paneRect) {
+	p.rect = r
+	if p.isLeaf() {
+		if r.width > 0 && r.height > 0 {
+			p.Terminal.SetSize(r.width, r.height)
+		}
+		return
+	}
+
+	first, second := r, r
+	if p.split == SplitRight {
+		first.width = splitSize(r.width, p.ratio)
+		second.x = r.x + first.width + 1
+		second.width = max(r.width-first.width-1, 0)
+	} else {
+		first.height = splitSize(r.height, p.ratio)
+		second.y = r.y + first.height + 1
+		second.height = max(r.height-first.height-1, 0)
+	}
+	p.children[0].layout(first)
+	p.children[1].layout(second)
+}
+
+// splitSize returns the size of the first pane of a split of size cells,
+// leaving at least one cell to the second pane after the separator
+func splitSize(size int, ratio float64) int {
+	avail := size - 1
+	if avail < 2 {
+		return max(avail, 0)
+	}
+	n := int(math.Round(float64(avail) * ratio))
+	return min(max(n, 1), avail-1)
+}
+
+// view renders the pane as laid out. The separators next to the focused
+// pane are highlighted.
+func (p *Pane) view(ctx *context.UI, focus *Pane) string {
+	if p.isLeaf() {
+		if p.rect.width == 0 || p.rect.height == 0 {
+			return ""
+		}
+		return p.Terminal.View()
+	}
+
+	first := p.children[0].view(ctx, focus)
+	second := p.children[1].view(ctx, focus)
+	if p.split == SplitRight {
+		x := p.children[0].rect.x + p.children[0].rect.width
+		sep := make([]string, p.rect.height)
+		for i := range sep {
+			y := p.rect.y + i
+			near := focus != nil && y >= focus.rect.y && y < focus.rect.y+focus.rect.height &&
+				(focus.rect.x+focus.rect.width == x || focus.rect.x == x+1)
+			sep[i] = separatorStyle(ctx, near).Render("│")
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Top, first, strings.Join(sep, "\n"), second)
+	}
+
+	y := p.children[0].rect.y + p.children[0].rect.height
+	var sb strings.Builder
+	for i := range p.rect.width {
+		x := p.rect.x + i
+		near := focus != nil && x >= focus.rect.x && x < focus.rect.x+focus.rect.width &&
+			(focus.rect.y+focus.rect.height == y || focus.rect.y == y+1)
+		sb.WriteString(separatorStyle(ctx, near).Render("─"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, first, sb.String(), second)
+}
+
+// separatorStyle returns the style of a separator cell
+func separatorStyle(ctx *context.UI, focused bool) lipgloss.Style {
+	color := ctx.Theme.Border
+	if focused {
+		color = ctx.Theme.Accent
+	}
+	return lipgloss.NewStyle().Foreground(color).Background(ctx.Theme.Bg)
+}
+
+// Panes returns the panes of the tab that show terminals
+func (tab *Tab) Panes() []*Pane {
+	return tab.Root.leaves(nil)
+}
+
+// Terminal returns the terminal of the focused pane
+func (tab *Tab) Terminal() *Terminal {
+	return tab.Focus.Terminal
+}
+
+// Split splits the focused pane, shows term in the new pane and focuses it
+func (tab *Tab) Split(dir SplitDir, term *Terminal) {
+	tab.focusPane(tab.Focus.splitLeaf(dir, term))
+	tab.relayout()
+}
+
+// ClosePane removes the focused pane and focuses its neighbour. It returns
+// the terminal of the removed pane, which is left for the caller to close.
+// The last pane of a tab is not removed; ok is false then.
+func (tab *Tab) ClosePane() (term *Terminal, ok bool) {
+	if tab.Focus == tab.Root {
+		return nil, false
+	}
+	term = tab.Focus.Terminal
+	node := tab.Focus.remove()
+	tab.focusPane(node.leaves(nil)[0])
+	tab.relayout()
+	return term, true
+}
+
+// MoveFocus focuses the nearest pane in the direction (dx, dy), one of
+// which is 0. It returns false if there is no pane in that direction.
+func (tab *Tab) MoveFocus(dx, dy int) bool {
+	cur := tab.Focus.rect
+	// The focus moves from the middle of the edge facing the direction
+	cx, cy := cur.x+cur.width/2, cur.y+cur.height/2
+
+	var best *Pane
+	bestDist := 0
+	for _, p := range tab.Panes() {
+		if p == tab.Focus {
+			continue
+		}
+		r := p.rect
+		var dist, off int
+		switch {
+		case dx > 0 && r.x > cur.x+cur.width-1:
+			dist, off = r.x-(cur.x+cur.width), axisDistance(cy, r.y, r.height)
+		case dx < 0 && r.x+r.width-1 < cur.x:
+			dist, off = cur.x-(r.x+r.width), axisDistance(cy, r.y, r.height)
+		case dy > 0 && r.y > cur.y+cur.height-1:
+			dist, off = r.y-(cur.y+cur.height), axisDistance(cx, r.x, r.width)
+		case dy < 0 && r.y+r.height-1 < cur.y:
+			dist, off = cur.y-(r.y+r.height), axisDistance(cx, r.x, r.width)
+		default:
+			continue
+		}
+		// Panes beside the middle of the focused pane come first
+		d := dist*1000 + off
+		if best == nil || d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	if best == nil {
+		return false
+	}
+	tab.focusPane(best)
+	return true
+}
+
+// axisDistance returns how far v is from the span [start, start+size)
+func axisDistance(v, start, size int) int {
+	switch {
+	case v < start:
+		return start - v
+	case v >= start+size:
+		return v - (start + size - 1)
+	}
+	return 0
+}
+
+// ResizePane moves the nearest separator of the focused pane in the
+// direction (dx, dy), one of which is 0
+func (tab *Tab) ResizePane(dx, dy int) {
+	dir, delta := SplitRight, dx
+	if dy != 0 {
+		dir, delta = SplitDown, dy
+	}
+	for p := tab.Focus.parent; p != nil; p = p.parent {
+		if p.split == dir {
+			p.ratio = min(max(p.ratio+float64(delta)*splitRatioStep, minSplitRatio), maxSplitRatio)
+			tab.relayout()
+			return
+		}
+	}
+}
+
+// SetSize lays out the panes of the tab in width x height cells
+func (tab *Tab) SetSize(width, height int) {
+	tab.Root.layout(paneRect{width: width, height: height})
+}
+
+// relayout lays out the panes again in the current size of the tab, once
+// it is known
+func (tab *Tab) relayout() {
+	if r := tab.Root.rect; r.width > 0 && r.height > 0 {
+		tab.Root.layout(paneRect{width: r.width, height: r.height})
+	}
+}
+
+// paneAt returns the leaf pane at the cell (x, y), or nil if the cell is
+// on a separator
+func (tab *Tab) paneAt(x, y int) *Pane {
+	for _, p := range tab.Panes() {
+		if p.rect.contains(x, y) {
+			return p
+		}
+	}
+	return nil
+}
+
+// View renders the panes of the tab
+func (tab *Tab) View(ctx *context.UI) string {
+	focus := tab.Focus
+	if tab.Root.isLeaf() {
+		focus = nil
+	}
+	return tab.Root.view(ctx, focus)
+}
+
+// focusPane focuses a leaf pane. Only the focused terminal draws its
+// cursor.
+func (tab *Tab) focusPane(p *Pane) {
+	tab.Focus = p
+	for _, leaf := range tab.Panes() {
+		leaf.Terminal.SetFocused(leaf == p)
+	}
+}
+
+// UpdateMouse forwards a mouse event in the content area of the tab to a
+// pane, relative to the pane's top left corner. Clicking focuses the pane
+// under the pointer and the wheel scrolls it; motion and release go to the
+// focused pane, so that a drag stays in the pane it started in.
+func (tab *Tab) UpdateMouse(msg tea.MouseMsg) tea.Cmd {
+	target := tab.Focus
+	if msg.Action == tea.MouseActionPress {
+		if target = tab.paneAt(msg.X, msg.Y); target == nil {
+			return nil
+		}
+		if !tea.MouseEvent(msg).IsWheel() && target != tab.Focus {
+			tab.focusPane(target)
+		}
+	}
+
+	r := target.rect
+	msg.X = min(max(msg.X-r.x, 0), max(r.width-1, 0))
+	msg.Y = min(max(msg.Y-r.y, 0), max(r.height-1, 0))
+	var cmd tea.Cmd
+	target.Terminal, cmd = target.Terminal.Update(msg)
+	return cmd
+}
@@ -0,0 +1,225 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+	"github.com/ousiass/GoNeSh/internal/ui/context"
+	"github.com/ousiass/GoNeSh/internal/ui/templates"
+)
+
+// Lines of the pasted text shown in the confirmation
+const pastePreviewLines = 8
+
+// dangerousPastes are patterns of commands that should not run by accident
+var dangerousPastes = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`\brm\s+(-\S+\s+)*-[a-zA-Z]*[rRf]`), "Recursive or forced rm"},
+	{regexp.MustCompile(`\|\s*(sudo\s+)?(ba|z|da|k|fi)?sh\b`), "Pipes into a shell"},
+	{regexp.MustCompile(`\bsudo\b`), "Runs sudo"},
+	{regexp.MustCompile(`\b(mkfs|fdisk|wipefs)\b|\bdd\s.*\bof=|>\s*/dev/(sd|nvme|hd|vd)`), "Writes to a disk"},
+	{regexp.MustCompile(`:\(\)\s*\{`), "Fork bomb"},
+	{regexp.MustCompile(`\bgit\s+push\b.*(\s-f\b|--force)`), "Force push"},
+	{regexp.MustCompile(`(?i)\bdrop\s+(table|database)\b`), "Drops a database table"},
+}
+
+// PasteWarnings returns why pasting text needs confirmation.
+// Multi-line text runs as soon as it is pasted into a shell that does not
+// use bracketed paste.
+func PasteWarnings(text string) []string {
+	var warnings []string
+	if strings.ContainsAny(strings.TrimRight(text, "\r\n"), "\r\n") {
+		warnings = append(warnings, fmt.Sprintf("Multi-line paste (%d lines)", pasteLineCount(text)))
+	} else if strings.HasSuffix(text, "\n") || strings.HasSuffix(text, "\r") {
+		warnings = append(warnings, "Ends with a newline and runs immediately")
+	}
+	for _, d := range dangerousPastes {
+		if d.re.MatchString(text) {
+			warnings = append(warnings, d.reason)
+		}
+	}
+	if strings.ContainsFunc(text, func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f
+	}) {
+		warnings = append(warnings, "Contains control characters")
+	}
+	return warnings
+}
+
+// pasteLineCount returns the number of lines of pasted text
+func pasteLineCount(text string) int {
+	text = strings.ReplaceAll(strings.TrimRight(text, "\r\n"), "\r\n", "\n")
+	return strings.Count(strings.ReplaceAll(text, "\r", "\n"), "\n") + 1
+}
+
+// PasteConfirmResult is sent when the paste confirmation is answered
+type PasteConfirmResult struct {
+	Text      string
+	Confirmed bool
+}
+
+// PasteConfirm asks before pasting text that could run commands by accident
+type PasteConfirm struct {
+	ctx      *context.UI
+	width    int
+	height   int
+	visible  bool
+	text     string
+	warnings []string
+	env      string
+}
+
+// NewPasteConfirm creates a new paste confirmation
+func NewPasteConfirm(ctx *context.UI) *PasteConfirm {
+	return &PasteConfirm{ctx: ctx}
+}
+
+// Show asks to confirm pasting text into a tab of the given environment
+func (p *PasteConfirm) Show(text string, warnings []string, env string) {
+	p.visible = true
+	p.text = text
+	p.warnings = warnings
+	p.env = env
+}
+
+// Hide hides the confirmation
+func (p *PasteConfirm) Hide() {
+	p.visible = false
+	p.text = ""
+	p.warnings = nil
+}
+
+// IsVisible returns whether the confirmation is visible
+func (p *PasteConfirm) IsVisible() bool {
+	return p.visible
+}
+
+// SetSize sets the modal size
+func (p *PasteConfirm) SetSize(width, height int) {
+	p.width = width
+	p.height = height
+}
+
+// Update handles messages for the confirmation
+func (p *PasteConfirm) Update(msg tea.Msg) (*PasteConfirm, tea.Cmd) {
+	if !p.visible {
+		return p, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+
+	text := p.text
+	switch keyMsg.String() {
+	case "enter", "y":
+		p.Hide()
+		return p, func() tea.Msg {
+			return PasteConfirmResult{Text: text, Confirmed: true}
+		}
+	case "esc", "n", "ctrl+c", "ctrl+g":
+		p.Hide()
+		return p, func() tea.Msg {
+			return PasteConfirmResult{Text: text, Confirmed: false}
+		}
+	}
+	return p, nil
+}
+
+// View renders the confirmation
+func (p *PasteConfirm) View() string {
+	if !p.visible || p.width == 0 || p.height == 0 {
+		return ""
+	}
+
+	contentWidth := min(max(p.width-12, 20), 72)
+
+	titleColor := p.ctx.Theme.Warning
+	if p.env == "prod" {
+		titleColor = p.ctx.Theme.Error
+	}
+	title := lipgloss.NewStyle().
+		Foreground(titleColor).
+		Background(p.ctx.Theme.Bg).
+		Bold(true).
+		Render(atoms.IconWarning + "  Confirm paste")
+	header := title + atoms.Fill(p.ctx, 2) + atoms.EnvBadge(p.ctx, p.env)
+
+	var reasons []string
+	for _, w := range p.warnings {
+		reasons = append(reasons, atoms.WarningText(p.ctx, "• "+w))
+	}
+	if p.env == "prod" {
+		reasons = append(reasons, atoms.ErrorText(p.ctx, "• Pasting into a production environment"))
+	}
+
+	// Preview of the text, with control characters made visible
+	lines := strings.Split(strings.ReplaceAll(strings.TrimRight(p.text, "\r\n"), "\r\n", "\n"), "\n")
+	var preview []string
+	for i, line := range lines {
+		if i == pastePreviewLines {
+			preview = append(preview, atoms.TextMuted(p.ctx, fmt.Sprintf("… %d more lines", len(lines)-i)))
+			break
+		}
+		line = strings.Map(func(r rune) rune {
+			switch {
+			case r == 0x1b:
+				return '␛'
+			case r < 0x20 && r != '\t' || r == 0x7f:
+				return '·'
+			}
+			return r
+		}, line)
+		line = strings.ReplaceAll(line, "\t", "    ")
+		preview = append(preview, atoms.Text(p.ctx, truncateWidth(line, contentWidth-2)))
+	}
+	previewBox := lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(p.ctx.Theme.Border).
+		BorderBackground(p.ctx.Theme.Bg).
+		Background(p.ctx.Theme.Bg).
+		PaddingLeft(1).
+		Render(strings.Join(preview, "\n"))
+
+	footer := atoms.TextMuted(p.ctx, "Enter/y paste • Esc/n cancel")
+
+	emptyRow := atoms.Fill(p.ctx, 1)
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		emptyRow,
+		strings.Join(reasons, "\n"),
+		emptyRow,
+		previewBox,
+		emptyRow,
+		footer,
+	)
+
+	return templates.Modal(p.ctx, content, p.width, p.height)
+}
+
+// truncateWidth cuts s to at most width cells, marking the cut with "…"
+func truncateWidth(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	var sb strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := lipgloss.Width(string(r))
+		if w+rw > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		w += rw
+	}
+	return sb.String() + "…"
+}
@@ -0,0 +1,300 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/monitor"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+	"github.com/ousiass/GoNeSh/internal/ui/context"
+	"github.com/ousiass/GoNeSh/internal/ui/molecules"
+	"github.com/ousiass/GoNeSh/internal/ui/templates"
+)
+
+// How often the resource monitor refreshes its details
+const monitorRefreshInterval = 2 * time.Second
+
+// monitorDetailsMsg carries the details fetched for the resource monitor.
+// seq tells apart the fetches of an earlier opening of the monitor.
+type monitorDetailsMsg struct {
+	seq     int
+	details monitor.Details
+}
+
+// monitorTickMsg asks the resource monitor to fetch the details again
+type monitorTickMsg struct {
+	seq int
+}
+
+// ResourceMonitor shows the details of one system resource, opened by
+// clicking its meter in the status bar
+type ResourceMonitor struct {
+	ctx     *context.UI
+	width   int
+	height  int
+	visible bool
+	seq     int
+
+	kind    molecules.ResourceType
+	gpu     int // index of the GPU for ResourceGPU
+	history []float64
+	details monitor.Details
+	loaded  bool
+}
+
+// NewResourceMonitor creates a new resource monitor
+func NewResourceMonitor(ctx *context.UI) *ResourceMonitor {
+	return &ResourceMonitor{ctx: ctx}
+}
+
+// Show shows the monitor for a resource and starts fetching its details.
+// gpu is the index of the GPU for ResourceGPU.
+func (m *ResourceMonitor) Show(kind molecules.ResourceType, gpu int) tea.Cmd {
+	m.visible = true
+	m.seq++
+	m.kind = kind
+	m.gpu = gpu
+	m.history = nil
+	m.details = monitor.Details{}
+	m.loaded = false
+	return m.fetch()
+}
+
+// Hide hides the monitor
+func (m *ResourceMonitor) Hide() {
+	m.visible = false
+	m.history = nil
+}
+
+// IsVisible returns whether the monitor is visible
+func (m *ResourceMonitor) IsVisible() bool {
+	return m.visible
+}
+
+// SetSize sets the modal size
+func (m *ResourceMonitor) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+// Resource returns the resource shown and the index of the GPU for
+// ResourceGPU
+func (m *ResourceMonitor) Resource() (kind molecules.ResourceType, gpu int) {
+	return m.kind, m.gpu
+}
+
+// SetHistory sets the recent usage samples of the resource, oldest first
+func (m *ResourceMonitor) SetHistory(samples []float64) {
+	m.history = samples
+}
+
+// Update handles messages for the monitor
+func (m *ResourceMonitor) Update(msg tea.Msg) (*ResourceMonitor, tea.Cmd) {
+	switch msg := msg.(type) {
+	case monitorDetailsMsg:
+		if !m.visible || msg.seq != m.seq {
+			return m, nil
+		}
+		m.details = msg.details
+		m.loaded = true
+		seq := m.seq
+		return m, tea.Tick(monitorRefreshInterval, func(time.Time) tea.Msg {
+			return monitorTickMsg{seq: seq}
+		})
+
+	case monitorTickMsg:
+		if !m.visible || msg.seq != m.seq {
+			return m, nil
+		}
+		return m, m.fetch()
+
+	case tea.KeyMsg:
+		if !m.visible {
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc", "q", "ctrl+c", "ctrl+g":
+			m.Hide()
+		}
+
+	case tea.MouseMsg:
+		// A click anywhere closes the popup
+		if m.visible && msg.Action == tea.MouseActionPress && !tea.MouseEvent(msg).IsWheel() {
+			m.Hide()
+		}
+	}
+	return m, nil
+}
+
+// fetch returns a command that fetches the details of the resources
+func (m *ResourceMonitor) fetch() tea.Cmd {
+	seq := m.seq
+	return func() tea.Msg {
+		return monitorDetailsMsg{seq: seq, details: monitor.FetchDetails()}
+	}
+}
+
+// View renders the monitor
+func (m *ResourceMonitor) View() string {
+	if !m.visible || m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	contentWidth := min(max(m.width-8, 30), 64)
+
+	var title string
+	var body []string
+	switch m.kind {
+	case molecules.ResourceCPU:
+		title, body = m.cpuView(contentWidth)
+	case molecules.ResourceMEM:
+		title, body = m.memView(contentWidth)
+	default:
+		title, body = m.gpuView(contentWidth)
+	}
+	if !m.loaded {
+		body = []string{atoms.TextMuted(m.ctx, "Loading...")}
+	}
+
+	footer := atoms.TextMuted(m.ctx, "Esc/q/click close • updates every 2s")
+
+	emptyRow := atoms.Fill(m.ctx, 1)
+	lines := []string{atoms.Title(m.ctx, title), emptyRow}
+	lines = append(lines, body...)
+	lines = append(lines, emptyRow, footer)
+	content := lipgloss.NewStyle().
+		Width(contentWidth).
+		Background(m.ctx.Theme.Bg).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return templates.ModalWithPadding(m.ctx, content, m.width, m.height, 0, 2)
+}
+
+// cpuView renders the usage of each core and the load average
+func (m *ResourceMonitor) cpuView(width int) (string, []string) {
+	d := m.details
+	title := "CPU Monitor"
+	if d.CPUError != nil {
+		return title, []string{atoms.ErrorText(m.ctx, d.CPUError.Error())}
+	}
+
+	body := m.usage(width, m.ctx.Theme.CPU)
+	if d.CPU.Model != "" {
+		body = append([]string{atoms.TextAlt(m.ctx, truncateWidth(d.CPU.Model, width))}, body...)
+	}
+	body = append(body, "",
+		m.field("Load", fmt.Sprintf("%.2f  %.2f  %.2f", d.CPU.Load[0], d.CPU.Load[1], d.CPU.Load[2])),
+		"",
+		atoms.Label(m.ctx, fmt.Sprintf("CORES (%d)", len(d.CPU.Cores)), m.ctx.Theme.Secondary),
+	)
+
+	// Cores in as many columns as fit
+	cells := make([]string, len(d.CPU.Cores))
+	for i, p := range d.CPU.Cores {
+		cells[i] = atoms.TextMuted(m.ctx, fmt.Sprintf("%3d ", i)) +
+			atoms.MeterCPU(m.ctx, p) + atoms.TextAlt(m.ctx, fmt.Sprintf(" %3.0f%%  ", p))
+	}
+	if len(cells) > 0 {
+		perRow := max(width/lipgloss.Width(cells[0]), 1)
+		for i := 0; i < len(cells); i += perRow {
+			body = append(body, strings.Join(cells[i:min(i+perRow, len(cells))], ""))
+		}
+	}
+	return title, body
+}
+
+// memView renders the memory and swap in use
+func (m *ResourceMonitor) memView(width int) (string, []string) {
+	d := m.details
+	title := "Memory Monitor"
+	if d.MEMError != nil {
+		return title, []string{atoms.ErrorText(m.ctx, d.MEMError.Error())}
+	}
+
+	body := m.usage(width, m.ctx.Theme.MEM)
+	body = append(body, "",
+		m.field("Used", formatBytes(d.MEM.Used)+" / "+formatBytes(d.MEM.Total)),
+		m.field("Available", formatBytes(d.MEM.Available)),
+	)
+	if d.MEM.SwapTotal > 0 {
+		body = append(body, m.field("Swap", formatBytes(d.MEM.SwapUsed)+" / "+formatBytes(d.MEM.SwapTotal)))
+	}
+	return title, body
+}
+
+// gpuView renders the utilization and memory of the GPU
+func (m *ResourceMonitor) gpuView(width int) (string, []string) {
+	d := m.details
+	title := fmt.Sprintf("GPU%d Monitor", m.gpu)
+	if d.GPUError != nil {
+		return title, []string{atoms.ErrorText(m.ctx, d.GPUError.Error())}
+	}
+
+	var gpu *monitor.GPU
+	for i := range d.GPUs {
+		if d.GPUs[i].Index == m.gpu {
+			gpu = &d.GPUs[i]
+		}
+	}
+	if gpu == nil {
+		return title, []string{atoms.TextMuted(m.ctx, "GPU not found")}
+	}
+
+	body := []string{atoms.TextAlt(m.ctx, truncateWidth(gpu.Name, width))}
+	body = append(body, m.usage(width, m.ctx.Theme.GPU)...)
+	body = append(body, "",
+		m.field("Memory", fmt.Sprintf("%d / %d MiB", gpu.MemUsed, gpu.MemTotal)+"  ")+
+			atoms.MeterGPU(m.ctx, gpu.MemPercent())+
+			atoms.TextAlt(m.ctx, fmt.Sprintf(" %3.0f%%", gpu.MemPercent())),
+	)
+	return title, body
+}
+
+// usage renders the latest usage of the resource and a graph of the
+// recent samples
+func (m *ResourceMonitor) usage(width int, color lipgloss.Color) []string {
+	if len(m.history) == 0 {
+		return []string{m.field("Usage", "-")}
+	}
+	latest := m.history[len(m.history)-1]
+	return []string{
+		m.field("Usage", fmt.Sprintf("%.0f%%", latest)),
+		atoms.Label(m.ctx, percentSparkline(m.history, width), color),
+	}
+}
+
+// field renders a name and a value on one line
+func (m *ResourceMonitor) field(name, value string) string {
+	return atoms.TextMuted(m.ctx, fmt.Sprintf("%-10s", name)) + atoms.Text(m.ctx, value)
+}
+
+// percentSparkline draws the last width percentages as a line of bars
+func percentSparkline(samples []float64, width int) string {
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	var sb strings.Builder
+	for _, p := range samples {
+		level := int(p / 100 * float64(len(sparkLevels)))
+		sb.WriteRune(sparkLevels[min(max(level, 0), len(sparkLevels)-1)])
+	}
+	return sb.String()
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -3,6 +3,7 @@ package organisms
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,6 +21,10 @@ type tickMsg time.Time
 // resourceMsg carries resource information
 type resourceMsg monitor.Resources
 
+// Resource samples kept for the graphs of the resource monitor, two
+// minutes at one sample per tick
+const resourceHistorySize = 60
+
 // StatusBar represents the bottom status bar
 type StatusBar struct {
 	ctx       *context.UI
@@ -28,14 +33,28 @@ type StatusBar struct {
 	mode      string // 現在のモード（normal, ai, etc.）
 	preset    string // 現在のプリセット名
 	env       string // 環境（local, dev, prod）
+
+	// 出力検索のマッチ数
+	searching   bool
+	searchIndex int
+	searchTotal int
+
+	// 履歴ファイルが読めず、コマンドをメモリにだけ残している
+	historyReadOnly bool
+
+	// Recent samples of each resource, oldest first
+	cpuHistory []float64
+	memHistory []float64
+	gpuHistory map[int][]float64
 }
 
 // NewStatusBar creates a new status bar
 func NewStatusBar(ctx *context.UI) *StatusBar {
 	return &StatusBar{
-		ctx:  ctx,
-		mode: "normal",
-		env:  "local",
+		ctx:        ctx,
+		mode:       "normal",
+		env:        "local",
+		gpuHistory: map[int][]float64{},
 	}
 }
 
@@ -57,11 +76,30 @@ func (s *StatusBar) SetEnv(env string) {
 	s.env = env
 }
 
+// Env returns the environment indicator
+func (s *StatusBar) Env() string {
+	return s.env
+}
+
 // SetPreset sets the current preset name
 func (s *StatusBar) SetPreset(preset string) {
 	s.preset = preset
 }
 
+// SetSearch sets the terminal search match indicator.
+// index is the 1-based selected match, 0 for none.
+func (s *StatusBar) SetSearch(index, total int, active bool) {
+	s.searching = active
+	s.searchIndex = index
+	s.searchTotal = total
+}
+
+// SetHistoryError sets why the history file is left alone, nil if it is
+// in use
+func (s *StatusBar) SetHistoryError(err error) {
+	s.historyReadOnly = err != nil
+}
+
 // Update handles messages for the status bar
 func (s *StatusBar) Update(msg tea.Msg) (*StatusBar, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -69,22 +107,60 @@ func (s *StatusBar) Update(msg tea.Msg) (*StatusBar, tea.Cmd) {
 		return s, tea.Batch(s.tick(), s.fetchResources())
 	case resourceMsg:
 		s.resources = monitor.Resources(msg)
+		s.recordHistory()
 	}
 	return s, nil
 }
 
-// View renders the status bar
-func (s *StatusBar) View() string {
-	if s.width == 0 {
-		return ""
+// recordHistory adds the current resource usage to the samples
+func (s *StatusBar) recordHistory() {
+	if s.resources.CPUError == nil {
+		s.cpuHistory = appendSample(s.cpuHistory, s.resources.CPU)
+	}
+	if s.resources.MEMError == nil {
+		s.memHistory = appendSample(s.memHistory, s.resources.MEM)
 	}
+	for _, gpu := range s.resources.GPUs {
+		s.gpuHistory[gpu.Index] = appendSample(s.gpuHistory[gpu.Index], gpu.Percent)
+	}
+}
 
-	// Left side: Resource meters
-	cpuMeter := molecules.Resource(s.ctx, molecules.ResourceCPU, s.resources.CPU, s.resources.CPUError != nil)
-	memMeter := molecules.Resource(s.ctx, molecules.ResourceMEM, s.resources.MEM, s.resources.MEMError != nil)
+// appendSample appends v to samples, dropping the oldest beyond
+// resourceHistorySize
+func appendSample(samples []float64, v float64) []float64 {
+	samples = append(samples, v)
+	if len(samples) > resourceHistorySize {
+		samples = samples[len(samples)-resourceHistorySize:]
+	}
+	return samples
+}
 
-	sep := atoms.Separator(s.ctx)
-	left := lipgloss.JoinHorizontal(lipgloss.Top, cpuMeter, sep, memMeter)
+// History returns the recent samples of a resource, oldest first.
+// gpu is the index of the GPU for ResourceGPU.
+func (s *StatusBar) History(kind molecules.ResourceType, gpu int) []float64 {
+	switch kind {
+	case molecules.ResourceCPU:
+		return s.cpuHistory
+	case molecules.ResourceMEM:
+		return s.memHistory
+	default:
+		return s.gpuHistory[gpu]
+	}
+}
+
+// statusMeter is a resource meter rendered in the status bar
+type statusMeter struct {
+	kind molecules.ResourceType
+	gpu  int // index of the GPU for ResourceGPU
+	view string
+}
+
+// meters renders the resource meters shown on the left side
+func (s *StatusBar) meters() []statusMeter {
+	meters := []statusMeter{
+		{kind: molecules.ResourceCPU, view: molecules.Resource(s.ctx, molecules.ResourceCPU, s.resources.CPU, s.resources.CPUError != nil)},
+		{kind: molecules.ResourceMEM, view: molecules.Resource(s.ctx, molecules.ResourceMEM, s.resources.MEM, s.resources.MEMError != nil)},
+	}
 
 	// GPUs (multiple)
 	for i, gpu := range s.resources.GPUs {
@@ -92,12 +168,54 @@ func (s *StatusBar) View() string {
 		if len(s.resources.GPUs) > 1 {
 			label = fmt.Sprintf("GPU%d", i)
 		}
-		gpuMeter := s.renderGPU(label, gpu.Percent, s.resources.GPUError != nil)
-		left = lipgloss.JoinHorizontal(lipgloss.Top, left, sep, gpuMeter)
+		meters = append(meters, statusMeter{
+			kind: molecules.ResourceGPU,
+			gpu:  gpu.Index,
+			view: s.renderGPU(label, gpu.Percent, s.resources.GPUError != nil),
+		})
+	}
+	return meters
+}
+
+// MeterAt returns the resource meter at column x of the status bar row.
+// gpu is the index of the GPU for ResourceGPU; ok is false when there is
+// no meter at x.
+func (s *StatusBar) MeterAt(x int) (kind molecules.ResourceType, gpu int, ok bool) {
+	// The bar is padded by one cell on the left
+	x -= 1
+	sepWidth := lipgloss.Width(atoms.Separator(s.ctx))
+	for _, m := range s.meters() {
+		w := lipgloss.Width(m.view)
+		if x >= 0 && x < w {
+			return m.kind, m.gpu, true
+		}
+		x -= w + sepWidth
+	}
+	return 0, 0, false
+}
+
+// View renders the status bar
+func (s *StatusBar) View() string {
+	if s.width == 0 {
+		return ""
+	}
+
+	// Left side: Resource meters
+	var meters []string
+	for _, m := range s.meters() {
+		meters = append(meters, m.view)
 	}
+	left := strings.Join(meters, atoms.Separator(s.ctx))
 
-	// Right side: Environment and preset
+	// Right side: Search matches, history warning, environment and preset
 	right := atoms.PresetBadge(s.ctx, s.preset) + atoms.EnvBadge(s.ctx, s.env)
+	if s.historyReadOnly {
+		right = atoms.IconWithText(s.ctx, atoms.IconWarning, "history read-only", s.ctx.Theme.Warning) +
+			atoms.Fill(s.ctx, 2) + right
+	}
+	if s.searching {
+		right = s.renderSearch() + atoms.Fill(s.ctx, 2) + right
+	}
 
 	// Calculate middle fill
 	leftWidth := lipgloss.Width(left)
@@ -128,6 +246,16 @@ func (s *StatusBar) renderGPU(label string, percent float64, hasError bool) stri
 	return labelText + " " + meter + percentText
 }
 
+// renderSearch renders the search match count
+func (s *StatusBar) renderSearch() string {
+	color := s.ctx.Theme.Accent
+	if s.searchTotal == 0 {
+		color = s.ctx.Theme.Warning
+	}
+	return atoms.IconWithText(s.ctx, atoms.IconSearch,
+		fmt.Sprintf("%d/%d", s.searchIndex, s.searchTotal), color)
+}
+
 // tick returns a command that triggers a tick after 2 seconds
 func (s *StatusBar) tick() tea.Cmd {
 	return tea.Tick(2*time.Second, func(t time.Time) tea.Msg {
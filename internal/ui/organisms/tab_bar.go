@@ -2,6 +2,10 @@
 package organisms
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ousiass/GoNeSh/internal/ui/atoms"
 	"github.com/ousiass/GoNeSh/internal/ui/context"
@@ -9,29 +13,56 @@ import (
 	"github.com/ousiass/GoNeSh/internal/ui/templates"
 )
 
-// Tab represents a terminal tab
+// Longest tab label in cells
+const maxTabLabelWidth = 24
+
+// Tab represents a terminal tab and owns the panes shown in it
 type Tab struct {
+	// Stable ID of the shell session, kept while the tab is moved
+	ID   string
 	Name string
 	Type molecules.TabType
+
+	// Renamed is set when the user named the tab. Otherwise the tab is
+	// labelled with the title of its focused terminal.
+	Renamed bool
+
+	// Layout tree of the panes and the focused pane
+	Root  *Pane
+	Focus *Pane
+
+	// Output or bell in the tab while it was in the background
+	marker molecules.TabMarker
+}
+
+// Label returns the name shown for the tab
+func (tab *Tab) Label() string {
+	if !tab.Renamed {
+		if title := tab.Terminal().Title(); title != "" {
+			return title
+		}
+	}
+	return tab.Name
 }
 
 // TabBar represents the tab bar component
 type TabBar struct {
 	ctx       *context.UI
-	tabs      []Tab
+	tabs      []*Tab
 	activeTab int
 	width     int
 }
 
-// NewTabBar creates a new tab bar
+// NewTabBar creates a new tab bar without tabs
 func NewTabBar(ctx *context.UI) *TabBar {
-	return &TabBar{
-		ctx: ctx,
-		tabs: []Tab{
-			{Name: "local", Type: molecules.TabTypeLocal},
-		},
-		activeTab: 0,
-	}
+	return &TabBar{ctx: ctx}
+}
+
+// newSessionID returns a random session ID
+func newSessionID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 // SetWidth sets the tab bar width
@@ -39,66 +70,186 @@ func (t *TabBar) SetWidth(width int) {
 	t.width = width
 }
 
-// AddTab adds a new tab
-func (t *TabBar) AddTab(name string, tabType string) {
+// AddTab adds a new tab with a single pane showing term and a new session
+// ID, and makes it active
+func (t *TabBar) AddTab(name string, tabType string, term *Terminal) *Tab {
+	root := newPane(term)
+	return t.addTab(name, tabType, root, root)
+}
+
+// addTab adds a new tab with the given layout tree and focused pane, and
+// makes it active
+func (t *TabBar) addTab(name string, tabType string, root, focus *Pane) *Tab {
 	tt := molecules.TabTypeLocal
 	if tabType == "ssh" {
 		tt = molecules.TabTypeSSH
 	}
-	t.tabs = append(t.tabs, Tab{Name: name, Type: tt})
-	t.activeTab = len(t.tabs) - 1
+	tab := &Tab{ID: newSessionID(), Name: name, Type: tt, Root: root}
+	tab.focusPane(focus)
+	t.tabs = append(t.tabs, tab)
+	t.SelectTab(len(t.tabs) - 1)
+	return tab
 }
 
-// CloseTab closes the current tab, returns true if app should quit
+// CloseTab closes the current tab, returns true if app should quit.
+// The tab's terminal is left for the caller to close.
 func (t *TabBar) CloseTab() bool {
+	return t.CloseTabAt(t.activeTab)
+}
+
+// CloseTabAt closes the tab at index i, returns true if app should quit.
+// The active tab stays active when another tab is closed.
+func (t *TabBar) CloseTabAt(i int) bool {
 	if len(t.tabs) <= 1 {
 		return true // quit
 	}
-	t.tabs = append(t.tabs[:t.activeTab], t.tabs[t.activeTab+1:]...)
-	if t.activeTab >= len(t.tabs) {
-		t.activeTab = len(t.tabs) - 1
+	if i < 0 || i >= len(t.tabs) {
+		return false
+	}
+	t.tabs = slices.Delete(t.tabs, i, i+1)
+	switch {
+	case i < t.activeTab:
+		t.activeTab--
+	case i == t.activeTab:
+		t.SelectTab(min(t.activeTab, len(t.tabs)-1))
 	}
 	return false
 }
 
 // NextTab switches to the next tab
 func (t *TabBar) NextTab() {
-	t.activeTab = (t.activeTab + 1) % len(t.tabs)
+	if len(t.tabs) > 0 {
+		t.SelectTab((t.activeTab + 1) % len(t.tabs))
+	}
 }
 
 // PrevTab switches to the previous tab
 func (t *TabBar) PrevTab() {
-	t.activeTab = (t.activeTab - 1 + len(t.tabs)) % len(t.tabs)
+	if len(t.tabs) > 0 {
+		t.SelectTab((t.activeTab - 1 + len(t.tabs)) % len(t.tabs))
+	}
+}
+
+// SelectTab switches to the tab at index i and clears its marker
+func (t *TabBar) SelectTab(i int) {
+	if i < 0 || i >= len(t.tabs) {
+		return
+	}
+	t.activeTab = i
+	t.tabs[i].marker = molecules.TabMarkerNone
+}
+
+// MoveTab moves the active tab by delta places, keeping it active
+func (t *TabBar) MoveTab(delta int) {
+	to := t.activeTab + delta
+	if to < 0 || to >= len(t.tabs) || delta == 0 {
+		return
+	}
+	tab := t.tabs[t.activeTab]
+	t.tabs = slices.Insert(slices.Delete(t.tabs, t.activeTab, t.activeTab+1), to, tab)
+	t.activeTab = to
+}
+
+// RenameTab names the active tab. An empty name labels the tab with its
+// terminal title again.
+func (t *TabBar) RenameTab(name string) {
+	tab := t.ActiveTab()
+	if tab == nil {
+		return
+	}
+	tab.Renamed = name != ""
+	if tab.Renamed {
+		tab.Name = name
+	}
+}
+
+// UpdateActivity marks the background tabs whose terminals received
+// output or rang the bell since the last update
+func (t *TabBar) UpdateActivity() {
+	for i, tab := range t.tabs {
+		for _, p := range tab.Panes() {
+			output, bell := p.Terminal.TakeActivity()
+			if i == t.activeTab {
+				continue
+			}
+			switch {
+			case bell:
+				tab.marker = molecules.TabMarkerBell
+			case output && tab.marker == molecules.TabMarkerNone:
+				tab.marker = molecules.TabMarkerActivity
+			}
+		}
+	}
 }
 
-// ActiveTab returns the current active tab
-func (t *TabBar) ActiveTab() Tab {
+// ActiveTab returns the current active tab, or nil if there are no tabs
+func (t *TabBar) ActiveTab() *Tab {
+	if t.activeTab >= len(t.tabs) {
+		return nil
+	}
 	return t.tabs[t.activeTab]
 }
 
+// TabByTerminal returns the tab showing the terminal with the given ID in
+// one of its panes, or nil
+func (t *TabBar) TabByTerminal(id int) *Tab {
+	for _, tab := range t.tabs {
+		for _, p := range tab.Panes() {
+			if p.Terminal.ID() == id {
+				return tab
+			}
+		}
+	}
+	return nil
+}
+
 // ActiveTabIndex returns the index of the active tab
 func (t *TabBar) ActiveTabIndex() int {
 	return t.activeTab
 }
 
 // Tabs returns all tabs
-func (t *TabBar) Tabs() []Tab {
+func (t *TabBar) Tabs() []*Tab {
 	return t.tabs
 }
 
+// HitTest returns what is at column x of the row of tabs: the index of a
+// tab, or newTab for the "+" button. index is -1 when neither is there.
+func (t *TabBar) HitTest(x int) (index int, newTab bool) {
+	// The bar is padded by one cell on the left
+	x -= 1
+	segments := t.segments()
+	for i, seg := range segments {
+		w := lipgloss.Width(seg)
+		if x >= 0 && x < w {
+			if i == len(t.tabs) {
+				return -1, true
+			}
+			return i, false
+		}
+		x -= w
+	}
+	return -1, false
+}
+
+// segments renders the tabs followed by the "+" button
+func (t *TabBar) segments() []string {
+	var segs []string
+	for i, tab := range t.tabs {
+		label := truncateWidth(tab.Label(), maxTabLabelWidth)
+		segs = append(segs, molecules.Tab(t.ctx, label, tab.Type, i == t.activeTab, tab.marker))
+	}
+	return append(segs, molecules.NewTabButton(t.ctx))
+}
+
 // View renders the tab bar
 func (t *TabBar) View() string {
 	if t.width == 0 {
 		return ""
 	}
 
-	var tabs []string
-	for i, tab := range t.tabs {
-		tabs = append(tabs, molecules.Tab(t.ctx, tab.Name, tab.Type, i == t.activeTab))
-	}
-
-	// Join tabs horizontally
-	tabsJoined := lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+	// Join tabs and the "+" button horizontally
+	tabsJoined := lipgloss.JoinHorizontal(lipgloss.Top, t.segments()...)
 
 	// Fill remaining space
 	tabsWidth := lipgloss.Width(tabsJoined)
@@ -0,0 +1,147 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ousiass/GoNeSh/internal/server"
+	"github.com/ousiass/GoNeSh/internal/session"
+	"github.com/ousiass/GoNeSh/internal/ui/molecules"
+)
+
+// Layout returns the tabs and panes in the form they are saved in
+func (t *TabBar) Layout() *session.Layout {
+	return t.layout(true)
+}
+
+// ServerLayout returns the tabs and panes in the form they are kept in
+// the GoNeSh server. The shells keep running there, so only the layout and
+// the IDs of the panes are kept, which does not ask the server anything.
+func (t *TabBar) ServerLayout() *session.Layout {
+	return t.layout(false)
+}
+
+// layout returns the saved form of the tabs, with the state of their
+// shells if shells is set
+func (t *TabBar) layout(shells bool) *session.Layout {
+	layout := &session.Layout{ActiveTab: t.activeTab}
+	for _, tab := range t.tabs {
+		st := session.Tab{
+			Type: string(tab.Type),
+			Root: tab.Root.layoutPane(tab.Focus, shells),
+		}
+		if tab.Renamed {
+			st.Name = tab.Name
+		}
+		if shells && sshCommand(tab.Terminal()) != nil {
+			st.Type = string(molecules.TabTypeSSH)
+		}
+		layout.Tabs = append(layout.Tabs, st)
+	}
+	return layout
+}
+
+// layoutPane returns the saved form of the pane and its children
+func (p *Pane) layoutPane(focus *Pane, shells bool) session.Pane {
+	if p.isLeaf() {
+		sp := session.Pane{Focused: p == focus}
+		if pane, ok := p.Terminal.Session().(*server.Pane); ok {
+			sp.Server = pane.ID()
+		}
+		if shells {
+			sp.Dir = p.Terminal.ShellDir()
+			sp.SSH = sshCommand(p.Terminal)
+		}
+		return sp
+	}
+	split := session.SplitRight
+	if p.split == SplitDown {
+		split = session.SplitDown
+	}
+	return session.Pane{
+		Split: split,
+		Ratio: p.ratio,
+		Children: []session.Pane{
+			p.children[0].layoutPane(focus, shells),
+			p.children[1].layoutPane(focus, shells),
+		},
+	}
+}
+
+// sshCommand returns the command line of ssh if it runs in the foreground
+// of the terminal, or nil
+func sshCommand(term *Terminal) []string {
+	cmd := term.ForegroundCommand()
+	if len(cmd) == 0 || filepath.Base(cmd[0]) != "ssh" {
+		return nil
+	}
+	return cmd
+}
+
+// AddSavedTab adds a tab laid out as saved and makes it active. The
+// terminals of its panes come from newTerminal and start their shells in
+// the saved directories, running ssh again where it was running.
+func (t *TabBar) AddSavedTab(st session.Tab, newTerminal func(session.Pane) *Terminal) *Tab {
+	var focus *Pane
+	root := savedPane(st.Root, nil, &focus, newTerminal)
+	if focus == nil {
+		focus = root.leaves(nil)[0]
+	}
+
+	tab := t.addTab("local", st.Type, root, focus)
+	if st.Name != "" {
+		tab.Name = st.Name
+		tab.Renamed = true
+	}
+	return tab
+}
+
+// savedPane builds the pane tree of a saved pane. The focused leaf is
+// stored in focus.
+func savedPane(sp session.Pane, parent *Pane, focus **Pane, newTerminal func(session.Pane) *Terminal) *Pane {
+	if sp.IsLeaf() {
+		term := newTerminal(sp)
+		input := ""
+		if len(sp.SSH) > 0 {
+			input = shellQuote(sp.SSH) + "\r"
+		}
+		term.SetStart(sp.Dir, input)
+
+		p := newPane(term)
+		p.parent = parent
+		if sp.Focused {
+			*focus = p
+		}
+		return p
+	}
+
+	p := &Pane{
+		split:  SplitRight,
+		ratio:  0.5,
+		parent: parent,
+	}
+	if sp.Split == session.SplitDown {
+		p.split = SplitDown
+	}
+	if sp.Ratio > 0 {
+		p.ratio = min(max(sp.Ratio, minSplitRatio), maxSplitRatio)
+	}
+	p.children[0] = savedPane(sp.Children[0], p, focus, newTerminal)
+	p.children[1] = savedPane(sp.Children[1], p, focus, newTerminal)
+	return p
+}
+
+// shellQuote joins a command line into shell input, quoting the words
+// that need it
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
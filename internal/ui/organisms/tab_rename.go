@@ -0,0 +1,140 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+	"github.com/ousiass/GoNeSh/internal/ui/context"
+	"github.com/ousiass/GoNeSh/internal/ui/templates"
+)
+
+// Longest tab name that can be typed, in runes
+const maxTabNameLength = 64
+
+// TabRenameResult is sent when the tab rename prompt is answered
+type TabRenameResult struct {
+	Name      string
+	Confirmed bool
+}
+
+// TabRename asks for a new name for the active tab
+type TabRename struct {
+	ctx     *context.UI
+	width   int
+	height  int
+	visible bool
+	name    []rune
+}
+
+// NewTabRename creates a new tab rename prompt
+func NewTabRename(ctx *context.UI) *TabRename {
+	return &TabRename{ctx: ctx}
+}
+
+// Show shows the prompt with the current name of the tab
+func (r *TabRename) Show(name string) {
+	r.visible = true
+	r.name = []rune(name)
+}
+
+// Hide hides the prompt
+func (r *TabRename) Hide() {
+	r.visible = false
+	r.name = nil
+}
+
+// IsVisible returns whether the prompt is visible
+func (r *TabRename) IsVisible() bool {
+	return r.visible
+}
+
+// SetSize sets the modal size
+func (r *TabRename) SetSize(width, height int) {
+	r.width = width
+	r.height = height
+}
+
+// Update handles messages for the prompt
+func (r *TabRename) Update(msg tea.Msg) (*TabRename, tea.Cmd) {
+	if !r.visible {
+		return r, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return r, nil
+	}
+
+	switch keyMsg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC, tea.KeyCtrlG:
+		r.Hide()
+		return r, func() tea.Msg {
+			return TabRenameResult{Confirmed: false}
+		}
+
+	case tea.KeyEnter:
+		name := string(r.name)
+		r.Hide()
+		return r, func() tea.Msg {
+			return TabRenameResult{Name: name, Confirmed: true}
+		}
+
+	case tea.KeyBackspace:
+		if len(r.name) > 0 {
+			r.name = r.name[:len(r.name)-1]
+		}
+
+	case tea.KeyCtrlU:
+		r.name = nil
+
+	case tea.KeyRunes, tea.KeySpace:
+		for _, c := range keyMsg.Runes {
+			if c >= 0x20 && c != 0x7f && len(r.name) < maxTabNameLength {
+				r.name = append(r.name, c)
+			}
+		}
+	}
+	return r, nil
+}
+
+// View renders the prompt
+func (r *TabRename) View() string {
+	if !r.visible || r.width == 0 || r.height == 0 {
+		return ""
+	}
+
+	contentWidth := min(max(r.width-12, 20), 48)
+
+	title := atoms.Title(r.ctx, atoms.IconTabs+"  Rename tab")
+
+	// Show the end of a name longer than the field
+	name := string(r.name)
+	for lipgloss.Width(name) > contentWidth-3 {
+		_, size := utf8.DecodeRuneInString(name)
+		name = name[size:]
+	}
+	input := lipgloss.NewStyle().
+		Width(contentWidth).
+		Border(lipgloss.NormalBorder(), false, false, true, false).
+		BorderForeground(r.ctx.Theme.Accent).
+		BorderBackground(r.ctx.Theme.Bg).
+		Background(r.ctx.Theme.Bg).
+		Render(atoms.Text(r.ctx, name) + atoms.Label(r.ctx, "_", r.ctx.Theme.Accent))
+
+	footer := atoms.TextMuted(r.ctx, "Enter rename • Esc cancel • empty for the title")
+
+	emptyRow := atoms.Fill(r.ctx, 1)
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		emptyRow,
+		input,
+		emptyRow,
+		footer,
+	)
+
+	return templates.Modal(r.ctx, content, r.width, r.height)
+}
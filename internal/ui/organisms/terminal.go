@@ -2,12 +2,17 @@
 package organisms
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/emulator"
 	"github.com/ousiass/GoNeSh/internal/terminal"
 	"github.com/ousiass/GoNeSh/internal/ui/context"
 )
@@ -17,12 +22,34 @@ const (
 	readBufferSize = 4096
 	// Maximum lines to keep in scrollback
 	maxScrollback = 10000
+	// Screen size used until the first SetSize
+	defaultCols = 80
+	defaultRows = 24
+	// Minimum interval between redraws while output keeps streaming
+	outputFrameInterval = time.Second / 60
+	// Minimum interval between lookups of the foreground process
+	processRefreshInterval = 500 * time.Millisecond
 )
 
-// ptyOutputMsg carries PTY output data
+// processCheckMsg asks a terminal to look up its foreground process again
+type processCheckMsg struct {
+	id int
+}
+
+// ptyStartedMsg signals that the shell has started
+type ptyStartedMsg struct {
+	id int
+}
+
+// ptyOutputMsg signals that new PTY output has been processed.
+// Notifications are coalesced, so one message may cover many reads.
 type ptyOutputMsg struct {
-	data []byte
-	id   int
+	id int
+}
+
+// ptyExitMsg signals that the PTY was closed or the shell exited
+type ptyExitMsg struct {
+	id int
 }
 
 // ptyErrorMsg signals a PTY error
@@ -34,28 +61,164 @@ type ptyErrorMsg struct {
 // Terminal represents a terminal emulator component
 type Terminal struct {
 	ctx    *context.UI
-	pty    *terminal.PTY
+	pty    terminal.Session
 	id     int
 	width  int
 	height int
 
-	// Output buffer
-	lines      []string
-	scrollPos  int
-	mu         sync.Mutex
+	// Screen model fed by the PTY output
+	emu *emulator.Emulator
+	mu  sync.Mutex
+
+	// Scrollback view. scrollTop is the line number of the top visible line
+	// (see emulator.TrimmedLines), so the view stays put while output scrolls.
+	scrolling bool
+	scrollTop int
+	search    searchState
+	sel       selection
+
+	// Set while another pane of the tab has the focus
+	blurred bool
+
+	// Output and bells since the last TakeActivity
+	activity bool
+	bell     bool
+
+	// Name of the foreground process, refreshed on output, when it was last
+	// looked up and whether a processCheckMsg is on its way
+	process        string
+	processChecked time.Time
+	processCheck   bool
+
+	// Directory the shell starts in and input typed into it once it has
+	// started, to restore a saved session
+	startDir   string
+	startInput string
+
+	// Starts the session of the terminal; nil starts a local shell
+	start SessionFunc
+
+	// Prompt navigation position (see promptRef)
+	promptLine int
+	promptTop  int
+
+	// Shell commands finished since the last output notification
+	finished []emulator.Command
+
+	// Command line suggestion for the input typed at the prompt
+	suggest      SuggestFunc
+	suggestInput string
+	suggestion   string
+
+	// Input for the PTY, written by a goroutine of its own
+	input *terminal.InputQueue
+
+	// Output notifications from the read goroutine
+	output     chan struct{}
+	done       chan struct{}
+	lastOutput time.Time
 
 	// State
 	running bool
 	err     error
 }
 
+// SessionFunc starts the shell session of a terminal in dir, with a
+// terminal of rows x cols cells. A session whose screen model is kept by
+// another process implements RemoteScreen.
+type SessionFunc func(dir string, rows, cols int) (terminal.Session, error)
+
+// RemoteScreen is implemented by sessions whose screen model is kept by the
+// GoNeSh server, which also replies to device queries
+type RemoteScreen interface {
+	// Screen returns the screen of the session when it was started
+	Screen() emulator.Snapshot
+}
+
 // NewTerminal creates a new terminal component
 func NewTerminal(ctx *context.UI, id int) *Terminal {
-	return &Terminal{
-		ctx:   ctx,
-		id:    id,
-		lines: []string{},
+	t := &Terminal{
+		ctx:    ctx,
+		id:     id,
+		emu:    emulator.New(defaultCols, defaultRows, maxScrollback),
+		input:  terminal.NewInputQueue(),
+		output: make(chan struct{}, 1),
+		done:   make(chan struct{}),
 	}
+	// Called from processOutput with the lock held
+	t.emu.SetCommandFunc(func(c emulator.Command) {
+		t.finished = append(t.finished, c)
+	})
+	t.emu.SetBellFunc(func() {
+		t.bell = true
+	})
+	return t
+}
+
+// ID returns the terminal ID
+func (t *Terminal) ID() int {
+	return t.id
+}
+
+// WorkingDir returns the working directory of the shell and the host it
+// runs on, if the shell reports them (see emulator.WorkingDir)
+func (t *Terminal) WorkingDir() (dir, host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.emu.WorkingDir()
+}
+
+// ShellDir returns the working directory of the shell on this host, or ""
+// if unknown. It differs from WorkingDir while the shell runs ssh.
+func (t *Terminal) ShellDir() string {
+	t.mu.Lock()
+	pty := t.pty
+	t.mu.Unlock()
+	if pty != nil {
+		if dir := pty.WorkingDir(); dir != "" {
+			return dir
+		}
+	}
+
+	// The directory reported by a shell on this host
+	dir, host := t.WorkingDir()
+	if local, _ := os.Hostname(); host == "" || host == local || host == "localhost" {
+		return dir
+	}
+	return ""
+}
+
+// ForegroundCommand returns the command line of the program running in
+// the foreground of the terminal, or nil if unknown
+func (t *Terminal) ForegroundCommand() []string {
+	t.mu.Lock()
+	pty := t.pty
+	t.mu.Unlock()
+	if pty == nil {
+		return nil
+	}
+	return pty.ForegroundCommand()
+}
+
+// SetStart sets the directory the shell starts in and input typed into it
+// once it has started. It must be called before Init.
+func (t *Terminal) SetStart(dir, input string) {
+	t.startDir = dir
+	t.startInput = input
+}
+
+// SetSessionFunc sets the function that starts the session of the
+// terminal. It must be called before Init.
+func (t *Terminal) SetSessionFunc(start SessionFunc) {
+	t.start = start
+}
+
+// Session returns the shell session of the terminal, or nil before it has
+// started
+func (t *Terminal) Session() terminal.Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pty
 }
 
 // Init initializes the terminal and starts the shell
@@ -66,29 +229,63 @@ func (t *Terminal) Init() tea.Cmd {
 // startShell starts a new shell session
 func (t *Terminal) startShell() tea.Cmd {
 	return func() tea.Msg {
-		pty, err := terminal.New()
+		// A directory removed since the session was saved is skipped
+		dir := t.startDir
+		if info, err := os.Stat(dir); dir != "" && (err != nil || !info.IsDir()) {
+			dir = ""
+		}
+		t.mu.Lock()
+		cols, rows := t.width, t.height
+		t.mu.Unlock()
+		if cols == 0 || rows == 0 {
+			cols, rows = defaultCols, defaultRows
+		}
+
+		var pty terminal.Session
+		var err error
+		if t.start != nil {
+			pty, err = t.start(dir, rows, cols)
+		} else {
+			pty, err = terminal.NewInDir(dir)
+		}
 		if err != nil {
 			return ptyErrorMsg{err: err, id: t.id}
 		}
 		t.mu.Lock()
 		t.pty = pty
 		t.running = true
-		t.mu.Unlock()
-
-		// Set initial size
+		if remote, ok := pty.(RemoteScreen); ok {
+			// The screen continues from where the server is
+			t.emu.Restore(remote.Screen())
+			if t.width > 0 && t.height > 0 {
+				t.emu.Resize(t.width, t.height)
+			}
+		} else {
+			// Replies to device queries (cursor position etc.) go back to the shell
+			t.emu.SetReplyWriter(t.input)
+		}
+		// Set initial size, in case it changed while the shell started
 		if t.width > 0 && t.height > 0 {
 			_ = pty.Resize(uint16(t.height), uint16(t.width))
 		}
+		t.mu.Unlock()
+
+		if t.startInput != "" {
+			_, _ = io.WriteString(t.input, t.startInput)
+		}
 
-		// Start reading output
+		// Start reading output and writing input
 		go t.readLoop()
+		go t.input.Run(pty, t.done)
 
-		return nil
+		return ptyStartedMsg{id: t.id}
 	}
 }
 
-// readLoop continuously reads from the PTY
+// readLoop continuously reads from the PTY and notifies the UI
 func (t *Terminal) readLoop() {
+	defer close(t.done)
+
 	buf := make([]byte, readBufferSize)
 	for {
 		t.mu.Lock()
@@ -100,42 +297,46 @@ func (t *Terminal) readLoop() {
 		t.mu.Unlock()
 
 		n, err := pty.Read(buf)
-		if err != nil {
-			return
-		}
 		if n > 0 {
 			t.mu.Lock()
 			t.processOutput(buf[:n])
 			t.mu.Unlock()
+			t.notifyOutput()
+		}
+		if err != nil {
+			return
 		}
 	}
 }
 
-// processOutput processes raw PTY output and adds to buffer
-func (t *Terminal) processOutput(data []byte) {
-	// Simple line-based processing
-	// TODO: Implement proper ANSI escape sequence handling
-	text := string(data)
+// notifyOutput signals new output without blocking.
+// A pending notification already covers the new data, so bursts of reads
+// collapse into a single message.
+func (t *Terminal) notifyOutput() {
+	select {
+	case t.output <- struct{}{}:
+	default:
+	}
+}
 
-	// Split by newlines but keep partial lines
-	parts := strings.Split(text, "\n")
+// processOutput feeds raw PTY output to the screen model.
+// Full-screen programs draw into the emulator's alternate screen, so the
+// primary screen and its scrollback are left untouched while they run.
+func (t *Terminal) processOutput(data []byte) {
+	_, _ = t.emu.Write(data)
+	t.activity = true
 
-	for i, part := range parts {
-		if i == 0 && len(t.lines) > 0 {
-			// Append to the last line
-			t.lines[len(t.lines)-1] += part
-		} else {
-			t.lines = append(t.lines, part)
-		}
+	// A full-screen program taking over ends scroll mode
+	alt := t.emu.Modes().AltScreen
+	if t.scrolling && alt {
+		t.stopScroll()
 	}
-
-	// Trim scrollback if needed
-	if len(t.lines) > maxScrollback {
-		t.lines = t.lines[len(t.lines)-maxScrollback:]
+	if t.sel.active && t.sel.alt != alt {
+		t.clearSelection()
+	}
+	if t.search.query != "" {
+		t.search.dirty = true
 	}
-
-	// Auto-scroll to bottom
-	t.scrollPos = len(t.lines)
 }
 
 // SetSize sets the terminal size
@@ -143,33 +344,146 @@ func (t *Terminal) SetSize(width, height int) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if width == t.width && height == t.height {
+		return
+	}
 	t.width = width
 	t.height = height
+	t.emu.Resize(width, height)
+
+	// Resizing moves lines between the screen and the scrollback
+	if t.search.query != "" {
+		t.search.scanned = 0
+		t.search.dirty = true
+	}
 
 	if t.pty != nil && t.running {
 		_ = t.pty.Resize(uint16(height), uint16(width))
 	}
 }
 
+// Title returns the title the program in the terminal set with OSC 0/2,
+// or else the name of the foreground process
+func (t *Terminal) Title() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if title := t.emu.Title(); title != "" {
+		return title
+	}
+	return t.process
+}
+
+// refreshProcess looks up the foreground process of the terminal after
+// output, at most once per processRefreshInterval. The process often
+// changes just after the output that announced it (the echo of Enter), so
+// it is looked up once more processRefreshInterval later.
+func (t *Terminal) refreshProcess() tea.Cmd {
+	t.mu.Lock()
+	due := time.Since(t.processChecked) >= processRefreshInterval
+	schedule := !t.processCheck
+	t.processCheck = true
+	t.mu.Unlock()
+
+	if due {
+		t.lookupProcess()
+	}
+	if !schedule {
+		return nil
+	}
+	return tea.Tick(processRefreshInterval, func(time.Time) tea.Msg {
+		return processCheckMsg{id: t.id}
+	})
+}
+
+// lookupProcess looks up the foreground process of the terminal
+func (t *Terminal) lookupProcess() {
+	t.mu.Lock()
+	pty := t.pty
+	t.processChecked = time.Now()
+	t.mu.Unlock()
+	if pty == nil {
+		return
+	}
+	process := pty.ForegroundProcess()
+	t.mu.Lock()
+	t.process = process
+	t.mu.Unlock()
+}
+
+// TakeActivity returns whether the terminal received output and whether
+// the bell rang since the last call
+func (t *Terminal) TakeActivity() (output, bell bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	output, bell = t.activity, t.bell
+	t.activity, t.bell = false, false
+	return output, bell
+}
+
+// SetFocused sets whether the terminal has the focus. A terminal without
+// the focus does not draw its cursor or suggestion.
+func (t *Terminal) SetFocused(focused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.blurred = !focused
+}
+
 // Update handles messages for the terminal
 func (t *Terminal) Update(msg tea.Msg) (*Terminal, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if handled, cmd := t.handleScrollKey(msg); handled {
+			return t, cmd
+		}
 		return t.handleKeyInput(msg)
-	case ptyOutputMsg:
+	case tea.MouseMsg:
+		return t, t.handleMouse(msg)
+	case ptyStartedMsg:
 		if msg.id == t.id {
-			// Output is handled in readLoop
 			return t, t.waitForOutput()
 		}
+	case ptyOutputMsg:
+		if msg.id == t.id {
+			// Output was already processed in readLoop; wait for more
+			return t, tea.Batch(t.waitForOutput(), t.finishedCommands(), t.refreshProcess())
+		}
+	case processCheckMsg:
+		if msg.id == t.id {
+			t.mu.Lock()
+			t.processCheck = false
+			t.mu.Unlock()
+			t.lookupProcess()
+		}
+	case ptyExitMsg:
+		if msg.id == t.id {
+			t.mu.Lock()
+			t.running = false
+			t.mu.Unlock()
+			return t, t.finishedCommands()
+		}
 	case ptyErrorMsg:
 		if msg.id == t.id {
 			t.err = msg.err
 			t.running = false
 		}
+	default:
+		if dir, ok := pageScrollKey(msg); ok {
+			t.handlePageScroll(dir)
+		}
 	}
 	return t, nil
 }
 
+// IsPTYMsg returns whether msg is a PTY notification addressed to a
+// terminal by ID. These must reach every terminal, not only the active one.
+func IsPTYMsg(msg tea.Msg) bool {
+	switch msg.(type) {
+	case ptyStartedMsg, ptyOutputMsg, ptyExitMsg, ptyErrorMsg, processCheckMsg:
+		return true
+	}
+	return false
+}
+
 // handleKeyInput handles keyboard input
 func (t *Terminal) handleKeyInput(msg tea.KeyMsg) (*Terminal, tea.Cmd) {
 	t.mu.Lock()
@@ -179,56 +493,41 @@ func (t *Terminal) handleKeyInput(msg tea.KeyMsg) (*Terminal, tea.Cmd) {
 		return t, nil
 	}
 
-	// Convert key to bytes and send to PTY
-	var data []byte
-	switch msg.Type {
-	case tea.KeyEnter:
-		data = []byte("\r")
-	case tea.KeyBackspace:
-		data = []byte{0x7f}
-	case tea.KeyTab:
-		data = []byte("\t")
-	case tea.KeyEscape:
-		data = []byte{0x1b}
-	case tea.KeyUp:
-		data = []byte("\x1b[A")
-	case tea.KeyDown:
-		data = []byte("\x1b[B")
-	case tea.KeyRight:
-		data = []byte("\x1b[C")
-	case tea.KeyLeft:
-		data = []byte("\x1b[D")
-	case tea.KeyCtrlC:
-		data = []byte{0x03}
-	case tea.KeyCtrlD:
-		data = []byte{0x04}
-	case tea.KeyCtrlZ:
-		data = []byte{0x1a}
-	case tea.KeyCtrlL:
-		data = []byte{0x0c}
-	case tea.KeyRunes:
-		data = []byte(string(msg.Runes))
-	case tea.KeySpace:
-		data = []byte(" ")
-	default:
-		// For other keys, try to use the string representation
-		if msg.String() != "" && len(msg.String()) == 1 {
-			data = []byte(msg.String())
-		}
+	if t.acceptSuggestion(msg.String()) {
+		return t, nil
 	}
 
+	// Convert key to the bytes an xterm would send
+	data := emulator.EncodeKey(tea.Key(msg), t.emu.Modes())
 	if len(data) > 0 {
-		_, _ = t.pty.Write(data)
+		t.clearSelection()
+		_, _ = t.input.Write(data)
 	}
 
 	return t, nil
 }
 
-// waitForOutput returns a command that waits briefly for more output
+// waitForOutput returns a command that blocks until the read goroutine
+// reports new output, so idle terminals cost nothing.
+// While output keeps streaming, messages are limited to one per frame.
 func (t *Terminal) waitForOutput() tea.Cmd {
-	return tea.Tick(10*time.Millisecond, func(time.Time) tea.Msg {
-		return ptyOutputMsg{id: t.id}
-	})
+	return func() tea.Msg {
+		select {
+		case <-t.output:
+			t.mu.Lock()
+			wait := outputFrameInterval - time.Since(t.lastOutput)
+			t.mu.Unlock()
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+			t.mu.Lock()
+			t.lastOutput = time.Now()
+			t.mu.Unlock()
+			return ptyOutputMsg{id: t.id}
+		case <-t.done:
+			return ptyExitMsg{id: t.id}
+		}
+	}
 }
 
 // View renders the terminal
@@ -246,42 +545,193 @@ func (t *Terminal) View() string {
 			Render("Error: " + t.err.Error())
 	}
 
-	// Calculate visible lines
-	visibleLines := t.height
-	startLine := 0
-	if len(t.lines) > visibleLines {
-		startLine = len(t.lines) - visibleLines
+	_, rows := t.emu.Size()
+	rows = min(rows, t.height)
+
+	var lines []string
+	if t.scrolling {
+		lines = t.scrollbackLines(rows)
+	} else {
+		// Render the visible screen
+		top := 0
+		if !t.emu.Modes().AltScreen {
+			top = t.liveTop()
+		}
+		cursor := t.emu.Cursor()
+		showCursor := cursor.Visible && t.running && !t.blurred
+		suggestion := ""
+		if !t.blurred {
+			suggestion = t.currentSuggestion()
+		}
+		lines = make([]string, 0, rows)
+		for y := 0; y < rows; y++ {
+			line := t.emu.Line(y)
+			marks := t.selectionMarks(top + y)
+			cursorX := -1
+			if showCursor && y == cursor.Y {
+				cursorX = cursor.X
+			}
+			if suggestion != "" && y == cursor.Y {
+				var mark lineMark
+				line, mark = suggestionLine(line, cursor.X, suggestion)
+				marks = append(marks, mark)
+			}
+			lines = append(lines, t.renderLine(line, cursorX, t.width, marks))
+		}
+	}
+
+	return lipgloss.NewStyle().
+		Width(t.width).
+		Height(t.height).
+		Background(t.ctx.Theme.Bg).
+		Foreground(t.ctx.Theme.Text).
+		Render(strings.Join(lines, "\n"))
+}
+
+// renderLine renders at most width cells of a line, drawing the cursor at
+// cursorX (-1 for none) and the highlights in marks. Cells are grouped into
+// runs with the same attributes so that each run is rendered with a single
+// style.
+func (t *Terminal) renderLine(line emulator.Line, cursorX, width int, marks []lineMark) string {
+	cells := line.Cells
+	if len(cells) > width {
+		cells = cells[:width]
+	}
+
+	// Trailing blank cells are left to the padding of the view
+	end := len(cells)
+	for end > 0 && end-1 != cursorX && isBlankCell(cells[end-1]) {
+		end--
+	}
+	for _, m := range marks {
+		end = max(end, min(m.end, len(cells)))
+	}
+
+	var sb, run strings.Builder
+	var runAttr emulator.Attr
+	var runMark markKind
+	flush := func() {
+		if run.Len() > 0 {
+			sb.WriteString(t.cellStyle(runAttr, runMark).Render(run.String()))
+			run.Reset()
+		}
 	}
 
-	// Build output
-	var output strings.Builder
-	for i := startLine; i < len(t.lines) && i < startLine+visibleLines; i++ {
-		line := t.lines[i]
-		// Truncate long lines
-		if len(line) > t.width {
-			line = line[:t.width]
+	for x := 0; x < end; x++ {
+		cell := cells[x]
+		if cell.Width == 0 {
+			continue
+		}
+		attr := cell.Attr
+		if x == cursorX {
+			attr.Flags ^= emulator.AttrReverse
 		}
-		output.WriteString(line)
-		if i < len(t.lines)-1 {
-			output.WriteString("\n")
+		mark := markAt(marks, x)
+		if mark == markSelect {
+			attr.Flags ^= emulator.AttrReverse
 		}
+		if attr != runAttr || mark != runMark {
+			flush()
+			runAttr = attr
+			runMark = mark
+		}
+		r := cell.Rune
+		if r == 0 {
+			r = ' '
+		}
+		run.WriteRune(r)
 	}
+	flush()
+
+	return sb.String()
+}
+
+// markKind is a highlight drawn over terminal cells.
+// Later kinds are drawn over earlier ones.
+type markKind uint8
+
+const (
+	markNone markKind = iota
+	markSuggest
+	markMatch
+	markCurrent
+	markSelect
+)
+
+// lineMark highlights the columns [start, end) of a rendered line
+type lineMark struct {
+	start int
+	end   int
+	kind  markKind
+}
+
+// lineMarks returns the search and selection highlights of a line of the
+// scrollback view
+func (t *Terminal) lineMarks(line int) []lineMark {
+	return append(t.searchMarks(line), t.selectionMarks(line)...)
+}
 
-	// Pad remaining lines
-	lineCount := len(t.lines) - startLine
-	if lineCount < 0 {
-		lineCount = 0
+// markAt returns the highlight of column x
+func markAt(marks []lineMark, x int) markKind {
+	kind := markNone
+	for _, m := range marks {
+		if x >= m.start && x < m.end && m.kind > kind {
+			kind = m.kind
+		}
+	}
+	return kind
+}
+
+// cellStyle returns the lipgloss style for a cell's graphic rendition and
+// search highlight. Selected cells are drawn in reverse video by the caller.
+func (t *Terminal) cellStyle(attr emulator.Attr, mark markKind) lipgloss.Style {
+	fg := t.themeColor(attr.FG, t.ctx.Theme.Text)
+	bg := t.themeColor(attr.BG, t.ctx.Theme.Bg)
+	if attr.Has(emulator.AttrReverse) {
+		fg, bg = bg, fg
+	}
+	if attr.Has(emulator.AttrHidden) {
+		fg = bg
 	}
-	for i := lineCount; i < visibleLines; i++ {
-		output.WriteString("\n")
+	switch mark {
+	case markSuggest:
+		fg = t.ctx.Theme.TextMuted
+	case markMatch:
+		fg, bg = t.ctx.Theme.Bg, t.ctx.Theme.Warning
+	case markCurrent:
+		fg, bg = t.ctx.Theme.Bg, t.ctx.Theme.Accent
 	}
 
 	return lipgloss.NewStyle().
-		Width(t.width).
-		Height(t.height).
-		Background(t.ctx.Theme.Bg).
-		Foreground(t.ctx.Theme.Text).
-		Render(output.String())
+		Foreground(fg).
+		Background(bg).
+		Bold(attr.Has(emulator.AttrBold)).
+		Faint(attr.Has(emulator.AttrFaint)).
+		Italic(attr.Has(emulator.AttrItalic)).
+		Underline(attr.Has(emulator.AttrUnderline)).
+		Blink(attr.Has(emulator.AttrBlink)).
+		Strikethrough(attr.Has(emulator.AttrStrikethrough))
+}
+
+// themeColor maps a terminal color onto the theme.
+// The 16 base colors come from the theme palette; the rest of the 256-color
+// palette and truecolor values are passed through.
+func (t *Terminal) themeColor(c emulator.Color, def lipgloss.Color) lipgloss.Color {
+	if idx, ok := c.Index(); ok {
+		if idx < 16 {
+			return t.ctx.Theme.ANSI[idx]
+		}
+		return lipgloss.Color(strconv.Itoa(int(idx)))
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", r, g, b))
+	}
+	return def
+}
+
+// isBlankCell returns whether a cell is an empty cell with default colors
+func isBlankCell(c emulator.Cell) bool {
+	return (c.Rune == ' ' || c.Rune == 0) && c.Width == 1 && c.Attr == emulator.Attr{}
 }
 
 // Close closes the terminal
@@ -303,6 +753,21 @@ func (t *Terminal) IsRunning() bool {
 	return t.running
 }
 
+// IsAltScreen returns whether a full-screen program is using the alternate screen
+func (t *Terminal) IsAltScreen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.emu.Modes().AltScreen
+}
+
+// AppKeypad returns whether the program asked for application keypad
+// sequences (DECKPAM)
+func (t *Terminal) AppKeypad() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.emu.Modes().AppKeypad
+}
+
 // SendInput sends a string to the terminal input
 func (t *Terminal) SendInput(input string) {
 	t.mu.Lock()
@@ -313,5 +778,5 @@ func (t *Terminal) SendInput(input string) {
 	}
 
 	// Write the input to the PTY
-	_, _ = t.pty.Write([]byte(input))
+	_, _ = t.input.Write([]byte(input))
 }
@@ -0,0 +1,113 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ousiass/GoNeSh/internal/emulator"
+)
+
+// CommandFinishedMsg is sent when a shell command run in a terminal
+// finishes. Commands are only known for shells with shell integration.
+type CommandFinishedMsg struct {
+	TerminalID int
+	Command    emulator.Command
+}
+
+// finishedCommands returns a command reporting the shell commands that
+// finished since the last call
+func (t *Terminal) finishedCommands() tea.Cmd {
+	t.mu.Lock()
+	finished := t.finished
+	t.finished = nil
+	t.mu.Unlock()
+
+	var cmds []tea.Cmd
+	for _, c := range finished {
+		msg := CommandFinishedMsg{TerminalID: t.id, Command: c}
+		cmds = append(cmds, func() tea.Msg { return msg })
+	}
+	return tea.Batch(cmds...)
+}
+
+// promptRef returns the line prompt navigation starts from: the prompt
+// jumped to last while the view has not moved since, or the top of the view
+func (t *Terminal) promptRef() int {
+	if t.scrollTop == t.promptTop {
+		return t.promptLine
+	}
+	return t.scrollTop
+}
+
+// jumpToPrompt scrolls the previous (dir < 0) or next prompt to the top of
+// the view. The view cannot move when the buffer fits on the screen, so
+// the prompt is remembered for the next jump.
+func (t *Terminal) jumpToPrompt(dir int) {
+	ref := t.promptRef()
+	commands := t.emu.Commands()
+	target := -1
+	if dir < 0 {
+		for i := len(commands) - 1; i >= 0; i-- {
+			if commands[i].PromptLine < ref {
+				target = commands[i].PromptLine
+				break
+			}
+		}
+	} else {
+		for _, c := range commands {
+			if c.PromptLine > ref {
+				target = c.PromptLine
+				break
+			}
+		}
+	}
+	if target < 0 {
+		return
+	}
+	t.scrollTop = max(t.emu.TrimmedLines(), min(target, t.liveTop()))
+	t.promptLine = target
+	t.promptTop = t.scrollTop
+}
+
+// selectCommandOutput selects the output of the command at the prompt
+// navigation position and copies it
+func (t *Terminal) selectCommandOutput() tea.Cmd {
+	ref := t.promptRef()
+	commands := t.emu.Commands()
+	var cmd *emulator.Command
+	for i := range commands {
+		if commands[i].PromptLine > ref && cmd != nil {
+			break
+		}
+		cmd = &commands[i]
+	}
+	if cmd == nil || cmd.OutputLine < 0 {
+		return nil
+	}
+
+	cols, _ := t.emu.Size()
+	end := selectPos{line: cmd.EndLine, col: cmd.EndCol - 1}
+	if !cmd.Finished {
+		cursor := t.emu.Cursor()
+		end = selectPos{line: t.liveTop() + cursor.Y, col: cursor.X - 1}
+	}
+	if end.col < 0 {
+		// The output ended with a newline
+		end = selectPos{line: end.line - 1, col: cols - 1}
+	}
+	start := selectPos{line: cmd.OutputLine, col: cmd.OutputCol}
+	if end.before(start) {
+		return nil
+	}
+
+	t.sel = selection{
+		active: true,
+		unit:   selectChar,
+		anchor: start,
+		head:   end,
+	}
+	text := t.selectedText()
+	if text == "" {
+		return nil
+	}
+	return func() tea.Msg { return CopyMsg{Text: text} }
+}
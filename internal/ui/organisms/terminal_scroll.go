@@ -0,0 +1,230 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+)
+
+// Lines scrolled per mouse wheel step
+const wheelScrollLines = 3
+
+// Bubbletea has no key type for Shift+PgUp/PgDn and reports them as
+// unknown CSI sequences, which only expose their String form
+var (
+	shiftPgUpSeq   = fmt.Sprintf("?CSI%+v?", []byte("5;2~"))
+	shiftPgDownSeq = fmt.Sprintf("?CSI%+v?", []byte("6;2~"))
+)
+
+// pageScrollKey returns the direction of a Shift+PgUp/PgDn message
+func pageScrollKey(msg tea.Msg) (int, bool) {
+	s, ok := msg.(fmt.Stringer)
+	if !ok {
+		return 0, false
+	}
+	switch s.String() {
+	case shiftPgUpSeq:
+		return -1, true
+	case shiftPgDownSeq:
+		return 1, true
+	}
+	return 0, false
+}
+
+// EnterScrollMode starts browsing the scrollback from the live view
+func (t *Terminal) EnterScrollMode() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startScroll()
+}
+
+// IsScrolling returns whether the terminal shows the scrollback instead of
+// live output
+func (t *Terminal) IsScrolling() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scrolling
+}
+
+// startScroll enters scroll mode at the live view.
+// Full-screen programs on the alternate screen have no scrollback.
+func (t *Terminal) startScroll() bool {
+	if t.scrolling {
+		return true
+	}
+	if t.emu.Modes().AltScreen {
+		return false
+	}
+	t.scrolling = true
+	t.scrollTop = t.liveTop()
+	t.promptLine = t.liveTop() + t.emu.Cursor().Y
+	t.promptTop = t.scrollTop
+	return true
+}
+
+// liveTop returns the line number of the top line of the live view
+func (t *Terminal) liveTop() int {
+	_, rows := t.emu.Size()
+	return t.emu.TrimmedLines() + t.emu.BufferLen() - rows
+}
+
+// scrollBy moves the scrollback view by n lines (negative is up)
+func (t *Terminal) scrollBy(n int) {
+	if !t.startScroll() {
+		return
+	}
+	t.scrollTop = max(t.emu.TrimmedLines(), min(t.scrollTop+n, t.liveTop()))
+}
+
+// stopScroll returns to live output, ending any search
+func (t *Terminal) stopScroll() {
+	t.scrolling = false
+	t.search = searchState{regex: t.search.regex, current: -1}
+}
+
+// exitScrollAtBottom returns to live output once the view reaches it,
+// unless a search is active
+func (t *Terminal) exitScrollAtBottom() {
+	if t.scrolling && t.search.query == "" && t.scrollTop >= t.liveTop() {
+		t.stopScroll()
+	}
+}
+
+// handleScrollKey handles a key in scroll mode.
+// It returns false when the key should go to the PTY; any such key also
+// returns the view to live output.
+func (t *Terminal) handleScrollKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.scrolling {
+		return false, nil
+	}
+	if t.search.prompting {
+		t.handleSearchInput(msg)
+		return true, nil
+	}
+
+	_, rows := t.emu.Size()
+	half := max(rows/2, 1)
+
+	switch msg.String() {
+	case "j", "down", "ctrl+e":
+		t.scrollBy(1)
+	case "k", "up", "ctrl+y":
+		t.scrollBy(-1)
+	case "ctrl+d":
+		t.scrollBy(half)
+	case "ctrl+u":
+		t.scrollBy(-half)
+	case "ctrl+f", "pgdown":
+		t.scrollBy(rows)
+	case "ctrl+b", "pgup":
+		t.scrollBy(-rows)
+	case "g", "home":
+		t.scrollTop = t.emu.TrimmedLines()
+	case "G", "end":
+		t.scrollTop = t.liveTop()
+	case "/":
+		t.openSearchPrompt(false)
+	case "?":
+		t.openSearchPrompt(true)
+	case "n":
+		t.searchNext(false)
+	case "N":
+		t.searchNext(true)
+	case "[":
+		t.jumpToPrompt(-1)
+	case "]":
+		t.jumpToPrompt(1)
+	case "o":
+		return true, t.selectCommandOutput()
+	case "q", "esc":
+		t.stopScroll()
+	default:
+		t.stopScroll()
+		return false, nil
+	}
+	return true, nil
+}
+
+// handlePageScroll scrolls a page for Shift+PgUp/PgDn
+func (t *Terminal) handlePageScroll(dir int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, rows := t.emu.Size()
+	t.scrollBy(dir * rows)
+	t.exitScrollAtBottom()
+}
+
+// handleMouse handles mouse events in the terminal pane.
+// The wheel scrolls and the left button selects text.
+func (t *Terminal) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if msg.Button == tea.MouseButtonLeft || msg.Action != tea.MouseActionPress {
+		return t.handleSelectMouse(msg)
+	}
+
+	var dir int
+	var key tea.KeyType
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		dir, key = -1, tea.KeyUp
+	case tea.MouseButtonWheelDown:
+		dir, key = 1, tea.KeyDown
+	default:
+		return nil
+	}
+
+	if t.emu.Modes().AltScreen {
+		// Alternate scroll: full-screen programs receive cursor keys
+		if t.running && t.pty != nil {
+			data := emulator.EncodeKey(tea.Key{Type: key}, t.emu.Modes())
+			for i := 0; i < wheelScrollLines; i++ {
+				_, _ = t.input.Write(data)
+			}
+		}
+		return nil
+	}
+
+	t.scrollBy(dir * wheelScrollLines)
+	t.exitScrollAtBottom()
+	return nil
+}
+
+// scrollbackLines renders the scrollback view with its position indicator
+func (t *Terminal) scrollbackLines(rows int) []string {
+	t.scrollTop = max(t.emu.TrimmedLines(), min(t.scrollTop, t.liveTop()))
+	start := t.scrollTop - t.emu.TrimmedLines()
+
+	t.refreshSearch()
+	lines := make([]string, 0, rows)
+	for y := 0; y < rows; y++ {
+		marks := t.lineMarks(t.scrollTop + y)
+		lines = append(lines, t.renderLine(t.emu.BufferLine(start+y), -1, t.width, marks))
+	}
+
+	// "line N/M" indicator at the top right
+	indicator := lipgloss.NewStyle().
+		Foreground(t.ctx.Theme.Bg).
+		Background(t.ctx.Theme.Accent).
+		Bold(true).
+		Render(fmt.Sprintf(" line %d/%d ", start+1, t.emu.BufferLen()))
+	textWidth := t.width - lipgloss.Width(indicator)
+	if textWidth >= 0 && len(lines) > 0 {
+		first := t.renderLine(t.emu.BufferLine(start), -1, textWidth, t.lineMarks(t.scrollTop))
+		lines[0] = first + atoms.Fill(t.ctx, textWidth-lipgloss.Width(first)) + indicator
+	}
+
+	if t.search.prompting && len(lines) > 0 {
+		lines[len(lines)-1] = t.searchPrompt()
+	}
+	return lines
+}
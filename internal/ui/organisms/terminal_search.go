@@ -0,0 +1,354 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/emulator"
+	"github.com/ousiass/GoNeSh/internal/ui/atoms"
+)
+
+// searchMatch is a match in the terminal buffer.
+// line is a stable line number (see scrollTop); start and end are columns.
+type searchMatch struct {
+	line  int
+	start int
+	end   int
+}
+
+// before reports whether m comes before the position (line, col)
+func (m searchMatch) before(line, col int) bool {
+	return m.line < line || (m.line == line && m.start < col)
+}
+
+// searchState is the find-in-output state of a terminal
+type searchState struct {
+	prompting bool   // the query is being typed
+	input     []rune // query being typed
+	query     string // query matches were computed for
+	regex     bool   // regular expression instead of plain text
+	backward  bool   // direction of the search and of n
+	err       error  // invalid regular expression
+
+	// Position the incremental search started from, restored on cancel
+	originTop int
+
+	re      *regexp.Regexp
+	matches []searchMatch // sorted by position
+	current int           // index of the selected match, -1 for none
+	dirty   bool          // the buffer changed since matches were computed
+
+	// Lines before this stable line number were in the scrollback when
+	// matches were computed. Scrollback lines do not change, so only the
+	// lines from here on are searched again after new output.
+	scanned int
+}
+
+// StartSearch opens the search prompt, entering scroll mode if needed.
+// The search starts towards older output.
+func (t *Terminal) StartSearch() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.startScroll() {
+		t.openSearchPrompt(true)
+	}
+}
+
+// IsSearching returns whether the search query is being typed
+func (t *Terminal) IsSearching() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scrolling && t.search.prompting
+}
+
+// SearchMatches returns the selected match (1-based, 0 for none) and the
+// number of matches. ok is false when no search is active.
+func (t *Terminal) SearchMatches() (current, total int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.scrolling || t.search.query == "" || t.search.err != nil {
+		return 0, 0, false
+	}
+	t.refreshSearch()
+	return t.search.current + 1, len(t.search.matches), true
+}
+
+// openSearchPrompt starts typing a new query
+func (t *Terminal) openSearchPrompt(backward bool) {
+	t.search = searchState{
+		prompting: true,
+		regex:     t.search.regex,
+		backward:  backward,
+		originTop: t.scrollTop,
+		current:   -1,
+	}
+}
+
+// handleSearchInput edits the query while the prompt is open.
+// Matches are updated as the query is typed.
+func (t *Terminal) handleSearchInput(msg tea.KeyMsg) {
+	s := &t.search
+
+	switch msg.Type {
+	case tea.KeyEnter:
+		s.prompting = false
+		if s.query == "" || s.err != nil {
+			t.search = searchState{regex: s.regex, current: -1}
+		}
+		return
+	case tea.KeyEsc:
+		t.scrollTop = s.originTop
+		t.search = searchState{regex: s.regex, current: -1}
+		return
+	case tea.KeyBackspace:
+		if len(s.input) == 0 {
+			t.search = searchState{regex: s.regex, current: -1}
+			return
+		}
+		s.input = s.input[:len(s.input)-1]
+	case tea.KeyCtrlU:
+		s.input = s.input[:0]
+	case tea.KeyCtrlW:
+		s.input = []rune(strings.TrimRightFunc(
+			strings.TrimRightFunc(string(s.input), unicode.IsSpace),
+			func(r rune) bool { return !unicode.IsSpace(r) }))
+	case tea.KeyTab:
+		s.regex = !s.regex
+	case tea.KeySpace:
+		s.input = append(s.input, ' ')
+	case tea.KeyRunes:
+		s.input = append(s.input, msg.Runes...)
+	default:
+		return
+	}
+
+	t.scrollTop = s.originTop
+	t.setSearchQuery(string(s.input))
+	if len(s.matches) > 0 {
+		_, rows := t.emu.Size()
+		origin := s.originTop
+		if s.backward {
+			origin += rows
+		}
+		t.selectMatch(t.nearestMatch(origin, 0, s.backward))
+	}
+}
+
+// setSearchQuery compiles a query and finds its matches.
+// Queries without upper case letters ignore case.
+func (t *Terminal) setSearchQuery(query string) {
+	s := &t.search
+	s.query = query
+	s.re = nil
+	s.err = nil
+	s.matches = nil
+	s.current = -1
+	if query == "" {
+		return
+	}
+
+	pattern := query
+	if !s.regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if strings.ToLower(query) == query {
+		pattern = "(?i)" + pattern
+	}
+	s.re, s.err = regexp.Compile(pattern)
+	if s.err != nil {
+		return
+	}
+	t.findMatches()
+}
+
+// findMatches searches every line of the buffer
+func (t *Terminal) findMatches() {
+	t.search.scanned = 0
+	t.scanMatches()
+}
+
+// scanMatches searches the lines that may have changed since matches were
+// computed, from the stable line number scanned on, and drops the matches
+// on lines trimmed from the scrollback
+func (t *Terminal) scanMatches() {
+	s := &t.search
+	s.dirty = false
+
+	trimmed := t.emu.TrimmedLines()
+	from := max(s.scanned, trimmed)
+	first := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= trimmed })
+	last := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= from })
+	s.matches = append(s.matches[:0], s.matches[first:last]...)
+	s.scanned = trimmed + t.emu.ScrollbackLen()
+
+	for i := from - trimmed; i < t.emu.BufferLen(); i++ {
+		text, cols := lineText(t.emu.BufferLine(i))
+		for _, loc := range s.re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			s.matches = append(s.matches, searchMatch{
+				line:  trimmed + i,
+				start: cols[loc[0]],
+				end:   cols[loc[1]],
+			})
+		}
+	}
+}
+
+// refreshSearch recomputes matches after new output, keeping the selection
+func (t *Terminal) refreshSearch() {
+	s := &t.search
+	if !s.dirty || s.re == nil {
+		return
+	}
+
+	var cur searchMatch
+	hasCurrent := s.current >= 0 && s.current < len(s.matches)
+	if hasCurrent {
+		cur = s.matches[s.current]
+	}
+	t.scanMatches()
+	s.current = -1
+	if hasCurrent && len(s.matches) > 0 {
+		s.current = t.nearestMatch(cur.line, cur.start, false)
+	}
+}
+
+// searchNext selects the next match in the search direction, or the
+// previous one when reverse is set. The search wraps around the buffer.
+func (t *Terminal) searchNext(reverse bool) {
+	t.refreshSearch()
+	s := &t.search
+	if len(s.matches) == 0 {
+		return
+	}
+
+	backward := s.backward != reverse
+	if s.current < 0 {
+		_, rows := t.emu.Size()
+		origin := t.scrollTop
+		if backward {
+			origin += rows
+		}
+		t.selectMatch(t.nearestMatch(origin, 0, backward))
+		return
+	}
+
+	step := 1
+	if backward {
+		step = -1
+	}
+	t.selectMatch((s.current + step + len(s.matches)) % len(s.matches))
+}
+
+// nearestMatch returns the index of the first match at or after the
+// position, or the last one before it when backward, wrapping around
+func (t *Terminal) nearestMatch(line, col int, backward bool) int {
+	matches := t.search.matches
+	i := sort.Search(len(matches), func(i int) bool {
+		return !matches[i].before(line, col)
+	})
+	if backward {
+		i--
+		if i < 0 {
+			i = len(matches) - 1
+		}
+		return i
+	}
+	if i == len(matches) {
+		i = 0
+	}
+	return i
+}
+
+// selectMatch makes a match current and scrolls it into view
+func (t *Terminal) selectMatch(i int) {
+	t.search.current = i
+	m := t.search.matches[i]
+
+	_, rows := t.emu.Size()
+	if m.line < t.scrollTop || m.line >= t.scrollTop+rows {
+		t.scrollTop = m.line - rows/2
+	}
+	t.scrollTop = max(t.emu.TrimmedLines(), min(t.scrollTop, t.liveTop()))
+}
+
+// searchMarks returns the search highlights of a line
+func (t *Terminal) searchMarks(line int) []lineMark {
+	s := &t.search
+	if s.prompting && s.err != nil {
+		return nil
+	}
+
+	i := sort.Search(len(s.matches), func(i int) bool {
+		return s.matches[i].line >= line
+	})
+	var marks []lineMark
+	for ; i < len(s.matches) && s.matches[i].line == line; i++ {
+		kind := markMatch
+		if i == s.current {
+			kind = markCurrent
+		}
+		marks = append(marks, lineMark{start: s.matches[i].start, end: s.matches[i].end, kind: kind})
+	}
+	return marks
+}
+
+// searchPrompt renders the query being typed
+func (t *Terminal) searchPrompt() string {
+	s := &t.search
+
+	prefix := "/"
+	if s.backward {
+		prefix = "?"
+	}
+	prompt := atoms.Label(t.ctx, prefix, t.ctx.Theme.Accent) +
+		atoms.Text(t.ctx, string(s.input)) +
+		lipgloss.NewStyle().Reverse(true).Render(" ")
+
+	var status string
+	switch {
+	case s.err != nil:
+		status = atoms.ErrorText(t.ctx, "invalid regex ")
+	case s.query != "" && len(s.matches) == 0:
+		status = atoms.WarningText(t.ctx, "no match ")
+	}
+	if s.regex {
+		status += atoms.TextMuted(t.ctx, "regex ")
+	}
+
+	fill := t.width - lipgloss.Width(prompt) - lipgloss.Width(status)
+	if fill < 0 {
+		return prompt
+	}
+	return prompt + atoms.Fill(t.ctx, fill) + status
+}
+
+// lineText returns the text of a line and the column of each byte of it.
+// The extra last entry is the column after the text.
+func lineText(line emulator.Line) (string, []int) {
+	var sb strings.Builder
+	cols := make([]int, 0, len(line.Cells)+1)
+	for x, cell := range line.Cells {
+		if cell.Width == 0 {
+			continue
+		}
+		r := cell.Rune
+		if r == 0 {
+			r = ' '
+		}
+		n, _ := sb.WriteRune(r)
+		for ; n > 0; n-- {
+			cols = append(cols, x)
+		}
+	}
+	cols = append(cols, len(line.Cells))
+	return sb.String(), cols
+}
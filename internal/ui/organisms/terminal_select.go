@@ -0,0 +1,299 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ousiass/GoNeSh/internal/emulator"
+)
+
+// Clicks closer together than this count as a double or triple click
+const multiClickInterval = 400 * time.Millisecond
+
+// Characters other than letters and digits that are part of a word for
+// double-click selection, so that paths and URLs are selected whole
+const wordChars = "-_./~:@%+#?=&"
+
+// CopyMsg is sent when text is selected in a terminal, to be copied to the
+// clipboard
+type CopyMsg struct {
+	Text string
+}
+
+// selectUnit is what a selection extends by
+type selectUnit uint8
+
+const (
+	selectChar selectUnit = iota
+	selectWord
+	selectLine
+)
+
+// selectPos is a position in the terminal buffer.
+// line is a stable line number (see scrollTop), or a screen row on the
+// alternate screen.
+type selectPos struct {
+	line int
+	col  int
+}
+
+// before reports whether p comes before q
+func (p selectPos) before(q selectPos) bool {
+	return p.line < q.line || (p.line == q.line && p.col < q.col)
+}
+
+// selection is the mouse selection of a terminal
+type selection struct {
+	active   bool
+	dragging bool
+	alt      bool // made on the alternate screen
+	unit     selectUnit
+	anchor   selectPos
+	head     selectPos
+
+	// Multi-click detection
+	lastClick time.Time
+	clickPos  selectPos
+	clicks    int
+}
+
+// handleSelectMouse handles the left button in the terminal pane.
+// The selection is copied when the button is released.
+func (t *Terminal) handleSelectMouse(msg tea.MouseMsg) tea.Cmd {
+	s := &t.sel
+	pos := t.viewPos(msg.X, msg.Y)
+
+	switch msg.Action {
+	case tea.MouseActionPress:
+		now := time.Now()
+		if s.clicks > 0 && s.clickPos == pos && now.Sub(s.lastClick) < multiClickInterval {
+			s.clicks = s.clicks%3 + 1
+		} else {
+			s.clicks = 1
+		}
+		s.lastClick = now
+		s.clickPos = pos
+
+		s.unit = selectUnit(s.clicks - 1)
+		s.alt = t.emu.Modes().AltScreen
+		s.anchor = pos
+		s.head = pos
+		s.dragging = true
+		// A single click only starts a selection once the mouse moves
+		s.active = s.unit != selectChar
+	case tea.MouseActionMotion:
+		if !s.dragging {
+			return nil
+		}
+		if pos != s.head {
+			s.head = pos
+			s.active = true
+		}
+	case tea.MouseActionRelease:
+		if !s.dragging {
+			return nil
+		}
+		s.dragging = false
+		if text := t.selectedText(); text != "" {
+			return func() tea.Msg { return CopyMsg{Text: text} }
+		}
+	}
+	return nil
+}
+
+// viewPos converts a position in the pane to a buffer position
+func (t *Terminal) viewPos(x, y int) selectPos {
+	cols, rows := t.emu.Size()
+	x = max(0, min(x, cols-1))
+	y = max(0, min(y, rows-1))
+
+	switch {
+	case t.emu.Modes().AltScreen:
+		return selectPos{line: y, col: x}
+	case t.scrolling:
+		return selectPos{line: t.scrollTop + y, col: x}
+	default:
+		return selectPos{line: t.liveTop() + y, col: x}
+	}
+}
+
+// selectionLine returns the buffer line at a selection position
+func (t *Terminal) selectionLine(line int) (emulator.Line, bool) {
+	if t.sel.alt {
+		_, rows := t.emu.Size()
+		if line < 0 || line >= rows {
+			return emulator.Line{}, false
+		}
+		return t.emu.Line(line), true
+	}
+	i := line - t.emu.TrimmedLines()
+	if i < 0 || i >= t.emu.BufferLen() {
+		return emulator.Line{}, false
+	}
+	return t.emu.BufferLine(i), true
+}
+
+// selectionBounds returns the ordered start and end (exclusive column) of
+// the selection, extended to whole words or lines
+func (t *Terminal) selectionBounds() (start, end selectPos) {
+	s := &t.sel
+	start, end = s.anchor, s.head
+	if end.before(start) {
+		start, end = end, start
+	}
+
+	switch s.unit {
+	case selectWord:
+		if line, ok := t.selectionLine(start.line); ok {
+			start.col, _ = wordBounds(line, start.col)
+		}
+		if line, ok := t.selectionLine(end.line); ok {
+			_, end.col = wordBounds(line, end.col)
+		} else {
+			end.col++
+		}
+	case selectLine:
+		cols, _ := t.emu.Size()
+		start.col = 0
+		end.col = cols
+	default:
+		end.col++
+	}
+	return start, end
+}
+
+// wordBounds returns the columns [start, end) of the word at col.
+// A cell that is not part of a word is selected by itself.
+func wordBounds(line emulator.Line, col int) (int, int) {
+	cells := line.Cells
+	if col >= len(cells) {
+		return col, col + 1
+	}
+	if !isWordCell(cells[col]) {
+		return col, col + 1
+	}
+
+	start, end := col, col+1
+	for start > 0 && isWordCell(cells[start-1]) {
+		start--
+	}
+	for end < len(cells) && isWordCell(cells[end]) {
+		end++
+	}
+	return start, end
+}
+
+// isWordCell returns whether a cell is part of a word.
+// The trailing half of a wide character belongs to it.
+func isWordCell(c emulator.Cell) bool {
+	if c.Width == 0 {
+		return true
+	}
+	return unicode.IsLetter(c.Rune) || unicode.IsDigit(c.Rune) || strings.ContainsRune(wordChars, c.Rune)
+}
+
+// selectionMarks returns the selection highlight of a line
+func (t *Terminal) selectionMarks(line int) []lineMark {
+	if !t.sel.active {
+		return nil
+	}
+	start, end := t.selectionBounds()
+	if line < start.line || line > end.line {
+		return nil
+	}
+
+	cols, _ := t.emu.Size()
+	m := lineMark{start: 0, end: cols, kind: markSelect}
+	if line == start.line {
+		m.start = start.col
+	}
+	if line == end.line {
+		m.end = end.col
+	}
+	return []lineMark{m}
+}
+
+// selectedText returns the text of the selection.
+// Lines that were wrapped by the terminal are joined.
+func (t *Terminal) selectedText() string {
+	if !t.sel.active {
+		return ""
+	}
+	start, end := t.selectionBounds()
+
+	var sb strings.Builder
+	for n := start.line; n <= end.line; n++ {
+		line, ok := t.selectionLine(n)
+		if !ok {
+			continue
+		}
+		from, to := 0, len(line.Cells)
+		if n == start.line {
+			from = start.col
+		}
+		if n == end.line {
+			to = min(end.col, to)
+		}
+		text := cellsText(line.Cells, from, to)
+		if n < end.line && !line.Wrapped {
+			text = strings.TrimRight(text, " ") + "\n"
+		} else if n == end.line {
+			text = strings.TrimRight(text, " ")
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// clearSelection removes the selection
+func (t *Terminal) clearSelection() {
+	t.sel.active = false
+	t.sel.dragging = false
+}
+
+// cellsText returns the text of the cells [from, to)
+func cellsText(cells []emulator.Cell, from, to int) string {
+	var sb strings.Builder
+	for x := max(from, 0); x < to && x < len(cells); x++ {
+		if cells[x].Width == 0 {
+			continue
+		}
+		r := cells[x].Rune
+		if r == 0 {
+			r = ' '
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Paste sends text to the PTY as if typed by the user.
+// When the program enabled bracketed paste (DECSET 2004), the text is
+// wrapped in paste markers so that it is not run line by line; markers
+// inside the text are removed so that it cannot end the paste early.
+// Otherwise newlines are sent as carriage returns, like xterm does.
+func (t *Terminal) Paste(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running || t.pty == nil || text == "" {
+		return
+	}
+	if t.scrolling {
+		t.stopScroll()
+	}
+	t.clearSelection()
+
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	if t.emu.Modes().BracketedPaste {
+		text = strings.ReplaceAll(text, "\x1b[200~", "")
+		text = strings.ReplaceAll(text, "\x1b[201~", "")
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	// Queued, so that a large paste does not block while holding the lock
+	_, _ = t.input.Write([]byte(text))
+}
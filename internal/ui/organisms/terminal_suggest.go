@@ -0,0 +1,104 @@
+// Package organisms provides complex UI components for GoNeSh.
+package organisms
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ousiass/GoNeSh/internal/emulator"
+)
+
+// SuggestFunc returns a command completing the input typed at a prompt in
+// dir on host, or "" if there is none
+type SuggestFunc func(input, dir, host string) string
+
+// SetSuggestFunc sets the function that suggests how to complete the
+// command line. Suggestions need shell integration to find the command line.
+func (t *Terminal) SetSuggestFunc(fn SuggestFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.suggest = fn
+	t.suggestInput = ""
+	t.suggestion = ""
+}
+
+// HasSuggestion returns whether a suggestion is shown after the cursor
+func (t *Terminal) HasSuggestion() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.currentSuggestion() != ""
+}
+
+// currentSuggestion returns the rest of the suggested command after what
+// has been typed, or "" if there is none.
+// The suggestion is kept until the input changes.
+func (t *Terminal) currentSuggestion() string {
+	if t.suggest == nil || !t.running || t.scrolling {
+		return ""
+	}
+	input, ok := t.emu.Input()
+	if !ok || strings.TrimSpace(input) == "" {
+		return ""
+	}
+	if input != t.suggestInput {
+		dir, host := t.emu.WorkingDir()
+		t.suggestInput = input
+		t.suggestion = strings.TrimPrefix(t.suggest(input, dir, host), input)
+	}
+	return t.suggestion
+}
+
+// acceptSuggestion types the suggestion, or its next word for Alt+F, if
+// the key accepts it. It reports whether the key was used.
+func (t *Terminal) acceptSuggestion(key string) bool {
+	suggestion := t.currentSuggestion()
+	if suggestion == "" {
+		return false
+	}
+
+	var text string
+	switch key {
+	case "right", "end":
+		text = suggestion
+	case "alt+f":
+		text = nextWord(suggestion)
+	default:
+		return false
+	}
+	t.clearSelection()
+	_, _ = t.input.Write([]byte(text))
+	return true
+}
+
+// nextWord returns s up to the end of its first word
+func nextWord(s string) string {
+	i := 0
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	for i < len(s) && s[i] != ' ' {
+		i++
+	}
+	return s[:i]
+}
+
+// suggestionLine draws the suggestion into a copy of the cursor line,
+// starting at the cursor. It returns the line and the highlight of the
+// suggested cells.
+func suggestionLine(line emulator.Line, x int, suggestion string) (emulator.Line, lineMark) {
+	cells := append([]emulator.Cell(nil), line.Cells...)
+	mark := lineMark{start: x, end: x, kind: markSuggest}
+	for _, r := range suggestion {
+		w := lipgloss.Width(string(r))
+		if w == 0 || mark.end+w > len(cells) {
+			break
+		}
+		cells[mark.end] = emulator.Cell{Rune: r, Width: uint8(w)}
+		if w == 2 {
+			cells[mark.end+1] = emulator.Cell{Width: 0}
+		}
+		mark.end += w
+	}
+	line.Cells = cells
+	return line, mark
+}
@@ -25,6 +25,9 @@ type Config struct {
 
 	// Git settings
 	Git GitConfig `mapstructure:"git"`
+
+	// History settings
+	History HistoryConfig `mapstructure:"history"`
 }
 
 // AIConfig holds AI-related configuration
@@ -66,6 +69,14 @@ type AutoCommitConfig struct {
 	EmojiMap     map[string]string `mapstructure:"emoji_map"`
 }
 
+// HistoryConfig holds the rules for recording command history
+type HistoryConfig struct {
+	IgnoreSpace   bool     `mapstructure:"ignore_space"`   // Don't record commands starting with a space
+	Ignore        []string `mapstructure:"ignore"`         // Regexes of commands not to record
+	Redact        []string `mapstructure:"redact"`         // Regexes of text to mask
+	RedactSecrets bool     `mapstructure:"redact_secrets"` // Mask tokens, keys and passwords
+}
+
 // configDir returns the GoNeSh configuration directory
 func configDir() (string, error) {
 	home, err := os.UserHomeDir()
@@ -127,6 +138,10 @@ func setDefaults() {
 	viper.SetDefault("git.auto_commit.emoji", true)
 	viper.SetDefault("git.auto_commit.candidates", 5)
 	viper.SetDefault("git.auto_commit.max_diff_lines", 500)
+	viper.SetDefault("history.ignore_space", true)
+	viper.SetDefault("history.ignore", []string{})
+	viper.SetDefault("history.redact", []string{})
+	viper.SetDefault("history.redact_secrets", true)
 }
 
 // createDefaultConfig creates default configuration files
@@ -160,6 +175,10 @@ func createDefaultConfig(dir string) (*Config, error) {
 				},
 			},
 		},
+		History: HistoryConfig{
+			IgnoreSpace:   true,
+			RedactSecrets: true,
+		},
 	}
 
 	// config.yaml を作成